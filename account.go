@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+)
+
+// Only set when ACCOUNT_ALIAS_LOOKUP is on
+var iamClient *iam.Client
+
+// The alias looked up from IAM, once per container. A failed lookup isn't
+// cached, so the next alert tries again.
+var (
+	accountAliasMu       sync.Mutex
+	accountAlias         string
+	accountAliasResolved bool
+)
+
+// Names the account an alert came from, for the subject prefix: ACCOUNT_NAME
+// when set, else the IAM account alias when ACCOUNT_ALIAS_LOOKUP is on,
+// falling back to the account ID in the alert's ARN. Empty when neither
+// option is set.
+func accountLabel(ctx context.Context, alert Alert) string {
+	if cfg.AccountName != "" {
+		return cfg.AccountName
+	}
+	if iamClient == nil {
+		return ""
+	}
+	if alias := lookupAccountAlias(ctx); alias != "" {
+		return alias
+	}
+	return accountFromARN(alert.Resource)
+}
+
+func lookupAccountAlias(ctx context.Context) string {
+	accountAliasMu.Lock()
+	defer accountAliasMu.Unlock()
+	if accountAliasResolved {
+		return accountAlias
+	}
+	out, err := iamClient.ListAccountAliases(ctx, &iam.ListAccountAliasesInput{})
+	if err != nil {
+		slog.Warn("Error looking up account alias, using account ID", "error", err)
+		return ""
+	}
+	// An account has at most one alias
+	if len(out.AccountAliases) > 0 {
+		accountAlias = out.AccountAliases[0]
+	}
+	accountAliasResolved = true
+	return accountAlias
+}
+
+// Extracts "123456789012" from "arn:aws:ecs:us-east-1:123456789012:task/..."
+func accountFromARN(arn string) string {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) < 6 || parts[0] != "arn" {
+		return ""
+	}
+	return parts[4]
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const acmExpiryDetailType = "ACM Certificate Approaching Expiration"
+
+// ACM sends the expiration event daily from 45 days before a certificate
+// expires, e.g. {"DaysToExpiry": 31, "CommonName": "example.com"}
+type ACMExpiryDetail struct {
+	DaysToExpiry int    `json:"DaysToExpiry"`
+	CommonName   string `json:"CommonName"`
+}
+
+// Severity rises as expiry nears: critical under a week, warning under
+// 30 days, info before that
+func acmExpirySeverity(days int) Severity {
+	switch {
+	case days < 7:
+		return SeverityCritical
+	case days < 30:
+		return SeverityWarning
+	default:
+		return SeverityInfo
+	}
+}
+
+func acmExpiryAlert(event events.CloudWatchEvent) (ok bool, alert Alert, err error) {
+	var detail ACMExpiryDetail
+	if err := decodeDetail(event.Detail, &detail); err != nil {
+		return false, Alert{}, fmt.Errorf("failed to unmarshal ACM expiry detail: %v", err)
+	}
+	certArn := ""
+	if len(event.Resources) > 0 {
+		certArn = event.Resources[0]
+	}
+
+	alert = Alert{
+		DetailType: event.DetailType,
+		Resource:   certArn,
+		Severity:   acmExpirySeverity(detail.DaysToExpiry),
+		Title:      fmt.Sprintf("Certificate for %s expires in %d days", detail.CommonName, detail.DaysToExpiry),
+		Fields: map[string]string{
+			"Domain":         detail.CommonName,
+			"Days To Expiry": fmt.Sprint(detail.DaysToExpiry),
+			"Certificate":    certArn,
+		},
+		Timestamp: event.Time,
+	}
+	if event.Region != "" && certArn != "" {
+		alert.Links = []string{fmt.Sprintf("https://%s.console.aws.amazon.com/acm/home?region=%s#/certificates/%s",
+			event.Region, event.Region, getResourceName(certArn))}
+	}
+	return true, alert, nil
+}
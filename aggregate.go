@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+const aggregateKeyPrefix = "agg#"
+
+// How many distinct failure reasons the summary alert quotes
+const aggregateSampleSize = 5
+
+// One failure counted into a service's aggregation window
+type aggregateEntry struct {
+	At      time.Time `json:"at"`
+	Cluster string    `json:"cluster"`
+	Reason  string    `json:"reason,omitempty"`
+	Event   string    `json:"event,omitempty"`
+}
+
+// Counts a task failure into its service's aggregation window. The first
+// failure of a window is sent straight away (aggregated=false); later ones
+// are held and summarised by flushTaskAggregates once the window closes.
+func aggregateTaskFailure(ctx context.Context, alert Alert) (aggregated bool, err error) {
+	if cfg.AggregateWindow <= 0 || !durableStates {
+		return false, nil
+	}
+	entry := aggregateEntry{At: time.Now(), Cluster: alert.Cluster, Event: eventToken()}
+	if len(alert.Details) > 0 {
+		entry.Reason = alert.Details[0]
+	}
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		return false, err
+	}
+
+	key := aggregateKeyPrefix + alert.Service
+	n, err := states.Append(ctx, key, string(raw), cfg.AggregateWindow+stateTTL)
+	if err != nil || n == 1 {
+		return false, err
+	}
+	// A redelivery of the failure that opened the window is still sent
+	held, err := states.List(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	entries := decodeAggregateEntries(held)
+	return len(entries) == 0 || entries[0].Event != entry.Event, nil
+}
+
+// Runs on the schedule rule: sends one "N tasks failing" alert for every
+// service whose window has closed with more than the one failure already sent.
+func flushTaskAggregates(ctx, sendCtx context.Context) error {
+	if cfg.AggregateWindow <= 0 || !durableStates {
+		return nil
+	}
+	keys, err := states.Keys(ctx, aggregateKeyPrefix)
+	if err != nil {
+		return fmt.Errorf("failed to list task failure aggregates: %v", err)
+	}
+	cutoff := time.Now().Add(-cfg.AggregateWindow)
+
+	for _, key := range keys {
+		raw, err := states.List(ctx, key)
+		if err != nil {
+			slog.Error("Error reading aggregation window", "key", key, "error", err)
+			continue
+		}
+		entries := decodeAggregateEntries(raw)
+		// The window opens with its first failure
+		if len(entries) == 0 || !entries[0].At.Before(cutoff) {
+			continue
+		}
+		// Delete first so a failure arriving now starts a fresh window
+		if err := states.Delete(ctx, key); err != nil {
+			slog.Error("Error closing aggregation window", "key", key, "error", err)
+			continue
+		}
+		if len(entries) <= 1 {
+			continue
+		}
+
+		serviceName := key[len(aggregateKeyPrefix):]
+		notify(sendCtx, Alert{
+			DetailType: "ECS Task State Change",
+			Severity:   SeverityCritical,
+			Title:      fmt.Sprintf("%d tasks failing in service %s", len(entries), serviceName),
+			Service:    serviceName,
+			Cluster:    entries[len(entries)-1].Cluster,
+			Fields: map[string]string{
+				"Window": fmt.Sprintf("%s starting %s", cfg.AggregateWindow, entries[0].At.UTC().Format(time.RFC3339)),
+			},
+			Details:   sampleReasons(entries),
+			Timestamp: time.Now(),
+		})
+	}
+	return nil
+}
+
+// Skips entries that do not parse rather than losing the whole window, and
+// the extra entries of a redelivered event
+func decodeAggregateEntries(raw []string) []aggregateEntry {
+	entries := make([]aggregateEntry, 0, len(raw))
+	seen := make(map[string]bool)
+	for _, r := range raw {
+		var e aggregateEntry
+		if err := json.Unmarshal([]byte(r), &e); err != nil {
+			slog.Warn("Skipping unreadable aggregate entry", "error", err)
+			continue
+		}
+		if e.Event != "" && seen[e.Event] {
+			continue
+		}
+		seen[e.Event] = true
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// Distinct failure reasons from an aggregation window, capped at
+// aggregateSampleSize
+func sampleReasons(entries []aggregateEntry) []string {
+	var sample []string
+	for _, e := range entries {
+		if e.Reason == "" || contains(sample, e.Reason) {
+			continue
+		}
+		sample = append(sample, e.Reason)
+		if len(sample) == aggregateSampleSize {
+			break
+		}
+	}
+	return sample
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAggregateTaskFailure(t *testing.T) {
+	withConfig(t, func(c *Config) { c.AggregateWindow = 5 * time.Minute })
+	sent := captureAlerts(t)
+	ctx := context.Background()
+	alert := Alert{Service: "api", Cluster: "prod", Details: []string{"OutOfMemoryError"}}
+
+	for i, want := range []bool{false, true, true} {
+		aggregated, err := aggregateTaskFailure(ctx, alert)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if aggregated != want {
+			t.Errorf("failure %d: aggregated=%v, want %v", i+1, aggregated, want)
+		}
+	}
+
+	// The window is still open
+	if err := flushTaskAggregates(ctx, ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(*sent) != 0 {
+		t.Errorf("flush sent %d alerts for an open window", len(*sent))
+	}
+	if entries, _ := states.List(ctx, aggregateKeyPrefix+"api"); len(entries) != 3 {
+		t.Errorf("open window holds %d failures, want 3", len(entries))
+	}
+}
+
+func TestFlushTaskAggregates(t *testing.T) {
+	withConfig(t, func(c *Config) { c.AggregateWindow = 5 * time.Minute })
+	sent := captureAlerts(t)
+	ctx := context.Background()
+	closed := time.Now().Add(-10 * time.Minute)
+	add := func(service, reason string) {
+		t.Helper()
+		raw, _ := json.Marshal(aggregateEntry{At: closed, Cluster: "prod", Reason: reason})
+		if _, err := states.Append(ctx, aggregateKeyPrefix+service, string(raw), time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+	add("api", "OutOfMemoryError")
+	add("api", "Essential container exited")
+	add("api", "OutOfMemoryError")
+	add("worker", "OutOfMemoryError")
+
+	if err := flushTaskAggregates(ctx, ctx); err != nil {
+		t.Fatal(err)
+	}
+	// The worker's single failure was already sent when it happened
+	if len(*sent) != 1 {
+		t.Fatalf("flush sent %d alerts, want 1", len(*sent))
+	}
+	got := (*sent)[0]
+	if got.Title != "3 tasks failing in service api" || got.Cluster != "prod" {
+		t.Errorf("alert = %q in %q", got.Title, got.Cluster)
+	}
+	if want := []string{"OutOfMemoryError", "Essential container exited"}; !slices.Equal(got.Details, want) {
+		t.Errorf("details = %q, want %q", got.Details, want)
+	}
+	if !strings.HasPrefix(got.Fields["Window"], "5m0s starting ") {
+		t.Errorf("window field = %q", got.Fields["Window"])
+	}
+	if keys, _ := states.Keys(ctx, aggregateKeyPrefix); len(keys) != 0 {
+		t.Errorf("closed windows still held: %q", keys)
+	}
+}
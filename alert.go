@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+type Severity string
+
+const (
+	SeverityCritical Severity = "critical"
+	SeverityWarning  Severity = "warning"
+	SeverityInfo     Severity = "info"
+)
+
+// Alert is the channel-agnostic content of a notification. handleRequest
+// builds it once and each sender renders it in its own format.
+type Alert struct {
+	DetailType string // EventBridge detail-type the alert came from
+	Resource   string // ARN of the task, service or instance the alert is about
+	ExitCode   int    // exit code of the first failed container, if any
+	Escalated  bool   // repeated failures crossed ESCALATE_AFTER, so paging channels get it
+	Forced     bool   // a reason matched FORCE_ALERT_REASONS, so suppressReason lets it through
+	Sampled    bool   // kept under INFO_SAMPLE_RATE, so readers see only a share; shown after the title
+	Account    string // account name or ID shown before the title, see accountLabel
+	Deployment string // ECS deployment ("ecs-svc/...") the alert is about or that started the task
+	TaskFamily string // task definition family of a failed task, see countRestart
+	Banner     string // NOTIFICATION_BANNER line for the channel being sent to, see notify
+	IncidentID string // short hash of the dedup key, see incidentID
+
+	Severity  Severity
+	Title     string
+	Service   string
+	Cluster   string
+	Fields    map[string]string // extra labelled values, rendered sorted by label
+	Details   []string          // bullet lines, e.g. one per failed container
+	Links     []string
+	Timestamp time.Time
+}
+
+// The title as channels show it, prefixed with the account in brackets and
+// the SEVERITY_ICONS entry for the alert's severity, and marked when sampled
+func (a Alert) subject() string {
+	title := a.displayTitle()
+	if a.Account != "" {
+		title = "[" + a.Account + "] " + title
+	}
+	if icon := cfg.SeverityIcons[a.Severity]; icon != "" {
+		return icon + " " + title
+	}
+	return title
+}
+
+// The title with the sampling mark. Keys are built from Title alone, so
+// they don't change with INFO_SAMPLE_RATE.
+func (a Alert) displayTitle() string {
+	if a.Sampled {
+		return a.Title + " (sampled)"
+	}
+	return a.Title
+}
+
+// Returns a copy of the alert showing at most max detail lines, with a
+// closing line saying how many were left out. max <= 0 keeps them all.
+func capDetails(a Alert, max int) Alert {
+	if max <= 0 || len(a.Details) <= max {
+		return a
+	}
+	details := slices.Clone(a.Details[:max])
+	a.Details = append(details, fmt.Sprintf("… and %d more", len(a.Details)-max))
+	return a
+}
+
+func (a *Alert) setField(label, value string) {
+	if a.Fields == nil {
+		a.Fields = make(map[string]string)
+	}
+	a.Fields[label] = value
+}
+
+// Renders the alert body as text. bold wraps labels in the target's markup
+// (Slack mrkdwn, Markdown, or nothing for plain text). Labels are shown in
+// LOCALE's language but sorted by their English form, so fields keep the
+// same order in every language.
+func renderText(a Alert, bold func(string) string) string {
+	var b strings.Builder
+	if a.Banner != "" {
+		b.WriteString(a.Banner + "\n\n")
+	}
+	line := func(label, value string) {
+		if value != "" {
+			fmt.Fprintf(&b, "%s %s\n", bold(translate(label)+":"), value)
+		}
+	}
+
+	line("Service", a.Service)
+	line("Cluster", a.Cluster)
+	line("Incident ID", a.IncidentID)
+
+	labels := make([]string, 0, len(a.Fields))
+	for label := range a.Fields {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	for _, label := range labels {
+		line(label, a.Fields[label])
+	}
+
+	if len(a.Details) > 0 {
+		b.WriteString(bold(translate("Details")+":") + "\n")
+		for _, d := range a.Details {
+			fmt.Fprintf(&b, "- %s\n", d)
+		}
+	}
+	for _, link := range a.Links {
+		b.WriteString(link + "\n")
+	}
+	if !a.Timestamp.IsZero() {
+		loc := cfg.Location
+		if loc == nil {
+			loc = time.UTC
+		}
+		line("Time", a.Timestamp.In(loc).Format(time.RFC1123))
+	}
+
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func slackBold(s string) string    { return "*" + s + "*" }
+func markdownBold(s string) string { return "**" + s + "**" }
+func plainText(s string) string    { return s }
+
+// Slack mrkdwn: title on its own line followed by the body
+func renderSlack(a Alert) string {
+	return slackBold(a.subject()) + "\n" + renderText(a, slackBold)
+}
+
+// Standard Markdown, used by Mattermost
+func renderMarkdown(a Alert) string {
+	return markdownBold(a.subject()) + "\n" + renderText(a, markdownBold)
+}
+
+// Plain text email body; the title goes in the subject
+func renderEmailBody(a Alert) string {
+	return renderText(a, plainText)
+}
+
+// Builds ECS console links for the service and, when known, the task
+func ecsConsoleLinks(region, cluster, service, taskArn string) []string {
+	if region == "" || cluster == "" {
+		return nil
+	}
+	base := fmt.Sprintf("https://%s.console.aws.amazon.com/ecs/v2/clusters/%s", region, cluster)
+	var links []string
+	if service != "" {
+		links = append(links, fmt.Sprintf("%s/services/%s/health?region=%s", base, service, region))
+	}
+	if taskArn != "" {
+		links = append(links, fmt.Sprintf("%s/tasks/%s/configuration?region=%s", base, getResourceName(taskArn), region))
+	}
+	return links
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Only set when AUDIT_LOG_BUCKET is
+var s3Client *s3.Client
+
+// Objects go under alerts/yyyy/mm/dd/, one per invocation that sent
+// anything, so a day's alerts can be listed or expired by prefix
+const auditLogPrefix = "alerts/"
+
+// One line of the audit log: the alert as the JSON webhook gets it, before
+// any per-channel masking or capping, and where it was delivered
+type auditRecord struct {
+	alertDocument
+	EventID   string   `json:"eventId"`
+	Delivered []string `json:"delivered"`
+	Failed    []string `json:"failed"`
+}
+
+// Records held until the invocation ends. S3 objects can't be appended
+// to, so the invocation's alerts are written together.
+var auditBuffer []auditRecord
+
+// Notes an alert notify handled, whatever became of it on the channels
+func recordAudit(alert Alert, delivered, failed []string) {
+	if s3Client == nil {
+		return
+	}
+	auditBuffer = append(auditBuffer, auditRecord{
+		alertDocument: newAlertDocument(alert),
+		EventID:       invocationEventID,
+		Delivered:     append([]string{}, delivered...),
+		Failed:        append([]string{}, failed...),
+	})
+}
+
+// Writes the buffered records as JSON lines to
+// alerts/yyyy/mm/dd/<time>-<event id>.jsonl in AUDIT_LOG_BUCKET. The buffer
+// is emptied either way, so a failed write doesn't spill into the next
+// invocation.
+func flushAuditLog(ctx context.Context) error {
+	records := auditBuffer
+	auditBuffer = nil
+	if s3Client == nil || len(records) == 0 {
+		return nil
+	}
+
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return fmt.Errorf("failed to encode audit record: %v", err)
+		}
+	}
+	now := time.Now().UTC()
+	key := fmt.Sprintf("%s%s/%s-%s.jsonl", auditLogPrefix, now.Format("2006/01/02"), now.Format("150405.000000000"), invocationEventID)
+	_, err := s3Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(cfg.AuditBucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(body.Bytes()),
+		ContentType: aws.String("application/x-ndjson"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write audit log %s: %v", key, err)
+	}
+	slog.Info("Audit log written", "bucket", cfg.AuditBucket, "key", key, "alerts", len(records))
+	return nil
+}
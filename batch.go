@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Reports whether the payload is a batch from an SQS queue, such as one an
+// EventBridge rule delivers to instead of invoking the Lambda directly
+func isSQSPayload(payload json.RawMessage) bool {
+	var probe struct {
+		Records []struct {
+			EventSource string `json:"eventSource"`
+		} `json:"Records"`
+	}
+	return json.Unmarshal(payload, &probe) == nil && len(probe.Records) > 0 && probe.Records[0].EventSource == "aws:sqs"
+}
+
+// An event from an SQS batch and the message that carried it
+type batchEvent struct {
+	messageID string
+	event     events.CloudWatchEvent
+}
+
+// Decodes each message body as an EventBridge event and handles the batch's
+// events one at a time. Only the messages whose event failed are reported
+// back, so SQS delivers those again and deletes the rest: the same-ID checks
+// let a redelivered event through, so failing the whole batch would alert
+// again on the events that already did. A body that isn't an event goes the
+// way of any other malformed event, see rejectUnparseable.
+func handleSQSBatch(ctx context.Context, payload json.RawMessage) (events.SQSEventResponse, error) {
+	var batch events.SQSEvent
+	if err := json.Unmarshal(payload, &batch); err != nil {
+		return events.SQSEventResponse{}, fmt.Errorf("failed to unmarshal SQS batch: %v", err)
+	}
+	var response events.SQSEventResponse
+	fail := func(messageID string, err error) {
+		slog.Error("Error handling SQS message", "message_id", messageID, "error", err)
+		response.BatchItemFailures = append(response.BatchItemFailures, events.SQSBatchItemFailure{ItemIdentifier: messageID})
+	}
+
+	decoded := make([]batchEvent, 0, len(batch.Records))
+	for _, record := range batch.Records {
+		var event events.CloudWatchEvent
+		if err := json.Unmarshal([]byte(record.Body), &event); err != nil {
+			if err := rejectUnparseable(ctx, sqsMessageEvent(record), fmt.Errorf("failed to unmarshal event in SQS message: %v", err)); err != nil {
+				fail(record.MessageId, err)
+			}
+			continue
+		}
+		decoded = append(decoded, batchEvent{messageID: record.MessageId, event: event})
+	}
+	for _, b := range dedupeBatch(decoded) {
+		if err := handleRequest(ctx, b.event); err != nil {
+			fail(b.messageID, err)
+		}
+	}
+	return response, nil
+}
+
+// Wraps a message body that isn't an event, so the DLQ keeps it as it came
+func sqsMessageEvent(record events.SQSMessage) events.CloudWatchEvent {
+	body, _ := json.Marshal(record.Body)
+	return events.CloudWatchEvent{
+		ID:         record.MessageId,
+		DetailType: "SQS Message",
+		Source:     "aws.sqs",
+		Detail:     body,
+	}
+}
+
+// Drops events whose ID already appeared earlier in the same batch, keeping
+// the first copy and the original order. EventBridge can deliver an event
+// more than once and a queue can hand both copies over together; this only
+// looks within one batch, so it needs no state store.
+func dedupeBatch(batch []batchEvent) []batchEvent {
+	seen := make(map[string]bool, len(batch))
+	unique := batch[:0:0]
+	for _, b := range batch {
+		// Without an ID there's nothing to compare, so keep it
+		if b.event.ID != "" && seen[b.event.ID] {
+			slog.Info("Duplicate event in batch skipped", "event_id", b.event.ID, "message_id", b.messageID)
+			continue
+		}
+		seen[b.event.ID] = true
+		unique = append(unique, b)
+	}
+	return unique
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestDedupeBatch(t *testing.T) {
+	var batch []batchEvent
+	for i, id := range []string{"a", "b", "a", "", "", "b", "c"} {
+		batch = append(batch, batchEvent{messageID: strconv.Itoa(i + 1), event: events.CloudWatchEvent{ID: id}})
+	}
+	var got []string
+	for _, b := range dedupeBatch(batch) {
+		got = append(got, b.messageID)
+	}
+	if want := []string{"1", "2", "4", "5", "7"}; !slices.Equal(got, want) {
+		t.Errorf("kept messages %q, want %q", got, want)
+	}
+	if batch[2].event.ID != "a" {
+		t.Error("the input batch was changed")
+	}
+}
+
+// An SQS batch with one message per body, numbered from 1
+func sqsBatch(t *testing.T, bodies ...any) events.SQSEvent {
+	t.Helper()
+	var batch events.SQSEvent
+	for i, body := range bodies {
+		text, ok := body.(string)
+		if !ok {
+			raw, err := json.Marshal(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			text = string(raw)
+		}
+		batch.Records = append(batch.Records, events.SQSMessage{
+			MessageId:   strconv.Itoa(i + 1),
+			EventSource: "aws:sqs",
+			Body:        text,
+		})
+	}
+	return batch
+}
+
+// Delivers the batch as SQS would and returns the messages reported failed
+func deliverSQSBatch(t *testing.T, batch events.SQSEvent) []string {
+	t.Helper()
+	payload, err := json.Marshal(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isSQSPayload(payload) {
+		t.Fatal("the SQS batch was not recognised")
+	}
+	response, err := runHandleInvocation(t, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var failed []string
+	for _, f := range response.(events.SQSEventResponse).BatchItemFailures {
+		failed = append(failed, f.ItemIdentifier)
+	}
+	return failed
+}
+
+func stoppedTaskEvent(t *testing.T) events.CloudWatchEvent {
+	return testEvent(t, "ECS Task State Change", stoppedTask("Essential container in task exited",
+		ContainerInfo{Name: "app", ExitCode: 137, Reason: "OutOfMemoryError"}))
+}
+
+func TestHandleInvocationSQSBatch(t *testing.T) {
+	withConfig(t, nil)
+	sent := captureAlerts(t)
+
+	event := stoppedTaskEvent(t)
+	if failed := deliverSQSBatch(t, sqsBatch(t, event, event)); len(failed) != 0 {
+		t.Errorf("messages %q reported failed", failed)
+	}
+	if len(*sent) != 1 {
+		t.Errorf("sent %d alerts for one event delivered twice, want 1", len(*sent))
+	}
+}
+
+// Only the message that failed comes back, so the event that alerted is
+// not handled again however often SQS redelivers
+func TestHandleInvocationSQSBatchRedelivery(t *testing.T) {
+	withConfig(t, func(c *Config) { c.Cooldown = 10 * time.Minute })
+	sent := captureAlerts(t)
+
+	batch := sqsBatch(t, stoppedTaskEvent(t), "not json")
+	for delivery := 1; delivery <= 3; delivery++ {
+		failed := deliverSQSBatch(t, batch)
+		if !slices.Equal(failed, []string{"2"}) {
+			t.Fatalf("delivery %d: messages %q reported failed, want only the undecodable one", delivery, failed)
+		}
+		// SQS deletes the messages that weren't reported
+		batch.Records = slices.DeleteFunc(batch.Records, func(m events.SQSMessage) bool {
+			return !slices.Contains(failed, m.MessageId)
+		})
+		if len(*sent) != 1 {
+			t.Errorf("delivery %d: %d alerts sent, want 1", delivery, len(*sent))
+		}
+	}
+}
+
+func TestHandleInvocationSQSBatchStrictMode(t *testing.T) {
+	withConfig(t, func(c *Config) { c.StrictMode = true })
+	sent := captureAlerts(t)
+
+	if failed := deliverSQSBatch(t, sqsBatch(t, stoppedTaskEvent(t), "not json")); len(failed) != 0 {
+		t.Errorf("messages %q reported failed, want the undecodable one dropped", failed)
+	}
+	if len(*sent) != 1 {
+		t.Errorf("sent %d alerts, want the decodable event still handled", len(*sent))
+	}
+}
+
+func TestIsSQSPayload(t *testing.T) {
+	for payload, want := range map[string]bool{
+		`{"Records": [{"eventSource": "aws:sqs", "body": "{}"}]}`: true,
+		`{"Records": [{"eventSource": "aws:sns"}]}`:               false,
+		`{"Records": []}`: false,
+		`{"detail-type": "ECS Task State Change", "detail": {}}`: false,
+	} {
+		if got := isSQSPayload(json.RawMessage(payload)); got != want {
+			t.Errorf("isSQSPayload(%s) = %v, want %v", payload, got, want)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// How often Set sweeps out expired entries on its own
+const cacheCleanupInterval = time.Minute
+
+// An in-memory map whose entries expire, safe for concurrent use. It backs
+// the stateful features when no state table is configured, so their state
+// only lasts while Lambda keeps reusing the same container.
+type ttlCache[K comparable, V any] struct {
+	mu          sync.Mutex
+	entries     map[K]ttlEntry[V]
+	lastCleanup time.Time
+	now         func() time.Time // time.Now, replaced in tests
+}
+
+type ttlEntry[V any] struct {
+	value     V
+	expiresAt time.Time
+}
+
+func newTTLCache[K comparable, V any]() *ttlCache[K, V] {
+	return &ttlCache[K, V]{entries: make(map[K]ttlEntry[V]), lastCleanup: time.Now(), now: time.Now}
+}
+
+// Returns the value for key unless it is missing or has expired
+func (c *ttlCache[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok || !c.now().Before(e.expiresAt) {
+		var zero V
+		return zero, false
+	}
+	return e.value, true
+}
+
+// Stores value for key for ttl
+func (c *ttlCache[K, V]) Set(key K, value V, ttl time.Duration) {
+	c.Swap(key, value, ttl)
+}
+
+// Stores value for key for ttl and returns the unexpired value it replaced,
+// as one step so concurrent callers can't both see the same old value
+func (c *ttlCache[K, V]) Swap(key K, value V, ttl time.Duration) (old V, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	if e, found := c.entries[key]; found && now.Before(e.expiresAt) {
+		old, ok = e.value, true
+	}
+	c.entries[key] = ttlEntry[V]{value: value, expiresAt: now.Add(ttl)}
+	if now.Sub(c.lastCleanup) >= cacheCleanupInterval {
+		c.cleanupLocked(now)
+	}
+	return old, ok
+}
+
+func (c *ttlCache[K, V]) Delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
+
+// Returns the keys of the unexpired entries, in no particular order
+func (c *ttlCache[K, V]) Keys() []K {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	keys := make([]K, 0, len(c.entries))
+	for k, e := range c.entries {
+		if now.Before(e.expiresAt) {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// Removes expired entries. Get already ignores them; this only frees the
+// memory, and Set calls it periodically.
+func (c *ttlCache[K, V]) Cleanup() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cleanupLocked(c.now())
+}
+
+func (c *ttlCache[K, V]) cleanupLocked(now time.Time) {
+	for k, e := range c.entries {
+		if !now.Before(e.expiresAt) {
+			delete(c.entries, k)
+		}
+	}
+	c.lastCleanup = now
+}
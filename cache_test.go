@@ -0,0 +1,119 @@
+package main
+
+import (
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A cache whose clock only moves when the test advances it
+func newTestCache() (*ttlCache[string, int], func(time.Duration)) {
+	c := newTTLCache[string, int]()
+	now := time.Date(2026, 3, 14, 9, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+	c.lastCleanup = now
+	return c, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestTTLCacheExpiry(t *testing.T) {
+	c, advance := newTestCache()
+	c.Set("a", 1, time.Minute)
+	c.Set("b", 2, time.Hour)
+
+	advance(59 * time.Second)
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Errorf("Get before expiry = %d, %v", v, ok)
+	}
+	advance(time.Second)
+	if _, ok := c.Get("a"); ok {
+		t.Error("Get found an entry at its expiry time")
+	}
+	if v, ok := c.Get("b"); !ok || v != 2 {
+		t.Errorf("Get of the longer-lived entry = %d, %v", v, ok)
+	}
+	if keys := c.Keys(); !slices.Equal(keys, []string{"b"}) {
+		t.Errorf("Keys = %q, want only the unexpired entry", keys)
+	}
+
+	if old, ok := c.Swap("a", 3, time.Minute); ok {
+		t.Errorf("Swap returned the expired value %d", old)
+	}
+	if old, ok := c.Swap("a", 4, time.Minute); !ok || old != 3 {
+		t.Errorf("Swap = %d, %v; want 3, true", old, ok)
+	}
+	c.Delete("a")
+	if _, ok := c.Get("a"); ok {
+		t.Error("Get found a deleted entry")
+	}
+}
+
+func TestTTLCacheEviction(t *testing.T) {
+	c, advance := newTestCache()
+	c.Set("short", 1, time.Second)
+	c.Set("long", 2, time.Hour)
+	advance(2 * time.Second)
+
+	// Expired entries stay in the map until a cleanup
+	if len(c.entries) != 2 {
+		t.Fatalf("%d entries before cleanup, want 2", len(c.entries))
+	}
+	c.Cleanup()
+	if _, found := c.entries["short"]; found || len(c.entries) != 1 {
+		t.Errorf("entries after Cleanup = %v, want only the long-lived one", c.entries)
+	}
+
+	// Set sweeps on its own once cacheCleanupInterval has passed
+	c.Set("short", 1, time.Second)
+	advance(2 * time.Second)
+	c.Set("other", 3, time.Hour)
+	if _, found := c.entries["short"]; !found {
+		t.Error("Set swept before cacheCleanupInterval")
+	}
+	advance(cacheCleanupInterval)
+	c.Set("other", 3, time.Hour)
+	if _, found := c.entries["short"]; found {
+		t.Error("Set didn't sweep after cacheCleanupInterval")
+	}
+}
+
+func TestTTLCacheConcurrency(t *testing.T) {
+	c := newTTLCache[string, int]()
+	const workers, rounds = 16, 200
+
+	// Every Swap sees the value of exactly one earlier Swap, so across all
+	// workers each value but the last is returned once
+	seen := make([]int, workers*rounds+1)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := range rounds {
+				old, ok := c.Swap("k", w*rounds+r+1, time.Hour)
+				c.Get("k")
+				c.Keys()
+				mu.Lock()
+				if ok {
+					seen[old]++
+				} else {
+					seen[0]++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	last, _ := c.Get("k")
+	for v, n := range seen {
+		want := 1
+		if v == last {
+			want = 0
+		}
+		if n != want {
+			t.Fatalf("value %d returned by Swap %d times, want %d", v, n, want)
+		}
+	}
+}
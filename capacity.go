@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Sent by ECS for service scheduler problems, including tasks it couldn't
+// place; SERVICE_STEADY_STATE arrives the same way
+const serviceActionDetailType = "ECS Service Action"
+
+type ECSServiceActionDetail struct {
+	EventType            string   `json:"eventType"`
+	EventName            string   `json:"eventName"`
+	ClusterArn           string   `json:"clusterArn"`
+	CapacityProviderArns []string `json:"capacityProviderArns"`
+	Reason               string   `json:"reason"`
+}
+
+type capacityFailureKind int
+
+const (
+	capacityNone capacityFailureKind = iota
+	capacityFargate
+	capacityInstances
+	capacityQuota
+)
+
+// Tells the reasons ECS gives for not acquiring capacity apart: placement
+// failure reasons such as "RESOURCE:FARGATE" or "RESOURCE:MEMORY", and the
+// stopped reasons of tasks that failed to start, e.g. "Capacity is
+// unavailable at this time" or "You've reached the limit on the number of
+// vCPUs you can run concurrently". Anything else is capacityNone.
+func classifyCapacityFailure(reason string) capacityFailureKind {
+	r := strings.ToLower(reason)
+	switch {
+	case strings.Contains(r, "reached the limit"), strings.Contains(r, "limitexceeded"):
+		return capacityQuota
+	case r == "resource:fargate", strings.Contains(r, "capacity is unavailable"):
+		return capacityFargate
+	case strings.HasPrefix(r, "resource:"):
+		return capacityInstances
+	}
+	return capacityNone
+}
+
+// What to look at for each kind of capacity failure
+func capacityHint(kind capacityFailureKind) string {
+	switch kind {
+	case capacityFargate:
+		return "Fargate could not provide capacity for the task. Check the Fargate vCPU service quota for the region, " +
+			"and consider spreading the service over more availability zones or adding a FARGATE capacity provider alongside FARGATE_SPOT."
+	case capacityInstances:
+		return "No container instance had room for the task. Check the capacity provider's Auto Scaling group maximum size, " +
+			"managed scaling, and the EC2 instance service quotas."
+	case capacityQuota:
+		return "The account reached an ECS or Fargate limit. Check the service quotas for the region and request an increase."
+	}
+	return ""
+}
+
+func serviceQuotasLink(region string, kind capacityFailureKind) string {
+	service := "fargate"
+	if kind == capacityInstances {
+		service = "ec2"
+	}
+	return fmt.Sprintf("https://%s.console.aws.amazon.com/servicequotas/home/services/%s/quotas?region=%s", region, service, region)
+}
+
+// Builds an alert for a SERVICE_TASK_PLACEMENT_FAILURE that comes down to
+// capacity. ok is false for other service actions, for placement failures
+// with other causes (placement constraints etc.), and with
+// ALERT_ON_CAPACITY_FAILURES off.
+func capacityAlert(event events.CloudWatchEvent) (ok bool, alert Alert, err error) {
+	if !cfg.CapacityAlerts {
+		return false, Alert{}, nil
+	}
+	var detail ECSServiceActionDetail
+	if err := decodeDetail(event.Detail, &detail); err != nil {
+		return false, Alert{}, fmt.Errorf("failed to unmarshal service action detail: %v", err)
+	}
+	if detail.EventName != "SERVICE_TASK_PLACEMENT_FAILURE" {
+		return false, Alert{}, nil
+	}
+	kind := classifyCapacityFailure(detail.Reason)
+	if kind == capacityNone {
+		return false, Alert{}, nil
+	}
+
+	var service string
+	if len(event.Resources) > 0 {
+		service = event.Resources[0]
+	}
+	serviceName := getResourceName(service)
+	cluster := getResourceName(detail.ClusterArn)
+
+	var providers []string
+	for _, arn := range detail.CapacityProviderArns {
+		providers = append(providers, getResourceName(arn))
+	}
+	title := fmt.Sprintf("ECS capacity unavailable: %s", serviceName)
+	if len(providers) > 0 {
+		title = fmt.Sprintf("ECS capacity unavailable on %s: %s", strings.Join(providers, ", "), serviceName)
+	}
+
+	alert = Alert{
+		DetailType: event.DetailType,
+		Resource:   service,
+		Severity:   SeverityWarning,
+		Title:      title,
+		Service:    serviceName,
+		Cluster:    cluster,
+		Fields:     map[string]string{"Event": detail.EventName, "Reason": detail.Reason},
+		Details:    []string{capacityHint(kind)},
+		Links:      ecsConsoleLinks(event.Region, cluster, serviceName, ""),
+		Timestamp:  event.Time,
+	}
+	if len(providers) > 0 {
+		alert.Fields["Capacity Provider"] = strings.Join(providers, ", ")
+	}
+	if event.Region != "" {
+		alert.Links = append(alert.Links, serviceQuotasLink(event.Region, kind))
+	}
+	return true, alert, nil
+}
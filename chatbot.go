@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sns/types"
+)
+
+// Only set when CHATBOT_SNS_TOPIC_ARN or SMS_NUMBERS is configured
+var snsClient *sns.Client
+
+// An AWS Chatbot custom notification. Chatbot picks these up from the SNS
+// topic and posts them to whichever Slack or Teams channels subscribe to it.
+type chatbotNotification struct {
+	Version  string          `json:"version"`
+	Source   string          `json:"source"`
+	Content  chatbotContent  `json:"content"`
+	Metadata chatbotMetadata `json:"metadata"`
+}
+
+type chatbotContent struct {
+	TextType    string   `json:"textType"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	NextSteps   []string `json:"nextSteps,omitempty"`
+	Keywords    []string `json:"keywords,omitempty"`
+}
+
+type chatbotMetadata struct {
+	ThreadID         string   `json:"threadId,omitempty"`
+	Summary          string   `json:"summary"`
+	EventType        string   `json:"eventType,omitempty"`
+	RelatedResources []string `json:"relatedResources,omitempty"`
+}
+
+// Builds the Chatbot custom notification for an alert. Chatbot renders the
+// description as Slack-style markdown and shows the title and next steps
+// on their own, so the console links move to nextSteps.
+func chatbotMessage(alert Alert) chatbotNotification {
+	body := alert
+	body.Links = nil
+
+	keywords := []string{string(alert.Severity)}
+	if alert.Service != "" {
+		keywords = append(keywords, alert.Service)
+	}
+	if alert.Cluster != "" {
+		keywords = append(keywords, alert.Cluster)
+	}
+
+	msg := chatbotNotification{
+		Version: "1.0",
+		Source:  "custom",
+		Content: chatbotContent{
+			TextType:    "client-markdown",
+			Title:       alert.subject(),
+			Description: renderText(body, slackBold),
+			NextSteps:   alert.Links,
+			Keywords:    keywords,
+		},
+		Metadata: chatbotMetadata{
+			// Keeps follow-up alerts for a service in one Slack thread
+			ThreadID:  alert.Service,
+			Summary:   alert.subject(),
+			EventType: alert.DetailType,
+		},
+	}
+	if alert.Resource != "" {
+		msg.Metadata.RelatedResources = []string{alert.Resource}
+	}
+	return msg
+}
+
+// Marshals the notification, cutting the description until it fits in an
+// SNS message. Cutting the JSON itself would leave it unparseable. Escaping
+// can make the description take more room in the JSON than it does raw, so
+// each cut is scaled by how much it grew and checked by marshalling again.
+func chatbotBody(msg chatbotNotification) ([]byte, bool, error) {
+	body, err := json.Marshal(msg)
+	if err != nil || len(body) <= snsMaxMessageBytes {
+		return body, false, err
+	}
+	description := msg.Content.Description
+	msg.Content.Description = ""
+	bare, err := json.Marshal(msg)
+	if err != nil {
+		return nil, false, err
+	}
+	budget := snsMaxMessageBytes - len(bare)
+
+	limit, truncated := len(description), false
+	for len(body) > snsMaxMessageBytes && limit > 0 {
+		if budget <= 0 {
+			limit = 0
+		} else {
+			limit = min(limit-1, limit*budget/(len(body)-len(bare)))
+		}
+		msg.Content.Description, truncated = fitSNSMessage(description, limit)
+		if body, err = json.Marshal(msg); err != nil {
+			return nil, false, err
+		}
+	}
+	return body, truncated, nil
+}
+
+func sendChatbotNotification(ctx context.Context, alert Alert) error {
+	if cfg.ChatbotTopicARN == "" {
+		slog.Info("Chatbot SNS topic not configured, skipping Chatbot notification")
+		return nil
+	}
+
+	body, truncated, err := chatbotBody(chatbotMessage(alert))
+	if err != nil {
+		return err
+	}
+
+	input := &sns.PublishInput{
+		TopicArn: aws.String(cfg.ChatbotTopicARN),
+		Message:  aws.String(string(body)),
+	}
+	if truncated {
+		input.MessageAttributes = map[string]types.MessageAttributeValue{
+			snsTruncatedAttribute: {DataType: aws.String("String"), StringValue: aws.String("true")},
+		}
+	}
+	if _, err := snsClient.Publish(ctx, input); err != nil {
+		return fmt.Errorf("failed to publish Chatbot notification: %v", err)
+	}
+	return nil
+}
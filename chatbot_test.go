@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestChatbotBodyFitsEscapedDescription(t *testing.T) {
+	// Each < is escaped to \u003c, six bytes in the JSON for one in the text
+	description := strings.Repeat("<", snsMaxMessageBytes/2)
+	msg := chatbotMessage(Alert{Title: "Task stopped", Severity: SeverityCritical})
+	msg.Content.Description = description
+
+	body, truncated, err := chatbotBody(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !truncated {
+		t.Error("expected the description to be cut")
+	}
+	if len(body) > snsMaxMessageBytes {
+		t.Errorf("body is %d bytes, over the %d byte limit", len(body), snsMaxMessageBytes)
+	}
+	var decoded chatbotNotification
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		t.Fatalf("body is not valid JSON: %v", err)
+	}
+	if !strings.HasSuffix(decoded.Content.Description, truncatedMarker) {
+		t.Errorf("description does not end with the truncation marker")
+	}
+	if len(decoded.Content.Description) < snsMaxMessageBytes/7 {
+		t.Errorf("description cut to %d bytes, far more than needed", len(decoded.Content.Description))
+	}
+}
+
+func TestChatbotBodyUnderLimit(t *testing.T) {
+	msg := chatbotMessage(Alert{Title: "Task stopped", Severity: SeverityCritical, Details: []string{"exit code 137"}})
+	body, truncated, err := chatbotBody(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if truncated {
+		t.Error("a small notification was marked truncated")
+	}
+	want, _ := json.Marshal(msg)
+	if string(body) != string(want) {
+		t.Errorf("body changed:\n%s\nwant:\n%s", body, want)
+	}
+}
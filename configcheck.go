@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/ses"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+)
+
+// Detail-type of the special event that runs the config check in Lambda, e.g.
+// `aws lambda invoke --payload '{"detail-type":"Config Check"}' ...`
+const configCheckDetailType = "Config Check"
+
+// Writes a summary of the resolved config followed by a pass/fail line per
+// configured channel, and reports whether every check passed. Nothing is
+// posted anywhere a human would see it.
+func runConfigCheck(ctx context.Context, w io.Writer) bool {
+	fmt.Fprintf(w, "ECS alerter %s (commit %s)\n", Version, Commit)
+	fmt.Fprintf(w, "Region:             %s\n", cfg.AWSRegion)
+	fmt.Fprintf(w, "Environment:        %s\n", orNone(cfg.Environment))
+	fmt.Fprintf(w, "Monitored services: %s\n", listOrAll(cfg.MonitoredServices))
+	fmt.Fprintf(w, "State table:        %s\n", orNone(cfg.StateTableName))
+	fmt.Fprintf(w, "Severity routes:    %s\n", describeRoutes())
+	fmt.Fprintf(w, "Slack webhook:      %s\n", maskURL(cfg.SlackWebhookURL))
+	fmt.Fprintf(w, "Security Slack:     %s\n", maskURL(cfg.SecuritySlackURL))
+	fmt.Fprintf(w, "Mattermost webhook: %s\n", maskURL(cfg.MattermostWebhookURL))
+	fmt.Fprintf(w, "Google Chat:        %s\n", maskURL(cfg.GoogleChatWebhookURL))
+	fmt.Fprintf(w, "JSON webhook:       %s\n", maskURL(cfg.JSONWebhookURL))
+	fmt.Fprintf(w, "Chatbot topic:      %s\n", orNone(cfg.ChatbotTopicARN))
+	fmt.Fprintf(w, "PagerDuty:          %s\n", describeEscalation())
+	fmt.Fprintf(w, "Email:              %s -> %s\n", orNone(cfg.SenderEmail), describeEmailRecipients())
+	fmt.Fprintln(w)
+
+	checks := []struct {
+		name       string
+		configured bool
+		enabled    bool
+		check      func(context.Context) error
+	}{
+		{"slack", cfg.SlackWebhookURL != "", cfg.SlackEnabled, checkSlackWebhook},
+		{"security", cfg.SecuritySlackURL != "", cfg.SecuritySlackEnabled, func(ctx context.Context) error { return checkReachable(ctx, cfg.SecuritySlackURL) }},
+		{"mattermost", cfg.MattermostWebhookURL != "", cfg.MattermostEnabled, func(ctx context.Context) error { return checkReachable(ctx, cfg.MattermostWebhookURL) }},
+		{"googlechat", cfg.GoogleChatWebhookURL != "", cfg.GoogleChatEnabled, func(ctx context.Context) error { return checkReachable(ctx, cfg.GoogleChatWebhookURL) }},
+		{"json", cfg.JSONWebhookURL != "", cfg.JSONWebhookEnabled, func(ctx context.Context) error { return checkReachable(ctx, cfg.JSONWebhookURL) }},
+		{"chatbot", cfg.ChatbotTopicARN != "", cfg.ChatbotEnabled, checkChatbotTopic},
+		{"email", cfg.SenderEmail != "" && hasEmailRecipients(), cfg.EmailEnabled, checkSES},
+		{"state", cfg.StateTableName != "", true, checkStateTable},
+	}
+
+	allPassed := true
+	for _, c := range checks {
+		switch {
+		case !c.configured:
+			fmt.Fprintf(w, "SKIP  %-10s not configured\n", c.name)
+		case !c.enabled:
+			fmt.Fprintf(w, "SKIP  %-10s disabled\n", c.name)
+		default:
+			if err := c.check(ctx); err != nil {
+				allPassed = false
+				fmt.Fprintf(w, "FAIL  %-10s %v\n", c.name, err)
+			} else {
+				fmt.Fprintf(w, "PASS  %-10s\n", c.name)
+			}
+		}
+	}
+	return allPassed
+}
+
+// Posts an empty payload, which Slack rejects with "no_text" only when the
+// webhook itself is valid. Nothing shows up in the channel.
+func checkSlackWebhook(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.SlackWebhookURL, strings.NewReader("{}"))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer drainAndClose(resp.Body)
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if strings.TrimSpace(string(body)) != "no_text" {
+		return fmt.Errorf("unexpected response %s: %s", resp.Status, body)
+	}
+	return nil
+}
+
+// Webhooks without a harmless ping: any HTTP response proves DNS, TLS and
+// routing work
+func checkReachable(ctx context.Context, rawURL string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	drainAndClose(resp.Body)
+	return nil
+}
+
+func checkSES(ctx context.Context) error {
+	identities := append([]sesIdentity{primarySESIdentity(ctx)}, sesFallbacks...)
+	var errs []error
+	for _, id := range identities {
+		if _, err := id.client.GetSendQuota(ctx, &ses.GetSendQuotaInput{}); err != nil {
+			errs = append(errs, fmt.Errorf("%s (%s): %v", id.Email, id.Region, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func checkChatbotTopic(ctx context.Context) error {
+	_, err := snsClient.GetTopicAttributes(ctx, &sns.GetTopicAttributesInput{TopicArn: aws.String(cfg.ChatbotTopicARN)})
+	return err
+}
+
+func checkStateTable(ctx context.Context) error {
+	_, err := dynamoClient.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(cfg.StateTableName),
+		Key:       map[string]types.AttributeValue{"pk": attrS("config-check")},
+	})
+	return err
+}
+
+// Keeps only scheme and host so tokens embedded in webhook paths never get printed
+func maskURL(raw string) string {
+	if raw == "" {
+		return "(not set)"
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return "****"
+	}
+	return u.Scheme + "://" + u.Host + "/****"
+}
+
+func describeRoutes() string {
+	if len(cfg.SeverityRoutes) == 0 {
+		return "all channels"
+	}
+	var parts []string
+	for sev, names := range cfg.SeverityRoutes {
+		parts = append(parts, fmt.Sprintf("%s=%s", sev, strings.Join(names, ",")))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, "; ")
+}
+
+func describeEscalation() string {
+	if cfg.PagerDutyRoutingKey == "" {
+		return "(not set)"
+	}
+	if cfg.EscalateAfter <= 0 || cfg.StateTableName == "" {
+		return "routing key set, escalation off"
+	}
+	return fmt.Sprintf("routing key set, escalate after %d failures within %s", cfg.EscalateAfter, cfg.EscalateWindow)
+}
+
+// RECIPIENT_EMAIL, followed by the severities that have their own list
+func describeEmailRecipients() string {
+	desc := orNone(cfg.RecipientEmail)
+	for _, sev := range []Severity{SeverityCritical, SeverityWarning, SeverityInfo} {
+		if list := cfg.SeverityRecipients[sev]; len(list) > 0 {
+			desc += fmt.Sprintf("; %s: %s", sev, strings.Join(list, ", "))
+		}
+	}
+	return desc
+}
+
+func listOrAll(list []string) string {
+	if len(list) == 0 {
+		return "(all)"
+	}
+	return strings.Join(list, ", ")
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "(not set)"
+	}
+	return s
+}
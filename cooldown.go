@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+const cooldownKeyPrefix = "cooldown#"
+
+// Reports whether an alert for key may be sent now, i.e. none was sent in the
+// last COOLDOWN_SECONDS. When allowed, suppressed is how many alerts for the
+// key were held back since the previous one.
+//
+// The first alert of a window claims the key for one cooldown, so it is
+// allowed again if its event is redelivered while later ones are not.
+// Held-back alerts are counted under a separate key that outlives the
+// window, for the next alert to report.
+func checkCooldown(ctx context.Context, key string) (allowed bool, suppressed int64, err error) {
+	if cfg.Cooldown <= 0 {
+		return true, 0, nil
+	}
+	token := eventToken()
+	holder, err := states.Claim(ctx, cooldownKeyPrefix+key, token, cfg.Cooldown)
+	if err != nil {
+		return true, 0, err
+	}
+	heldKey := cooldownKeyPrefix + key + "#held"
+	if holder != token {
+		_, err := states.IncrementSliding(ctx, heldKey, token, cfg.Cooldown+time.Hour)
+		return false, 0, err
+	}
+	suppressed, err = getCount(ctx, heldKey)
+	if err != nil || suppressed == 0 {
+		return true, 0, err
+	}
+	return true, suppressed, states.PutWithTTL(ctx, heldKey, "0", cfg.Cooldown+time.Hour)
+}
@@ -0,0 +1,35 @@
+package main
+
+import "context"
+
+// A failed deployment stops its tasks too, and each of those would alert on
+// its own. When a deployment-failure alert has just gone out for a service,
+// its task failures within TASK_CORRELATION_SECONDS are dropped as
+// part of the same incident.
+const deployFailureKeyPrefix = "deployfail#"
+
+func deployFailureKey(alert Alert) string {
+	return alert.Cluster + "/" + alert.Service
+}
+
+func isDeploymentFailure(alert Alert) bool {
+	return alert.DetailType == "ECS Deployment State Change" && alert.Severity == SeverityCritical
+}
+
+// Remembers that a deployment-failure alert went out for the alert's service
+func recordDeploymentFailure(ctx context.Context, alert Alert) error {
+	if cfg.DeployTaskWindow <= 0 {
+		return nil
+	}
+	return states.PutWithTTL(ctx, deployFailureKeyPrefix+deployFailureKey(alert), "1", cfg.DeployTaskWindow)
+}
+
+// Reports whether a deployment-failure alert went out for the task alert's
+// service within the correlation window
+func followsDeploymentFailure(ctx context.Context, alert Alert) (bool, error) {
+	if cfg.DeployTaskWindow <= 0 {
+		return false, nil
+	}
+	_, ok, err := states.Get(ctx, deployFailureKeyPrefix+deployFailureKey(alert))
+	return ok, err
+}
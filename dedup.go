@@ -0,0 +1,82 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+const defaultDedupKeyTemplate = "{{.Service}}|{{.Cluster}}|{{.Subject}}"
+
+// Values a DEDUP_KEY_TEMPLATE can refer to
+type dedupKeyData struct {
+	Service    string
+	Cluster    string
+	Subject    string
+	Severity   Severity
+	DetailType string
+	ExitCode   int
+}
+
+// Parses DEDUP_KEY_TEMPLATE and dry-runs it so typos in field names fail at
+// init rather than on the first alert
+func parseDedupKeyTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("dedup").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if err := tmpl.Execute(new(strings.Builder), dedupKeyData{}); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// Identifies which alerts count as duplicates of each other for the
+// suppression features
+func dedupKey(alert Alert) string {
+	var b strings.Builder
+	err := cfg.DedupKeyTemplate.Execute(&b, dedupKeyData{
+		Service:    alert.Service,
+		Cluster:    alert.Cluster,
+		Subject:    alert.Title,
+		Severity:   alert.Severity,
+		DetailType: alert.DetailType,
+		ExitCode:   alert.ExitCode,
+	})
+	if err != nil {
+		// Validated at init, so this only happens on a template runtime error
+		return fmt.Sprintf("%s|%s|%s", alert.Service, alert.Cluster, alert.Title)
+	}
+	return b.String()
+}
+
+// With DEDUP_BYPASS_CRITICAL, critical alerts skip cooldown and the
+// per-channel repeat window, so suppression meant for noise never holds
+// back an emergency
+func bypassesDedup(alert Alert) bool {
+	return cfg.DedupBypassCritical && alert.Severity == SeverityCritical
+}
+
+// Cooldown is per service and severity unless a dedup template is set.
+// Alerts that aren't about a service (ACM certificates, RDS instances) are
+// per resource instead, so one certificate's alert doesn't hold back
+// another's.
+func cooldownKey(alert Alert) string {
+	if !cfg.CustomDedupKey {
+		if alert.Service == "" {
+			return alert.Resource + "|" + string(alert.Severity)
+		}
+		return alert.Service + "|" + string(alert.Severity)
+	}
+	return dedupKey(alert) + "|" + string(alert.Severity)
+}
+
+// A short ID for the incident an alert belongs to, the same in every
+// channel, so a Slack message and an email about one failure can be matched
+// up. Alerts with the same dedup key share it.
+func incidentID(alert Alert) string {
+	sum := sha256.Sum256([]byte(dedupKey(alert)))
+	return hex.EncodeToString(sum[:3])
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCooldownKey(t *testing.T) {
+	withConfig(t, nil)
+	certA := Alert{DetailType: acmExpiryDetailType, Resource: "arn:aws:acm:us-east-1:123456789012:certificate/9f8e7d6c", Severity: SeverityWarning}
+	certB := Alert{DetailType: acmExpiryDetailType, Resource: "arn:aws:acm:us-east-1:123456789012:certificate/1a2b3c4d", Severity: SeverityWarning}
+	api := Alert{Service: "api", Resource: testTaskArn, Severity: SeverityCritical}
+	apiOtherTask := Alert{Service: "api", Resource: testTaskArn + "0", Severity: SeverityCritical}
+
+	if cooldownKey(certA) == cooldownKey(certB) {
+		t.Errorf("two certificates share the cooldown key %q", cooldownKey(certA))
+	}
+	if got := cooldownKey(certA); got != certA.Resource+"|warning" {
+		t.Errorf("certificate cooldown key = %q", got)
+	}
+	if cooldownKey(api) != cooldownKey(apiOtherTask) || cooldownKey(api) != "api|critical" {
+		t.Errorf("tasks of one service get keys %q and %q, want api|critical", cooldownKey(api), cooldownKey(apiOtherTask))
+	}
+}
+
+// ACM alerts for different certificates don't hold each other back
+func TestCooldownPerCertificate(t *testing.T) {
+	withConfig(t, func(c *Config) { c.Cooldown = 5 * time.Minute })
+	savedID := invocationEventID
+	t.Cleanup(func() { invocationEventID = savedID })
+
+	for i, cert := range []string{"certificate/9f8e7d6c", "certificate/1a2b3c4d"} {
+		event := testEvent(t, acmExpiryDetailType, ACMExpiryDetail{DaysToExpiry: 20, CommonName: "api.example.com"},
+			"arn:aws:acm:us-east-1:123456789012:"+cert)
+		ok, alert, _, err := shouldAlert(event)
+		if !ok || err != nil {
+			t.Fatalf("%s: shouldAlert = %v, %v", cert, ok, err)
+		}
+		invocationEventID = event.ID + string(rune('a'+i))
+		if skip, err := suppressReason(t.Context(), &alert); skip != "" || err != nil {
+			t.Errorf("%s: skip reason %q (err %v)", cert, skip, err)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const deploymentKeyPrefix = "deploy#"
+
+// Per-service record of the deployment in progress, for SUPPRESS_DURING_DEPLOY
+const activeDeployKeyPrefix = "activedeploy#"
+
+// A service's current deployment. EndedAt is zero until it completes or fails.
+type activeDeployment struct {
+	StartedAt time.Time `json:"startedAt"`
+	EndedAt   time.Time `json:"endedAt,omitzero"`
+}
+
+// A deployment in progress, for the stuck deployment sweep
+type deploymentRecord struct {
+	Service   string    `json:"service"`
+	Cluster   string    `json:"cluster"`
+	StartedAt time.Time `json:"startedAt"`
+	Alerted   bool      `json:"alerted,omitempty"`
+}
+
+// Per deployment and event name, for DEPLOYMENT_DEDUP_SECONDS
+const deployEventKeyPrefix = "deployevent#"
+
+// Reports whether ECS already sent this event for this deployment within
+// DEPLOYMENT_DEDUP_SECONDS, as it sometimes sends one transition twice.
+// Unlike cooldown this holds back only exact repeats, critical ones too.
+func isDuplicateDeploymentEvent(ctx context.Context, alert Alert) (bool, error) {
+	if cfg.DeployDedupWindow <= 0 || alert.Deployment == "" || alert.Fields["Event"] == "" {
+		return false, nil
+	}
+	// Claimed by the first event, which stays the original on redelivery
+	token := eventToken()
+	holder, err := states.Claim(ctx, deployEventKeyPrefix+alert.Deployment+"/"+alert.Fields["Event"], token, cfg.DeployDedupWindow)
+	if err != nil {
+		return false, err
+	}
+	return holder != token, nil
+}
+
+// Matches deployFailureKey, so task alerts find their service's deployments
+func activeDeployKey(detail ECSDeplomentDetail) string {
+	return getResourceName(detail.Cluster) + "/" + getResourceName(detail.Service)
+}
+
+// Records when a deployment started so the scheduled sweep can spot ones that
+// never finish, and which services are deploying. Finished deployments are
+// removed again.
+func trackDeploymentEvent(ctx context.Context, event events.CloudWatchEvent) error {
+	detail, err := parseDeploymentDetail(event)
+	if err != nil || detail.DeploymentID == "" {
+		return err
+	}
+	if err := trackActiveDeployment(ctx, event, detail); err != nil {
+		return err
+	}
+	if !durableStates {
+		return nil
+	}
+	key := deploymentKeyPrefix + detail.DeploymentID
+
+	switch detail.EventName {
+	case "SERVICE_DEPLOYMENT_IN_PROGRESS":
+		// ECS can re-emit IN_PROGRESS; keep the original start time
+		var d deploymentRecord
+		if found, err := getRecord(ctx, key, &d); err != nil || found {
+			return err
+		}
+		startedAt := event.Time
+		if startedAt.IsZero() {
+			startedAt = time.Now()
+		}
+		return putRecord(ctx, key, deploymentRecord{Service: detail.Service, Cluster: detail.Cluster, StartedAt: startedAt}, stateTTL)
+
+	case "SERVICE_DEPLOYMENT_COMPLETED", "SERVICE_DEPLOYMENT_FAILED":
+		return states.Delete(ctx, key)
+	}
+	return nil
+}
+
+// Keeps the per-service record behind isDeploying up to date: started on
+// IN_PROGRESS, ended (but kept for DEPLOY_GRACE_SECONDS) on COMPLETED or FAILED
+func trackActiveDeployment(ctx context.Context, event events.CloudWatchEvent, detail ECSDeplomentDetail) error {
+	if !cfg.SuppressDuringDeploy {
+		return nil
+	}
+	key := activeDeployKeyPrefix + activeDeployKey(detail)
+
+	switch detail.EventName {
+	case "SERVICE_DEPLOYMENT_IN_PROGRESS":
+		startedAt := event.Time
+		if startedAt.IsZero() {
+			startedAt = time.Now()
+		}
+		return putRecord(ctx, key, activeDeployment{StartedAt: startedAt}, cfg.DeployTimeout+cfg.DeployGrace)
+
+	case "SERVICE_DEPLOYMENT_COMPLETED", "SERVICE_DEPLOYMENT_FAILED":
+		var d activeDeployment
+		found, err := getRecord(ctx, key, &d)
+		if err != nil || !found {
+			// Started before tracking was on; nothing to end
+			return err
+		}
+		d.EndedAt = time.Now()
+		return putRecord(ctx, key, d, cfg.DeployGrace)
+	}
+	return nil
+}
+
+// Reports whether the task alert's service has a deployment in progress, or
+// finished one less than DEPLOY_GRACE_SECONDS ago. Deployments stop the old
+// tasks on purpose, and blue/green ones stop a whole task set at once.
+// Deployments running longer than DEPLOY_TIMEOUT_MINUTES no longer count:
+// the stuck deployment sweep reports those.
+func isDeploying(ctx context.Context, alert Alert) (bool, error) {
+	if !cfg.SuppressDuringDeploy {
+		return false, nil
+	}
+	var d activeDeployment
+	found, err := getRecord(ctx, activeDeployKeyPrefix+alert.Cluster+"/"+alert.Service, &d)
+	if err != nil || !found {
+		return false, err
+	}
+
+	if !d.EndedAt.IsZero() {
+		return time.Since(d.EndedAt) < cfg.DeployGrace, nil
+	}
+	return time.Since(d.StartedAt) < cfg.DeployTimeout, nil
+}
+
+// Runs on the schedule rule and alerts once for every deployment that has
+// been in progress longer than DEPLOY_TIMEOUT_MINUTES.
+func sweepStuckDeployments(ctx, sendCtx context.Context) error {
+	if !durableStates {
+		slog.Info("State table not configured, skipping stuck deployment sweep")
+		return nil
+	}
+	keys, err := states.Keys(ctx, deploymentKeyPrefix)
+	if err != nil {
+		return fmt.Errorf("failed to list deployments: %v", err)
+	}
+	cutoff := time.Now().Add(-cfg.DeployTimeout)
+
+	for _, key := range keys {
+		var d deploymentRecord
+		found, err := getRecord(ctx, key, &d)
+		if err != nil {
+			slog.Error("Error reading deployment", "key", key, "error", err)
+			continue
+		}
+		if !found || d.Alerted || !d.StartedAt.Before(cutoff) {
+			continue
+		}
+		serviceName := getResourceName(d.Service)
+		// The key is the deployment ID
+		deployment := key[len(deploymentKeyPrefix):]
+
+		if isMonitored(serviceName) {
+			notify(sendCtx, Alert{
+				Severity:   SeverityWarning,
+				Title:      fmt.Sprintf("ECS Deployment Stuck: %s", serviceName),
+				Service:    serviceName,
+				Cluster:    getResourceName(d.Cluster),
+				Deployment: deployment,
+				Fields: map[string]string{
+					"Deployment":      deployment,
+					"In Progress For": time.Since(d.StartedAt).Round(time.Minute).String(),
+				},
+				Timestamp: time.Now(),
+			})
+		}
+
+		// Alert once per deployment; the record is removed when it finally completes or fails
+		d.Alerted = true
+		if err := putRecord(ctx, key, d, stateTTL); err != nil {
+			slog.Error("Error marking deployment as alerted", "key", key, "error", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestIsDeploying(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.SuppressDuringDeploy = true
+		c.DeployTimeout = 30 * time.Minute
+		c.DeployGrace = 2 * time.Minute
+	})
+	ctx := context.Background()
+	api := Alert{Cluster: "prod", Service: "api"}
+	deploy := func(eventName string) {
+		t.Helper()
+		event := deploymentEvent(t, eventName, "")
+		event.Time = time.Now()
+		if err := trackDeploymentEvent(ctx, event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	deploying := func(alert Alert) bool {
+		t.Helper()
+		ok, err := isDeploying(ctx, alert)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ok
+	}
+
+	if deploying(api) {
+		t.Error("deploying before any deployment event")
+	}
+	deploy("SERVICE_DEPLOYMENT_IN_PROGRESS")
+	if !deploying(api) {
+		t.Error("not deploying after IN_PROGRESS")
+	}
+	if deploying(Alert{Cluster: "prod", Service: "worker"}) {
+		t.Error("another service counts as deploying")
+	}
+	deploy("SERVICE_DEPLOYMENT_COMPLETED")
+	if !deploying(api) {
+		t.Error("not deploying within DEPLOY_GRACE_SECONDS of completing")
+	}
+
+	// A deployment that started longer than DEPLOY_TIMEOUT_MINUTES ago is
+	// left to the stuck deployment sweep
+	old := deploymentEvent(t, "SERVICE_DEPLOYMENT_IN_PROGRESS", "")
+	old.Time = time.Now().Add(-time.Hour)
+	if err := trackDeploymentEvent(ctx, old); err != nil {
+		t.Fatal(err)
+	}
+	if deploying(api) {
+		t.Error("a deployment past DEPLOY_TIMEOUT_MINUTES still counts")
+	}
+}
+
+func TestSweepStuckDeployments(t *testing.T) {
+	withConfig(t, func(c *Config) { c.DeployTimeout = 30 * time.Minute })
+	sent := captureAlerts(t)
+	ctx := context.Background()
+
+	// Started at testEventTime, long before DEPLOY_TIMEOUT_MINUTES ago
+	if err := trackDeploymentEvent(ctx, deploymentEvent(t, "SERVICE_DEPLOYMENT_IN_PROGRESS", "")); err != nil {
+		t.Fatal(err)
+	}
+	// A repeated IN_PROGRESS keeps the original start time
+	again := deploymentEvent(t, "SERVICE_DEPLOYMENT_IN_PROGRESS", "")
+	again.Time = time.Now()
+	if err := trackDeploymentEvent(ctx, again); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sweepStuckDeployments(ctx, ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(*sent) != 1 {
+		t.Fatalf("sweep sent %d alerts, want 1", len(*sent))
+	}
+	if got := (*sent)[0]; got.Title != "ECS Deployment Stuck: api" || got.Deployment != "ecs-svc/4271158118824739872" {
+		t.Errorf("alert = %q for %q", got.Title, got.Deployment)
+	}
+
+	if err := sweepStuckDeployments(ctx, ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(*sent) != 1 {
+		t.Errorf("second sweep alerted again")
+	}
+
+	if err := trackDeploymentEvent(ctx, deploymentEvent(t, "SERVICE_DEPLOYMENT_COMPLETED", "")); err != nil {
+		t.Fatal(err)
+	}
+	if keys, _ := states.Keys(ctx, deploymentKeyPrefix); len(keys) != 0 {
+		t.Errorf("completed deployment still tracked: %q", keys)
+	}
+}
+
+func TestDuplicateDeploymentEvents(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.DeployDedupWindow = time.Minute
+		c.AlertOnDeploySuccess = true
+	})
+	ctx := context.Background()
+	savedID := invocationEventID
+	t.Cleanup(func() { invocationEventID = savedID })
+
+	rollback := "ECS deployment circuit breaker: rolling back to deploymentId ecs-svc/1234567890."
+	other := deploymentEvent(t, "SERVICE_DEPLOYMENT_FAILED", rollback)
+	other.Detail = []byte(`{"eventName": "SERVICE_DEPLOYMENT_FAILED", "deploymentId": "ecs-svc/9999999999999999999", "clusterArn": "` + testClusterArn + `"}`)
+
+	steps := []struct {
+		name     string
+		eventID  string
+		event    events.CloudWatchEvent
+		wantSkip string
+	}{
+		{"first FAILED", "3f1f0c1e-0001", deploymentEvent(t, "SERVICE_DEPLOYMENT_FAILED", rollback), ""},
+		{"same transition sent again", "3f1f0c1e-0002", deploymentEvent(t, "SERVICE_DEPLOYMENT_FAILED", rollback), "deployment event already received"},
+		{"and a third time", "3f1f0c1e-0003", deploymentEvent(t, "SERVICE_DEPLOYMENT_FAILED", rollback), "deployment event already received"},
+		{"another event of the deployment", "3f1f0c1e-0004", deploymentEvent(t, "SERVICE_DEPLOYMENT_COMPLETED", ""), ""},
+		{"another deployment", "3f1f0c1e-0005", other, ""},
+	}
+	for _, s := range steps {
+		ok, alert, _, err := shouldAlert(s.event)
+		if !ok || err != nil {
+			t.Fatalf("%s: shouldAlert = %v, %v", s.name, ok, err)
+		}
+		invocationEventID = s.eventID
+		if skip, err := suppressReason(ctx, &alert); skip != s.wantSkip || err != nil {
+			t.Errorf("%s: skip reason %q (err %v), want %q", s.name, skip, err, s.wantSkip)
+		}
+	}
+
+	// Without DEPLOYMENT_DEDUP_SECONDS nothing is held back
+	cfg.DeployDedupWindow = 0
+	alert := Alert{Deployment: "ecs-svc/4271158118824739872", Fields: map[string]string{"Event": "SERVICE_DEPLOYMENT_FAILED"}}
+	if dup, _ := isDuplicateDeploymentEvent(ctx, alert); dup {
+		t.Error("duplicate reported with DEPLOYMENT_DEDUP_SECONDS off")
+	}
+}
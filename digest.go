@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// With EMAIL_DIGEST, email alerts are collected into buckets of
+// EMAIL_DIGEST_SECONDS instead of being sent one by one; other channels
+// still get each alert straight away
+const emailDigestKeyPrefix = "emaildigest#"
+
+// One alert held for a digest, rendered as the email would have shown it
+type digestEntry struct {
+	Subject  string   `json:"subject"`
+	Body     string   `json:"body"`
+	Severity Severity `json:"severity"`
+	Banner   string   `json:"banner,omitempty"`
+}
+
+// Holds an alert for the digest of the bucket it falls in. notify has
+// already routed, masked and capped it, so the rendered text is stored as
+// the email would have shown it.
+func queueEmailDigest(ctx context.Context, alert Alert) error {
+	start := time.Now().Truncate(cfg.EmailDigestWindow)
+	// The banner heads the digest once rather than every entry
+	entry := digestEntry{Severity: alert.Severity, Banner: alert.Banner}
+	alert.Banner = ""
+	entry.Subject, entry.Body = alert.subject(), renderEmailBody(alert)
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	key := emailDigestKeyPrefix + strconv.FormatInt(start.Unix(), 10)
+	_, err = states.Append(ctx, key, string(raw), cfg.EmailDigestWindow+stateTTL)
+	return err
+}
+
+// Runs on the schedule rule: sends one email for every bucket that has
+// closed. A bucket is sent on the first sweep after it ends, so an alert
+// reaches the inbox at most one bucket plus one schedule period late.
+func flushEmailDigests(ctx, sendCtx context.Context) error {
+	if !cfg.EmailDigest || !durableStates {
+		return nil
+	}
+	keys, err := states.Keys(ctx, emailDigestKeyPrefix)
+	if err != nil {
+		return fmt.Errorf("failed to list email digests: %v", err)
+	}
+	cutoff := time.Now().Add(-cfg.EmailDigestWindow)
+
+	for _, key := range keys {
+		unix, err := strconv.ParseInt(key[len(emailDigestKeyPrefix):], 10, 64)
+		if err != nil {
+			slog.Warn("Skipping email digest with an unreadable key", "key", key)
+			continue
+		}
+		start := time.Unix(unix, 0)
+		if start.After(cutoff) {
+			continue
+		}
+		raw, err := states.List(ctx, key)
+		if err != nil {
+			slog.Error("Error reading email digest", "key", key, "error", err)
+			continue
+		}
+		// Delete first so the bucket is never mailed twice
+		if err := states.Delete(ctx, key); err != nil {
+			slog.Error("Error closing email digest", "key", key, "error", err)
+			continue
+		}
+
+		digest, ok := emailDigest(start, raw)
+		if !ok {
+			continue
+		}
+		if err := sendEmail(sendCtx, digest); err != nil {
+			slog.Error("Error sending email digest", "key", key, "alerts", len(digest.Details), "error", err)
+			reportSendFailure(sendCtx, "email", digest, err)
+		}
+	}
+	return nil
+}
+
+// Builds the digest email for a bucket: the subject counts the alerts and
+// takes the most severe one's severity, the body lists each alert in full
+func emailDigest(start time.Time, raw []string) (Alert, bool) {
+	digest := Alert{
+		DetailType: "Email Digest",
+		Severity:   SeverityInfo,
+		Timestamp:  start,
+	}
+	for _, r := range raw {
+		var entry digestEntry
+		if err := json.Unmarshal([]byte(r), &entry); err != nil {
+			slog.Warn("Skipping unreadable email digest entry", "error", err)
+			continue
+		}
+		if severityRank(entry.Severity) > severityRank(digest.Severity) {
+			digest.Severity = entry.Severity
+		}
+		if entry.Banner != "" {
+			digest.Banner = entry.Banner
+		}
+		body := strings.ReplaceAll(entry.Body, "\n", "\n  ")
+		digest.Details = append(digest.Details, entry.Subject+"\n  "+body)
+	}
+	if len(digest.Details) == 0 {
+		return Alert{}, false
+	}
+	digest.Title = fmt.Sprintf("%d alerts in the %s from %s", len(digest.Details), cfg.EmailDigestWindow, start.UTC().Format(time.RFC3339))
+	return digest, true
+}
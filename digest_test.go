@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEmailDigest(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.EmailDigest = true
+		c.EmailDigestWindow = time.Hour
+	})
+	ctx := context.Background()
+
+	alerts := []Alert{
+		{DetailType: "ECS Task State Change", Severity: SeverityWarning, Title: "ECS Task Failure: api", Banner: "staging", Timestamp: testEventTime},
+		{DetailType: "ECS Task State Change", Severity: SeverityCritical, Title: "ECS Task Failure: worker", Banner: "staging", Timestamp: testEventTime},
+	}
+	for _, a := range alerts {
+		if err := queueEmailDigest(ctx, a); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	start := time.Now().Truncate(cfg.EmailDigestWindow)
+	key := emailDigestKeyPrefix + strconv.FormatInt(start.Unix(), 10)
+	raw, _ := states.List(ctx, key)
+	digest, ok := emailDigest(start, raw)
+	if !ok {
+		t.Fatalf("no digest for %d queued alerts", len(raw))
+	}
+	if !strings.HasPrefix(digest.Title, "2 alerts in the 1h0m0s from ") {
+		t.Errorf("title = %q", digest.Title)
+	}
+	if digest.Severity != SeverityCritical || digest.Banner != "staging" {
+		t.Errorf("severity %q banner %q, want critical and the alerts' banner", digest.Severity, digest.Banner)
+	}
+	if len(digest.Details) != 2 || !strings.Contains(digest.Details[1], "ECS Task Failure: worker") {
+		t.Errorf("details = %q", digest.Details)
+	}
+	for _, d := range digest.Details {
+		if strings.Contains(d, "staging") {
+			t.Errorf("banner repeated in an entry: %q", d)
+		}
+	}
+
+	// The bucket is still open, so the sweep leaves it alone
+	if err := flushEmailDigests(ctx, ctx); err != nil {
+		t.Fatal(err)
+	}
+	if held, _ := states.List(ctx, key); len(held) != 2 {
+		t.Errorf("flush took an open bucket, %d alerts left", len(held))
+	}
+
+	if _, ok := emailDigest(start, nil); ok {
+		t.Error("digest built for an empty bucket")
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// Only set when DLQ_SQS_URL is configured
+var sqsClient *sqs.Client
+
+// Parks an event that can't be parsed on the DLQ_SQS_URL queue, with the
+// parse error as a message attribute, so it can be inspected and replayed
+// later instead of being retried by Lambda until it is dropped.
+func sendToDLQ(ctx context.Context, event events.CloudWatchEvent, cause error) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = sqsClient.SendMessage(ctx, &sqs.SendMessageInput{
+		QueueUrl:    aws.String(cfg.DLQQueueURL),
+		MessageBody: aws.String(string(body)),
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			"error": {
+				DataType:    aws.String("String"),
+				StringValue: aws.String(cause.Error()),
+			},
+			"detailType": {
+				DataType:    aws.String("String"),
+				StringValue: aws.String(event.DetailType),
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	slog.Warn("Unparseable event sent to DLQ", "detail_type", event.DetailType, "error", cause)
+	return nil
+}
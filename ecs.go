@@ -0,0 +1,233 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func parseDeploymentDetail(event events.CloudWatchEvent) (ECSDeplomentDetail, error) {
+	var detail ECSDeplomentDetail
+	if err := decodeDetail(event.Detail, &detail); err != nil {
+		return detail, fmt.Errorf("failed to unmarshal deployment detail: %v", err)
+	}
+	// ECS puts the service ARN in resources rather than the detail
+	if detail.Service == "" && len(event.Resources) > 0 {
+		detail.Service = event.Resources[0]
+	}
+	return detail, nil
+}
+
+func deploymentAlert(event events.CloudWatchEvent) (ok bool, alert Alert, err error) {
+	detail, err := parseDeploymentDetail(event)
+	if err != nil {
+		return false, Alert{}, err
+	}
+	serviceName := getResourceName(detail.Service)
+	cluster := getResourceName(detail.Cluster)
+
+	alert = Alert{
+		DetailType: event.DetailType,
+		Resource:   detail.Service,
+		Service:    serviceName,
+		Cluster:    cluster,
+		Deployment: detail.DeploymentID,
+		Fields:     map[string]string{"Event": detail.EventName, "Reason": detail.Reason},
+		Links:      ecsConsoleLinks(event.Region, cluster, serviceName, ""),
+		Timestamp:  event.Time,
+	}
+	switch detail.EventName {
+	case "SERVICE_DEPLOYMENT_COMPLETED":
+		if !cfg.AlertOnDeploySuccess {
+			return false, Alert{}, nil
+		}
+		alert.Severity = SeverityInfo
+		alert.Title = fmt.Sprintf("Deployment succeeded: %s", serviceName)
+		alert.Fields["Deployment"] = detail.DeploymentID
+	case "SERVICE_DEPLOYMENT_IN_PROGRESS":
+		// Only forwarded so stuck deployments can be tracked, not alert-worthy on its own
+		return false, Alert{}, nil
+	case "SERVICE_DEPLOYMENT_FAILED":
+		alert.Severity = SeverityCritical
+		alert.Title = fmt.Sprintf("ECS Service Rollback/Failure: %s", serviceName)
+
+		switch kind, rolledBackTo := classifyDeploymentFailure(detail.Reason); kind {
+		case failureCircuitBreakerRollback:
+			alert.Title = fmt.Sprintf("Deployment circuit breaker triggered a rollback: %s", serviceName)
+			alert.Fields["Rolled Back From"] = detail.DeploymentID
+			if rolledBackTo != "" {
+				alert.Fields["Rolled Back To"] = rolledBackTo
+			}
+		case failureCircuitBreaker:
+			alert.Title = fmt.Sprintf("Deployment circuit breaker stopped deployment: %s", serviceName)
+			alert.Fields["Deployment"] = detail.DeploymentID
+		}
+	default:
+		alert.Severity = SeverityWarning
+		alert.Title = "ECS Deployment Alert"
+	}
+	return true, alert, nil
+}
+
+type deploymentFailureKind int
+
+const (
+	failureGeneric deploymentFailureKind = iota
+	failureCircuitBreaker
+	failureCircuitBreakerRollback
+)
+
+// e.g. "ECS deployment circuit breaker: rolling back to deploymentId ecs-svc/1234567890."
+var rollbackTargetRe = regexp.MustCompile(`rolling back to deploymentId (\S+?)\.?$`)
+
+// Tells circuit breaker failures (and the rollbacks they trigger) apart from
+// other deployment failures by their reason text. rolledBackTo is the
+// deployment ECS is returning to, when the reason names one.
+func classifyDeploymentFailure(reason string) (kind deploymentFailureKind, rolledBackTo string) {
+	if !strings.Contains(strings.ToLower(reason), "circuit breaker") {
+		return failureGeneric, ""
+	}
+	if m := rollbackTargetRe.FindStringSubmatch(reason); m != nil {
+		return failureCircuitBreakerRollback, m[1]
+	}
+	if strings.Contains(strings.ToLower(reason), "rolling back") || strings.Contains(strings.ToLower(reason), "rollback") {
+		return failureCircuitBreakerRollback, ""
+	}
+	return failureCircuitBreaker, ""
+}
+
+func taskAlert(event events.CloudWatchEvent) (ok bool, alert Alert, err error) {
+	var detail ECSTaskDetail
+	if err := decodeDetail(event.Detail, &detail); err != nil {
+		return false, Alert{}, fmt.Errorf("failed to unmarshal task detail: %v", err)
+	}
+	serviceName := getServiceNameFromGroup(detail.Group)
+
+	// Running tasks only alert when a container's health check fails
+	if detail.LastStatus == "RUNNING" {
+		return unhealthyTaskAlert(event, detail)
+	}
+
+	// We only care if the task STOPPED and it wasn't a manual stop (exit code != 0)
+	if detail.LastStatus != "STOPPED" {
+		return false, Alert{}, nil
+	}
+
+	var failed []ContainerInfo
+	exitCode := 0
+	forced := false
+	for _, c := range detail.Containers {
+		// FORCE_ALERT_REASONS (e.g. OutOfMemoryError) alert before any
+		// container filter gets a say
+		if isForcedStopReason(c.Reason) {
+			forced = true
+			failed = append(failed, c)
+			if exitCode == 0 {
+				exitCode = c.ExitCode
+			}
+			continue
+		}
+		// Sidecars (log routers etc.) often exit nonzero on teardown
+		if contains(cfg.IgnoredContainers, c.Name) {
+			continue
+		}
+		// A failing health check matters even when the container then
+		// exited cleanly
+		if alertsOnExitCode(c.ExitCode) || c.HealthStatus == "UNHEALTHY" {
+			failed = append(failed, c)
+			if exitCode == 0 {
+				exitCode = c.ExitCode
+			}
+		}
+	}
+
+	var failureDetails []string
+	for _, c := range sortContainersByExitCode(failed) {
+		line := fmt.Sprintf("Container '%s' exited with code %d (%s)", c.Name, c.ExitCode, c.Reason)
+		if c.HealthStatus == "UNHEALTHY" {
+			line += ", health check: UNHEALTHY"
+		}
+		if tag := imageTag(c.Image); tag != "" {
+			line += fmt.Sprintf(", deployed commit: %s", tag)
+		}
+		failureDetails = append(failureDetails, line)
+	}
+
+	// Also catch tasks that failed to start (no exit code, but stopped reason exists)
+	// Filter out normal scaling down events, unless FORCE_ALERT_REASONS names the reason
+	if len(failureDetails) == 0 && detail.StoppedReason != "" {
+		if isForcedStopReason(detail.StoppedReason) {
+			forced = true
+		}
+		if forced || !isScalingStopReason(detail.StoppedReason) {
+			failureDetails = append(failureDetails, fmt.Sprintf("Task stopped: %s", detail.StoppedReason))
+		}
+	}
+
+	if len(failureDetails) == 0 {
+		return false, Alert{}, nil
+	}
+
+	// Services start their tasks with the deployment ID; RunTask callers
+	// can put anything there
+	deployment := ""
+	if strings.HasPrefix(detail.StartedBy, "ecs-svc/") {
+		deployment = detail.StartedBy
+	}
+
+	cluster := getResourceName(detail.ClusterArn)
+	alert = Alert{
+		DetailType: event.DetailType,
+		Resource:   detail.TaskArn,
+		ExitCode:   exitCode,
+		Forced:     forced,
+		Severity:   SeverityCritical,
+		Title:      fmt.Sprintf("ECS Task Failure: %s", serviceName),
+		Service:    serviceName,
+		Cluster:    cluster,
+		Fields:     map[string]string{"Task ARN": detail.TaskArn},
+		Details:    failureDetails,
+		Links:      ecsConsoleLinks(event.Region, cluster, serviceName, detail.TaskArn),
+		Timestamp:  event.Time,
+		Deployment: deployment,
+		TaskFamily: taskFamily(detail.TaskDefinitionArn),
+	}
+	// Tasks that never got capacity say so in the stopped reason
+	if kind := classifyCapacityFailure(detail.StoppedReason); kind != capacityNone && cfg.CapacityAlerts {
+		alert.Details = append(alert.Details, capacityHint(kind))
+		if detail.CapacityProviderName != "" {
+			alert.Fields["Capacity Provider"] = detail.CapacityProviderName
+		}
+		if event.Region != "" {
+			alert.Links = append(alert.Links, serviceQuotasLink(event.Region, kind))
+		}
+	}
+	return true, alert, nil
+}
+
+// Orders failed containers by exit code, then name, so the lines kept under
+// MAX_CONTAINER_LINES are the same whatever order ECS listed them in
+func sortContainersByExitCode(containers []ContainerInfo) []ContainerInfo {
+	sorted := slices.Clone(containers)
+	slices.SortStableFunc(sorted, func(a, b ContainerInfo) int {
+		if c := cmp.Compare(a.ExitCode, b.ExitCode); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Name, b.Name)
+	})
+	return sorted
+}
+
+// Nonzero exit codes alert unless they fall below MIN_ALERT_EXIT_CODE, which
+// lets teams reserve low codes for expected exits. This only affects
+// container exit codes; stopped-reason detection is unchanged.
+func alertsOnExitCode(code int) bool {
+	if code == 0 {
+		return false
+	}
+	return code < 0 || code >= cfg.MinAlertExitCode
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTaskAlertIgnoredContainers(t *testing.T) {
+	app := func(code int) ContainerInfo {
+		return ContainerInfo{Name: "app", Image: "repo/api:3f2c1ab", ExitCode: code, Reason: "Essential container exited"}
+	}
+	router := func(code int) ContainerInfo {
+		return ContainerInfo{Name: "log-router", Image: "amazon/aws-for-fluent-bit:2.32", ExitCode: code}
+	}
+	xray := ContainerInfo{Name: "xray-daemon", ExitCode: 2}
+
+	tests := []struct {
+		name       string
+		ignored    []string
+		containers []ContainerInfo
+		wantOK     bool
+		wantNames  []string // containers named in the alert
+		wantAbsent []string // and those that must not be
+	}{
+		{
+			name:       "ignored sidecar fails, main container clean",
+			ignored:    []string{"log-router"},
+			containers: []ContainerInfo{app(0), router(1)},
+		},
+		{
+			name:       "ignored sidecar and main container both fail",
+			ignored:    []string{"log-router"},
+			containers: []ContainerInfo{app(137), router(1)},
+			wantOK:     true, wantNames: []string{"'app'"}, wantAbsent: []string{"log-router"},
+		},
+		{
+			name:       "several sidecars ignored, one not",
+			ignored:    []string{"log-router", "envoy"},
+			containers: []ContainerInfo{app(0), router(1), xray},
+			wantOK:     true, wantNames: []string{"'xray-daemon'"}, wantAbsent: []string{"log-router", "'app'"},
+		},
+		{
+			name:       "sidecar alerts when not ignored",
+			containers: []ContainerInfo{app(0), router(1)},
+			wantOK:     true, wantNames: []string{"'log-router'"},
+		},
+		{
+			name:       "main container named in the list",
+			ignored:    []string{"app", "log-router"},
+			containers: []ContainerInfo{app(1), router(1)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withConfig(t, func(c *Config) { c.IgnoredContainers = tt.ignored })
+			ok, alert, err := taskAlert(testEvent(t, "ECS Task State Change", stoppedTask("", tt.containers...)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != tt.wantOK {
+				t.Fatalf("ok = %v, want %v (details %q)", ok, tt.wantOK, alert.Details)
+			}
+			details := strings.Join(alert.Details, "\n")
+			for _, name := range tt.wantNames {
+				if !strings.Contains(details, name) {
+					t.Errorf("details %q don't name %s", details, name)
+				}
+			}
+			for _, name := range tt.wantAbsent {
+				if strings.Contains(details, name) {
+					t.Errorf("details %q name %s", details, name)
+				}
+			}
+		})
+	}
+}
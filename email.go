@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ses"
+	"github.com/aws/aws-sdk-go-v2/service/ses/types"
+	"github.com/aws/smithy-go"
+)
+
+// A verified SES sender identity and the client for the region it lives in
+type sesIdentity struct {
+	Email  string
+	Region string
+	client *ses.Client
+}
+
+// Secondary identities tried, in order, when the primary can't send
+var sesFallbacks []sesIdentity
+
+// Parses SENDER_EMAIL_FALLBACKS, e.g. "alerts@example.com:us-west-2,alerts@example.org:eu-west-1"
+func parseSESFallbacks(spec string, awsCfg aws.Config) ([]sesIdentity, error) {
+	var identities []sesIdentity
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		email, region, ok := strings.Cut(entry, ":")
+		if !ok || email == "" || region == "" {
+			return nil, fmt.Errorf("entry %q is not email:region", entry)
+		}
+		identities = append(identities, sesIdentity{
+			Email:  email,
+			Region: region,
+			client: ses.NewFromConfig(awsCfg, func(o *ses.Options) { o.Region = region }),
+		})
+	}
+	return identities, nil
+}
+
+// SES error codes worth retrying from another identity/region. Anything else
+// (bad recipient, malformed message) would fail the same way everywhere.
+var retryableSESCodes = map[string]bool{
+	"Throttling":                             true,
+	"ThrottlingException":                    true,
+	"AccountSendingPausedException":          true,
+	"ConfigurationSetSendingPausedException": true,
+	"ServiceUnavailable":                     true,
+	"InternalFailure":                        true,
+}
+
+// SES accounts in the sandbox can only send to verified addresses, and
+// reject anything else as MessageRejected "Email address is not verified".
+// Another identity or region won't help until the recipient is verified or
+// production access granted, so these are never retried.
+func isSESSandboxError(err error) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) || apiErr.ErrorCode() != "MessageRejected" {
+		return false
+	}
+	return strings.Contains(apiErr.ErrorMessage(), "not verified")
+}
+
+func isRetryableSESError(err error) bool {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		return retryableSESCodes[apiErr.ErrorCode()]
+	}
+	// Network-level failures never reached SES, so another region may work
+	return true
+}
+
+type emailNotifier struct{}
+
+func (emailNotifier) Name() string { return "email" }
+
+// With EMAIL_DIGEST the alert waits for the next digest instead. It is
+// mailed on its own when the state table can't take it, rather than lost.
+func (emailNotifier) Send(ctx context.Context, alert Alert) error {
+	if cfg.EmailDigest && durableStates {
+		err := queueEmailDigest(ctx, alert)
+		if err == nil {
+			return nil
+		}
+		slog.Warn("Error queueing alert for email digest, sending it now", "error", err)
+	}
+	return sendEmail(ctx, alert)
+}
+
+// The addresses an alert of this severity is emailed to: the
+// RECIPIENT_EMAIL_<SEVERITY> list when set, RECIPIENT_EMAIL otherwise
+func emailRecipients(sev Severity) []string {
+	if list := cfg.SeverityRecipients[sev]; len(list) > 0 {
+		return list
+	}
+	return splitList(cfg.RecipientEmail)
+}
+
+// Reports whether some severity has an address to email
+func hasEmailRecipients() bool {
+	for _, sev := range []Severity{SeverityCritical, SeverityWarning, SeverityInfo} {
+		if len(emailRecipients(sev)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func sendEmail(ctx context.Context, alert Alert) error {
+	to := emailRecipients(alert.Severity)
+	if cfg.SenderEmail == "" || len(to) == 0 {
+		slog.Info("Sender or recipient email not configured, skipping email notification", "severity", alert.Severity)
+		return nil
+	}
+
+	graph := metricGraph(ctx, alert)
+
+	identities := append([]sesIdentity{primarySESIdentity(ctx)}, sesFallbacks...)
+	var err error
+	for i, id := range identities {
+		var messageID string
+		if graph != nil {
+			messageID, err = sendRawEmailFrom(ctx, id, to, alert, graph)
+		} else {
+			messageID, err = sendEmailFrom(ctx, id, to, alert)
+		}
+		if err == nil {
+			// The message ID is what SES delivery, bounce and complaint
+			// notifications refer to
+			slog.Info("Email sent via SES identity", "sender", id.Email, "region", id.Region,
+				"fallback", i > 0, "ses_message_id", messageID)
+			return nil
+		}
+		if isSESSandboxError(err) {
+			slog.Error("SES is in sandbox; verify recipient or request production access",
+				"sender", id.Email, "recipients", to, "region", id.Region, "error", err)
+			return err
+		}
+		if !isRetryableSESError(err) {
+			return err
+		}
+		slog.Warn("SES send failed, trying next identity", "sender", id.Email, "region", id.Region, "error", err)
+	}
+	return err
+}
+
+func sendEmailFrom(ctx context.Context, id sesIdentity, to []string, alert Alert) (messageID string, err error) {
+	input := &ses.SendEmailInput{
+		Destination: &types.Destination{
+			ToAddresses: to,
+		},
+		Message: &types.Message{
+			Body: &types.Body{
+				Text: &types.Content{
+					Data:    aws.String(renderEmailBody(alert)),
+					Charset: aws.String("UTF-8"),
+				},
+			},
+			Subject: &types.Content{
+				Data:    aws.String(alert.subject()),
+				Charset: aws.String("UTF-8"),
+			},
+		},
+		Source: aws.String(senderAddress(id.Email)),
+	}
+
+	out, err := id.client.SendEmail(ctx, input)
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(out.MessageId), nil
+}
+
+// Sends the alert as a multipart MIME message with the metric graph attached
+func sendRawEmailFrom(ctx context.Context, id sesIdentity, to []string, alert Alert, graph []byte) (messageID string, err error) {
+	var msg bytes.Buffer
+	w := multipart.NewWriter(&msg)
+
+	fmt.Fprintf(&msg, "From: %s\r\n", senderAddress(id.Email))
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", alert.subject()))
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/mixed; boundary=%q\r\n\r\n", w.Boundary())
+
+	text, err := w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=UTF-8"},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	if err != nil {
+		return "", err
+	}
+	writeBase64Lines(text, []byte(renderEmailBody(alert)))
+
+	img, err := w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"image/png"},
+		"Content-Transfer-Encoding": {"base64"},
+		"Content-Disposition":       {`attachment; filename="metrics.png"`},
+	})
+	if err != nil {
+		return "", err
+	}
+	writeBase64Lines(img, graph)
+
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+
+	out, err := id.client.SendRawEmail(ctx, &ses.SendRawEmailInput{
+		RawMessage: &types.RawMessage{Data: msg.Bytes()},
+	})
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(out.MessageId), nil
+}
+
+// MIME requires base64 bodies wrapped at 76 characters
+func writeBase64Lines(w io.Writer, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		io.WriteString(w, encoded[:76]+"\r\n")
+		encoded = encoded[76:]
+	}
+	io.WriteString(w, encoded+"\r\n")
+}
+
+// Formats the From header as `Name <address>` when SENDER_NAME is set.
+// mail.Address takes care of quoting and encoding non-ASCII names.
+func senderAddress(email string) string {
+	if cfg.SenderName == "" {
+		return email
+	}
+	return (&mail.Address{Name: cfg.SenderName, Address: email}).String()
+}
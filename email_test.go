@@ -0,0 +1,11 @@
+package main
+
+import "testing"
+
+func TestEmailBodyGolden(t *testing.T) {
+	for name, alert := range goldenAlerts(t) {
+		t.Run(name, func(t *testing.T) {
+			checkGolden(t, "email/"+name, "Subject: "+alert.subject()+"\n\n"+renderEmailBody(alert)+"\n")
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import "context"
+
+const escalateKeyPrefix = "escalate#"
+
+// Failures are counted per service, or per resource for alerts that aren't
+// about an ECS service (RDS, GuardDuty). Empty when the alert has neither.
+func escalationKey(alert Alert) string {
+	if alert.Service == "" {
+		return alert.Resource
+	}
+	return alert.Cluster + "/" + alert.Service
+}
+
+// Counts a failure for the alert's service and reports whether the count
+// has reached ESCALATE_AFTER. The count keeps growing as long as failures
+// arrive less than ESCALATE_WINDOW_SECONDS apart; after a quiet period of
+// that length the next failure starts again at one. Needs the state table.
+func recordFailure(ctx context.Context, alert Alert) (escalated bool, count int64, err error) {
+	key := escalationKey(alert)
+	if cfg.EscalateAfter <= 0 || !durableStates || alert.Severity == SeverityInfo || key == "" {
+		return false, 0, nil
+	}
+	count, err = states.IncrementSliding(ctx, escalateKeyPrefix+key, eventToken(), cfg.EscalateWindow)
+	if err != nil {
+		return false, 0, err
+	}
+	return count >= int64(cfg.EscalateAfter), count, nil
+}
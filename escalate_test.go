@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRecordFailure(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.EscalateAfter = 3
+		c.EscalateWindow = time.Hour
+	})
+	ctx := context.Background()
+	api := Alert{Severity: SeverityCritical, Cluster: "prod", Service: "api"}
+
+	for i, want := range []bool{false, false, true, true} {
+		escalated, count, err := recordFailure(ctx, api)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if escalated != want || count != int64(i+1) {
+			t.Errorf("failure %d: escalated=%v count=%d, want %v %d", i+1, escalated, count, want, i+1)
+		}
+	}
+
+	worker := api
+	worker.Service = "worker"
+	if escalated, count, _ := recordFailure(ctx, worker); escalated || count != 1 {
+		t.Errorf("another service shares the count: escalated=%v count=%d", escalated, count)
+	}
+
+	info := api
+	info.Severity = SeverityInfo
+	if escalated, count, _ := recordFailure(ctx, info); escalated || count != 0 {
+		t.Errorf("info alert counted: escalated=%v count=%d", escalated, count)
+	}
+
+	// Alerts about other resources count per resource
+	rds := Alert{Severity: SeverityCritical, DetailType: "RDS DB Instance Event", Resource: "arn:aws:rds:us-east-1:123456789012:db:orders"}
+	guardDuty := Alert{Severity: SeverityCritical, DetailType: guardDutyDetailType, Resource: "arn:aws:guardduty:us-east-1:123456789012:detector/d1/finding/a2b4"}
+	for _, want := range []int64{1, 2} {
+		if _, count, _ := recordFailure(ctx, rds); count != want {
+			t.Errorf("RDS failure count = %d, want %d", count, want)
+		}
+	}
+	if _, count, _ := recordFailure(ctx, guardDuty); count != 1 {
+		t.Errorf("GuardDuty finding shares the RDS count: %d", count)
+	}
+	if escalated, count, _ := recordFailure(ctx, Alert{Severity: SeverityCritical}); escalated || count != 0 {
+		t.Errorf("alert with no service or resource counted: escalated=%v count=%d", escalated, count)
+	}
+
+	durableStates = false
+	if escalated, count, _ := recordFailure(ctx, api); escalated || count != 0 {
+		t.Errorf("counted without a state table: escalated=%v count=%d", escalated, count)
+	}
+}
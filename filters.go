@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Stopped reasons ECS uses for routine scale-in, including the
+// "Scaling activity initiated by (deployment ecs-svc/123...)" form whose
+// deployment ID varies per rollout.
+var defaultScalingReasonPatterns = []string{
+	"Scaling activity",
+	"Service scheduler",
+}
+
+// Scheduler messages that mean a task couldn't be placed, e.g. "Service
+// scheduler: unable to place task because no container instance met all of
+// its requirements". They share the "Service scheduler" prefix with routine
+// scale-in, so they are checked first and always count as failures.
+var defaultPlacementFailurePatterns = []string{
+	"unable to place",
+	"no container instance met",
+}
+
+// A stopped-reason matcher. Entries written as /.../ are regular expressions,
+// anything else is a plain substring.
+type reasonPattern struct {
+	substr string
+	re     *regexp.Regexp
+}
+
+func (p reasonPattern) matches(reason string) bool {
+	if p.re != nil {
+		return p.re.MatchString(reason)
+	}
+	return strings.Contains(reason, p.substr)
+}
+
+// Compiles the configured patterns, dropping duplicates
+func parseReasonPatterns(entries []string) ([]reasonPattern, error) {
+	var patterns []reasonPattern
+	seen := make(map[string]bool)
+	for _, e := range entries {
+		if seen[e] {
+			continue
+		}
+		seen[e] = true
+
+		if len(e) > 2 && strings.HasPrefix(e, "/") && strings.HasSuffix(e, "/") {
+			re, err := regexp.Compile(e[1 : len(e)-1])
+			if err != nil {
+				return nil, fmt.Errorf("pattern %q: %v", e, err)
+			}
+			patterns = append(patterns, reasonPattern{re: re})
+			continue
+		}
+		patterns = append(patterns, reasonPattern{substr: e})
+	}
+	return patterns, nil
+}
+
+// Reports whether a stopped reason is routine scale-in rather than a failure.
+// Placement failures never are, even when a scaling pattern matches too.
+func isScalingStopReason(reason string) bool {
+	for _, p := range cfg.PlacementFailures {
+		if p.matches(reason) {
+			return false
+		}
+	}
+	for _, p := range cfg.ScalingReasons {
+		if p.matches(reason) {
+			return true
+		}
+	}
+	return false
+}
+
+// Reports whether a stopped or container reason matches FORCE_ALERT_REASONS,
+// which alert whatever the other filters say
+func isForcedStopReason(reason string) bool {
+	if reason == "" {
+		return false
+	}
+	for _, p := range cfg.ForceAlertReasons {
+		if p.matches(reason) {
+			return true
+		}
+	}
+	return false
+}
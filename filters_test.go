@@ -0,0 +1,170 @@
+package main
+
+import "testing"
+
+func TestIsScalingStopReason(t *testing.T) {
+	withConfig(t, nil)
+	tests := []struct {
+		reason string
+		want   bool
+	}{
+		{"Scaling activity initiated by (deployment ecs-svc/4271158118824739872)", true},
+		{"Scaling activity initiated by (deployment ecs-svc/0123456789012345678)", true},
+		{"Scaling activity initiated by (service-autoscaling)", true},
+		{"Essential container in task exited", false},
+		{"Task failed ELB health checks in (target-group arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/api/6d0ecf831eec9f09)", false},
+		{"CannotPullContainerError: pull image manifest has been retried 5 time(s)", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := isScalingStopReason(tt.reason); got != tt.want {
+			t.Errorf("isScalingStopReason(%q) = %v, want %v", tt.reason, got, tt.want)
+		}
+	}
+}
+
+// "Service scheduler" starts both routine scale-in and placement failures;
+// only the first is filtered
+func TestServiceSchedulerReasons(t *testing.T) {
+	withConfig(t, nil)
+	tests := []struct {
+		reason string
+		want   bool
+	}{
+		{"Service scheduler: task stopped by the service", true},
+		{"Service scheduler stopped the task to rebalance tasks across Availability Zones", true},
+		{"Service scheduler: unable to place task because no container instance met all of its requirements", false},
+		{"Service scheduler: unable to place a task because no container instance met all of its requirements. The closest matching container-instance 5a86d3c4 has insufficient memory available.", false},
+		{"Service scheduler: no container instance met all of its requirements", false},
+	}
+	for _, tt := range tests {
+		if got := isScalingStopReason(tt.reason); got != tt.want {
+			t.Errorf("isScalingStopReason(%q) = %v, want %v", tt.reason, got, tt.want)
+		}
+	}
+
+	// A placement failure alerts as a task that failed to start
+	event := testEvent(t, "ECS Task State Change",
+		stoppedTask("Service scheduler: unable to place task because no container instance met all of its requirements"))
+	if ok, _, skip, err := shouldAlert(event); !ok || err != nil {
+		t.Errorf("placement failure not alerted: skip %q, err %v", skip, err)
+	}
+}
+
+func TestParseReasonPatterns(t *testing.T) {
+	patterns, err := parseReasonPatterns([]string{
+		`/^Scaling activity initiated by \(deployment ecs-svc/\d+\)$/`,
+		"Spot interruption",
+		"Spot interruption",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patterns) != 2 {
+		t.Fatalf("got %d patterns, want the duplicate dropped", len(patterns))
+	}
+	withConfig(t, func(c *Config) { c.ScalingReasons = patterns })
+
+	tests := []struct {
+		reason string
+		want   bool
+	}{
+		{"Scaling activity initiated by (deployment ecs-svc/4271158118824739872)", true},
+		// The regex is anchored, so other scaling activity no longer matches
+		{"Scaling activity initiated by (service-autoscaling)", false},
+		{"Your Spot Task was interrupted. (Spot interruption)", true},
+		{"Service scheduler: task stopped by the service", false},
+	}
+	for _, tt := range tests {
+		if got := isScalingStopReason(tt.reason); got != tt.want {
+			t.Errorf("isScalingStopReason(%q) = %v, want %v", tt.reason, got, tt.want)
+		}
+	}
+
+	if _, err := parseReasonPatterns([]string{"/deployment (/"}); err == nil {
+		t.Error("an invalid regular expression was accepted")
+	}
+}
+
+// FORCE_ALERT_REASONS is checked before the container, exit code and
+// scaling filters, so a matching reason alerts whatever they say
+func TestForceAlertReasonsPrecedence(t *testing.T) {
+	forced, err := parseReasonPatterns([]string{"OutOfMemoryError", "Task failed ELB health checks"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	oom := ContainerInfo{Name: "log-router", ExitCode: 137, Reason: "OutOfMemoryError: Container killed due to memory usage"}
+	clean := ContainerInfo{Name: "app", ExitCode: 0}
+
+	tests := []struct {
+		name string
+		edit func(c *Config)
+		task ECSTaskDetail
+	}{
+		{
+			name: "container on IGNORED_CONTAINERS",
+			edit: func(c *Config) { c.IgnoredContainers = []string{"log-router"} },
+			task: stoppedTask("", clean, oom),
+		},
+		{
+			name: "exit code below MIN_ALERT_EXIT_CODE",
+			edit: func(c *Config) { c.MinAlertExitCode = 200 },
+			task: stoppedTask("", clean, oom),
+		},
+		{
+			name: "stopped reason matching a scaling pattern",
+			edit: func(c *Config) {
+				c.ScalingReasons = append(c.ScalingReasons, reasonPattern{substr: "ELB health checks"})
+			},
+			task: stoppedTask("Task failed ELB health checks in (target-group arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/api/6d0ecf831eec9f09)", clean),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withConfig(t, tt.edit)
+			event := testEvent(t, "ECS Task State Change", tt.task)
+
+			// The filter holds the task back on its own...
+			if ok, _, _, err := shouldAlert(event); ok || err != nil {
+				t.Fatalf("without FORCE_ALERT_REASONS: ok=%v err=%v, want the filter to skip it", ok, err)
+			}
+			// ...and the forced reason wins over it
+			cfg.ForceAlertReasons = forced
+			ok, alert, skip, err := shouldAlert(event)
+			if !ok || err != nil {
+				t.Fatalf("with FORCE_ALERT_REASONS: ok=%v skip=%q err=%v", ok, skip, err)
+			}
+			if alert.Severity != SeverityCritical {
+				t.Errorf("severity = %s, want critical", alert.Severity)
+			}
+		})
+	}
+}
+
+// The task failure rule forwards every STOPPED task, so the Lambda alone
+// tells failures from routine stops
+func TestShouldAlertStoppedReasons(t *testing.T) {
+	clean := ContainerInfo{Name: "app", ExitCode: 0}
+	tests := []struct {
+		reason string
+		want   bool
+	}{
+		{"Scaling activity initiated by (deployment ecs-svc/4271158118824739872)", false},
+		{"Service scheduler: task stopped to make room for the new deployment", false},
+		{"Task failed ELB health checks in (target-group arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/api/6d0ecf831eec9f09)", true},
+		{"Service scheduler: unable to place task because no container instance met all of its requirements", true},
+		{"Task failed to start", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.reason, func(t *testing.T) {
+			withConfig(t, nil)
+			ok, _, skip, err := shouldAlert(testEvent(t, "ECS Task State Change", stoppedTask(tt.reason, clean)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != tt.want {
+				t.Errorf("shouldAlert = %v (%s), want %v", ok, skip, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// With ALERT_FIRST_FAILURE_ONLY, task failures are counted per deployment
+// and only the first one alerts; the rest wait for the deployment's
+// summary when it completes or fails
+const firstFailureKeyPrefix = "firstfail#"
+
+func firstFailureKey(cluster, service, deploymentID string) string {
+	return cluster + "/" + service + "/" + deploymentID
+}
+
+// Counts a task failure against the deployment that started the task and
+// reports whether it is the deployment's first. Tasks not started by a
+// deployment always count as first. The first failure's event claims the
+// deployment, so it is still the first when redelivered.
+func isFirstDeploymentFailure(ctx context.Context, alert Alert) (bool, error) {
+	if !cfg.FirstFailureOnly || alert.Deployment == "" {
+		return true, nil
+	}
+	key := firstFailureKey(alert.Cluster, alert.Service, alert.Deployment)
+	token := eventToken()
+
+	if _, err := states.Increment(ctx, firstFailureKeyPrefix+key, token, stateTTL); err != nil {
+		return true, err
+	}
+	holder, err := states.Claim(ctx, firstFailureKeyPrefix+key+"#first", token, stateTTL)
+	if err != nil {
+		return true, err
+	}
+	return holder == token, nil
+}
+
+// Takes the failure count of a deployment that just completed or failed and
+// returns the summary alert for it, or ok=false when none of its tasks
+// failed
+func deploymentFailureSummary(ctx context.Context, event events.CloudWatchEvent) (ok bool, alert Alert, err error) {
+	if !cfg.FirstFailureOnly {
+		return false, Alert{}, nil
+	}
+	detail, err := parseDeploymentDetail(event)
+	if err != nil || detail.DeploymentID == "" {
+		return false, Alert{}, err
+	}
+	var outcome string
+	severity := SeverityInfo
+	switch detail.EventName {
+	case "SERVICE_DEPLOYMENT_COMPLETED":
+		outcome = "resolved"
+	case "SERVICE_DEPLOYMENT_FAILED":
+		outcome, severity = "failed", SeverityWarning
+	default:
+		return false, Alert{}, nil
+	}
+
+	serviceName := getResourceName(detail.Service)
+	cluster := getResourceName(detail.Cluster)
+	key := firstFailureKey(cluster, serviceName, detail.DeploymentID)
+
+	failures, err := getCount(ctx, firstFailureKeyPrefix+key)
+	if err != nil || failures == 0 {
+		return false, Alert{}, err
+	}
+	if err := states.PutWithTTL(ctx, firstFailureKeyPrefix+key, "0", time.Hour); err != nil {
+		return false, Alert{}, err
+	}
+	// A failure after the summary is the first of a new count
+	if err := states.Delete(ctx, firstFailureKeyPrefix+key+"#first"); err != nil {
+		return false, Alert{}, err
+	}
+
+	return true, Alert{
+		DetailType: event.DetailType,
+		Resource:   detail.Service,
+		Severity:   severity,
+		Title:      fmt.Sprintf("Deployment %s: %s", outcome, serviceName),
+		Service:    serviceName,
+		Cluster:    cluster,
+		Deployment: detail.DeploymentID,
+		Fields: map[string]string{
+			"Deployment":    detail.DeploymentID,
+			"Task Failures": fmt.Sprintf("%d during the rollout, only the first was alerted", failures),
+		},
+		Links:     ecsConsoleLinks(event.Region, cluster, serviceName, ""),
+		Timestamp: event.Time,
+	}, nil
+}
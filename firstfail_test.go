@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDeploymentFailureSummarySendBudget(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.FirstFailureOnly = true
+		c.SendBudget = time.Minute
+	})
+	sent := captureAlerts(t)
+	var deadlines []bool
+	notifiers["json"] = notifierFunc{"json", func(ctx context.Context, alert Alert) error {
+		_, ok := ctx.Deadline()
+		deadlines = append(deadlines, ok)
+		*sent = append(*sent, alert)
+		return nil
+	}}
+
+	key := firstFailureKeyPrefix + firstFailureKey("prod", "api", "ecs-svc/4271158118824739872")
+	for _, token := range []string{"event-1", "event-2"} {
+		if _, err := states.Increment(t.Context(), key, token, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	event := deploymentEvent(t, "SERVICE_DEPLOYMENT_FAILED", "ECS deployment circuit breaker: rolling back")
+	if err := runHandleRequest(t, context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+	var summaries int
+	for i, alert := range *sent {
+		if alert.Title == "Deployment failed: api" {
+			summaries++
+			if !deadlines[i] {
+				t.Error("the failure summary was sent without the SEND_BUDGET_SECONDS deadline")
+			}
+		}
+	}
+	if summaries != 1 {
+		t.Errorf("sent %d failure summaries, want 1: %v", summaries, *sent)
+	}
+	if summary.decision == "" {
+		t.Error("the invocation summary recorded no decision")
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"html"
+	"log/slog"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// Google Chat rejects message text longer than this
+const googleChatMaxText = 4096
+
+type googleChatPayload struct {
+	Text    string           `json:"text"`
+	CardsV2 []googleChatCard `json:"cardsV2,omitempty"`
+}
+
+type googleChatCard struct {
+	CardID string `json:"cardId"`
+	Card   struct {
+		Header struct {
+			Title    string `json:"title"`
+			Subtitle string `json:"subtitle,omitempty"`
+		} `json:"header"`
+		Sections []googleChatSection `json:"sections"`
+	} `json:"card"`
+}
+
+type googleChatSection struct {
+	Widgets []googleChatWidget `json:"widgets"`
+}
+
+type googleChatWidget struct {
+	TextParagraph struct {
+		Text string `json:"text"`
+	} `json:"textParagraph"`
+}
+
+// Renders the alert as a card; the plain text is kept as the notification
+// preview for clients that don't show cards
+func googleChatMessage(alert Alert) googleChatPayload {
+	body := strings.ReplaceAll(html.EscapeString(renderEmailBody(alert)), "\n", "<br>")
+
+	var card googleChatCard
+	card.CardID = "alert"
+	card.Card.Header.Title = alert.subject()
+	card.Card.Header.Subtitle = alert.Service
+
+	var widget googleChatWidget
+	widget.TextParagraph.Text = truncateChatMarkup(body, googleChatMaxText)
+	card.Card.Sections = []googleChatSection{{Widgets: []googleChatWidget{widget}}}
+
+	return googleChatPayload{
+		Text:    truncateRunes(alert.subject(), googleChatMaxText),
+		CardsV2: []googleChatCard{card},
+	}
+}
+
+// Like truncateRunes, for text already escaped and with <br> line breaks:
+// escaping lengthens the text, so the cut comes after it, and it backs up
+// to before an entity or tag it would otherwise split
+func truncateChatMarkup(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	marker := truncatedMarker
+	room := max - utf8.RuneCountInString(marker)
+	if room < 0 {
+		marker, room = "", max
+	}
+	text := string([]rune(s)[:room])
+	if i := strings.LastIndexByte(text, '&'); i >= 0 && !strings.Contains(text[i:], ";") {
+		text = text[:i]
+	}
+	if i := strings.LastIndexByte(text, '<'); i >= 0 && !strings.Contains(text[i:], ">") {
+		text = text[:i]
+	}
+	return text + marker
+}
+
+// Adds the threadKey so alerts for the same service group into one thread,
+// starting a new thread when the key hasn't been seen before
+func googleChatThreadURL(webhookURL, threadKey string) (string, error) {
+	u, err := url.Parse(webhookURL)
+	if err != nil {
+		return "", err
+	}
+	if threadKey != "" {
+		q := u.Query()
+		q.Set("threadKey", threadKey)
+		q.Set("messageReplyOption", "REPLY_MESSAGE_FALLBACK_TO_NEW_THREAD")
+		u.RawQuery = q.Encode()
+	}
+	return u.String(), nil
+}
+
+func sendGoogleChatNotification(ctx context.Context, alert Alert) error {
+	if cfg.GoogleChatWebhookURL == "" {
+		slog.Info("Google Chat webhook URL not configured, skipping Google Chat notification")
+		return nil
+	}
+
+	webhookURL, err := googleChatThreadURL(cfg.GoogleChatWebhookURL, alert.Service)
+	if err != nil {
+		return err
+	}
+	payloadBytes, err := json.Marshal(googleChatMessage(alert))
+	if err != nil {
+		return err
+	}
+
+	return postJSON(ctx, "Google Chat", webhookURL, payloadBytes)
+}
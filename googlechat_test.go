@@ -0,0 +1,46 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// Escaping and line breaks lengthen the body, so a body at the limit must
+// still fit once they are applied
+func TestGoogleChatMessageFitsEscapedBody(t *testing.T) {
+	withConfig(t, nil)
+	alert := Alert{
+		Title:    "ECS task failed: api",
+		Severity: SeverityCritical,
+		Details:  []string{strings.Repeat("a<b> & \"c\"\n", googleChatMaxText/10)},
+	}
+	text := googleChatMessage(alert).CardsV2[0].Card.Sections[0].Widgets[0].TextParagraph.Text
+	if n := utf8.RuneCountInString(text); n > googleChatMaxText {
+		t.Errorf("card text is %d characters, over the %d limit", n, googleChatMaxText)
+	}
+	if !strings.HasSuffix(text, truncatedMarker) {
+		t.Errorf("card text does not end with the truncation marker")
+	}
+}
+
+func TestTruncateChatMarkup(t *testing.T) {
+	marker := utf8.RuneCountInString(truncatedMarker)
+	tests := []struct {
+		text string
+		max  int
+		want string
+	}{
+		{"short &amp; sweet", 100, "short &amp; sweet"},
+		// The cut would fall inside &amp; or <br>, so it backs up before it
+		{"abc&amp;" + strings.Repeat("x", 40), marker + 5, "abc" + truncatedMarker},
+		{"abc<br>" + strings.Repeat("x", 40), marker + 5, "abc" + truncatedMarker},
+		{"abc<br>" + strings.Repeat("x", 40), marker + 7, "abc<br>" + truncatedMarker},
+		{strings.Repeat("x", 40), 3, "xxx"},
+	}
+	for _, tt := range tests {
+		if got := truncateChatMarkup(tt.text, tt.max); got != tt.want {
+			t.Errorf("truncateChatMarkup(%q, %d) = %q, want %q", tt.text, tt.max, got, tt.want)
+		}
+	}
+}
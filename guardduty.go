@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const guardDutyDetailType = "GuardDuty Finding"
+
+type GuardDutyDetail struct {
+	ID          string  `json:"id"`
+	Arn         string  `json:"arn"`
+	Type        string  `json:"type"`
+	Severity    float64 `json:"severity"`
+	Title       string  `json:"title"`
+	Description string  `json:"description"`
+	AccountID   string  `json:"accountId"`
+	Region      string  `json:"region"`
+	Resource    struct {
+		ResourceType string `json:"resourceType"`
+	} `json:"resource"`
+}
+
+// GuardDuty rates findings 0-10: 7 and up is high (9 and up critical),
+// 4 to 7 medium, below 4 low
+func guardDutySeverity(score float64) Severity {
+	switch {
+	case score >= 7:
+		return SeverityCritical
+	case score >= 4:
+		return SeverityWarning
+	default:
+		return SeverityInfo
+	}
+}
+
+// Builds an alert for a GuardDuty finding. Every finding alerts; severity
+// routing decides where low ones go.
+func guardDutyAlert(event events.CloudWatchEvent) (ok bool, alert Alert, err error) {
+	var detail GuardDutyDetail
+	if err := decodeDetail(event.Detail, &detail); err != nil {
+		return false, Alert{}, fmt.Errorf("failed to unmarshal GuardDuty finding: %v", err)
+	}
+
+	region := detail.Region
+	if region == "" {
+		region = event.Region
+	}
+	alert = Alert{
+		DetailType: event.DetailType,
+		Resource:   detail.Arn,
+		Severity:   guardDutySeverity(detail.Severity),
+		Title:      fmt.Sprintf("GuardDuty: %s", detail.Title),
+		Fields: map[string]string{
+			"Finding Type":  detail.Type,
+			"Severity":      strconv.FormatFloat(detail.Severity, 'f', -1, 64),
+			"Account":       detail.AccountID,
+			"Resource Type": detail.Resource.ResourceType,
+		},
+		Details:   []string{detail.Description},
+		Timestamp: event.Time,
+	}
+	if region != "" && detail.ID != "" {
+		alert.Links = []string{fmt.Sprintf("https://%s.console.aws.amazon.com/guardduty/home?region=%s#/findings?macros=current&fId=%s",
+			region, region, url.QueryEscape(detail.ID))}
+	}
+	return true, alert, nil
+}
+
+// Security findings go to SECURITY_SLACK_WEBHOOK_URL instead of the other
+// channels when it is set
+func isSecurityAlert(alert Alert) bool {
+	return alert.DetailType == guardDutyDetailType || alert.DetailType == securityHubDetailType
+}
+
+func sendSecuritySlackNotification(ctx context.Context, alert Alert) error {
+	if cfg.SecuritySlackURL == "" {
+		slog.Info("Security Slack webhook URL not configured, skipping security Slack notification")
+		return nil
+	}
+
+	payloadBytes, err := json.Marshal(slackPayload(alert))
+	if err != nil {
+		return fmt.Errorf("failed to encode Slack payload: %v", err)
+	}
+
+	return postJSONChecked(ctx, "security Slack", cfg.SecuritySlackURL, payloadBytes, checkSlackWebhookResponse)
+}
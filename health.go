@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Detail-type of the alerts raised for a running task whose container
+// health check started failing. They come from Task State Change events but
+// skip the checks meant for stopped tasks.
+const unhealthyTaskDetailType = "ECS Task Unhealthy"
+
+// The containers of a task failing their health check, leaving out
+// IGNORED_CONTAINERS
+func unhealthyContainers(detail ECSTaskDetail) []ContainerInfo {
+	var unhealthy []ContainerInfo
+	for _, c := range detail.Containers {
+		if c.HealthStatus == "UNHEALTHY" && !contains(cfg.IgnoredContainers, c.Name) {
+			unhealthy = append(unhealthy, c)
+		}
+	}
+	return unhealthy
+}
+
+// Builds a warning for a RUNNING task with an UNHEALTHY container. ok is
+// false with ALERT_ON_UNHEALTHY_TASKS off or when every container is
+// healthy; suppressReason drops the events repeating a flip already
+// alerted.
+func unhealthyTaskAlert(event events.CloudWatchEvent, detail ECSTaskDetail) (ok bool, alert Alert, err error) {
+	if !cfg.UnhealthyAlerts {
+		return false, Alert{}, nil
+	}
+	unhealthy := unhealthyContainers(detail)
+	if len(unhealthy) == 0 {
+		return false, Alert{}, nil
+	}
+
+	var details, names []string
+	for _, c := range unhealthy {
+		line := fmt.Sprintf("Container '%s' is failing its health check", c.Name)
+		if tag := imageTag(c.Image); tag != "" {
+			line += fmt.Sprintf(", deployed commit: %s", tag)
+		}
+		details = append(details, line)
+		names = append(names, c.Name)
+	}
+
+	deployment := ""
+	if strings.HasPrefix(detail.StartedBy, "ecs-svc/") {
+		deployment = detail.StartedBy
+	}
+	serviceName := getServiceNameFromGroup(detail.Group)
+	cluster := getResourceName(detail.ClusterArn)
+	return true, Alert{
+		DetailType: unhealthyTaskDetailType,
+		Resource:   detail.TaskArn,
+		Severity:   SeverityWarning,
+		Title:      fmt.Sprintf("ECS Task Unhealthy: %s", serviceName),
+		Service:    serviceName,
+		Cluster:    cluster,
+		Deployment: deployment,
+		Fields: map[string]string{
+			"Task ARN":             detail.TaskArn,
+			"Unhealthy Containers": strings.Join(names, ", "),
+		},
+		Details:   details,
+		Links:     ecsConsoleLinks(event.Region, cluster, serviceName, detail.TaskArn),
+		Timestamp: event.Time,
+	}, nil
+}
+
+// Marks a running task whose containers are all healthy again, so its next
+// flip to UNHEALTHY alerts rather than counting as a repeat
+func trackTaskHealth(ctx context.Context, event events.CloudWatchEvent) error {
+	if !cfg.UnhealthyAlerts {
+		return nil
+	}
+	var detail ECSTaskDetail
+	if err := decodeDetail(event.Detail, &detail); err != nil {
+		return fmt.Errorf("failed to unmarshal task detail: %v", err)
+	}
+	if detail.LastStatus != "RUNNING" || len(unhealthyContainers(detail)) > 0 {
+		return nil
+	}
+	_, err := isRepeatTaskStatus(ctx, detail.TaskArn, "RUNNING")
+	return err
+}
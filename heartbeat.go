@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Kept short: the ping runs before the handler returns, since Lambda may
+// freeze the process as soon as it has
+const heartbeatTimeout = 2 * time.Second
+
+// GETs HEARTBEAT_URL so a dead-man switch (healthchecks.io and the like)
+// notices when the alerter stops being invoked or keeps failing. Failures
+// are logged and never fail the invocation.
+func pingHeartbeat(ctx context.Context) {
+	if cfg.HeartbeatURL == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), heartbeatTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, cfg.HeartbeatURL, nil)
+	if err != nil {
+		slog.Warn("Heartbeat ping failed", "error", err)
+		return
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		slog.Warn("Heartbeat ping failed", "error", err)
+		return
+	}
+	drainAndClose(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		slog.Warn("Heartbeat ping failed", "status", resp.Status)
+	}
+}
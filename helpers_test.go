@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+var update = flag.Bool("update", false, "rewrite the testdata golden files with the current output")
+
+// The time every test event carries, so rendered output is stable
+var testEventTime = time.Date(2026, 3, 14, 9, 26, 53, 0, time.UTC)
+
+// Runs the test against the config init built from the environment, changed
+// by edit, a fresh in-memory state store and no cached tag query. The store
+// counts as durable so the table-only features run against it. All of this
+// is put back when the test ends.
+func withConfig(t *testing.T, edit func(c *Config)) {
+	t.Helper()
+	saved, savedStates, savedDurable := cfg, states, durableStates
+	t.Cleanup(func() {
+		cfg, states, durableStates = saved, savedStates, savedDurable
+		setTaggedServices(nil)
+	})
+	if edit != nil {
+		edit(&cfg)
+	}
+	states, durableStates = newMemoryStore(), true
+	setTaggedServices(nil)
+}
+
+// Runs handleRequest, putting back the logger and event ID it sets
+func runHandleRequest(t *testing.T, ctx context.Context, event events.CloudWatchEvent) error {
+	t.Helper()
+	savedLogger, savedID := slog.Default(), invocationEventID
+	t.Cleanup(func() {
+		slog.SetDefault(savedLogger)
+		invocationEventID = savedID
+	})
+	return handleRequest(ctx, event)
+}
+
+// Like runHandleRequest, for a raw invocation payload
+func runHandleInvocation(t *testing.T, payload json.RawMessage) (any, error) {
+	t.Helper()
+	savedLogger, savedID := slog.Default(), invocationEventID
+	t.Cleanup(func() {
+		slog.SetDefault(savedLogger)
+		invocationEventID = savedID
+	})
+	return handleInvocation(t.Context(), payload)
+}
+
+// Replaces the notifiers with one on the JSON webhook channel that keeps
+// every alert it is sent. Call after withConfig, which puts the channel flag
+// back.
+func captureAlerts(t *testing.T) *[]Alert {
+	t.Helper()
+	saved := notifiers
+	t.Cleanup(func() { notifiers = saved })
+	var sent []Alert
+	notifiers = map[string]Notifier{"json": notifierFunc{"json", func(_ context.Context, alert Alert) error {
+		sent = append(sent, alert)
+		return nil
+	}}}
+	cfg.JSONWebhookEnabled = true
+	cfg.RepeatWindow = 0
+	return &sent
+}
+
+// An EventBridge event as ECS and the other sources deliver it; detail is
+// marshalled unless it is already raw JSON
+func testEvent(t *testing.T, detailType string, detail any, resources ...string) events.CloudWatchEvent {
+	t.Helper()
+	raw, ok := detail.(json.RawMessage)
+	if !ok {
+		var err error
+		if raw, err = json.Marshal(detail); err != nil {
+			t.Fatalf("marshal detail: %v", err)
+		}
+	}
+	return events.CloudWatchEvent{
+		ID:         "6a7e8feb-b491-4cf7-a9f1-bf3703467718",
+		DetailType: detailType,
+		Source:     "aws.ecs",
+		AccountID:  "123456789012",
+		Time:       testEventTime,
+		Region:     "us-east-1",
+		Resources:  resources,
+		Detail:     raw,
+	}
+}
+
+const (
+	testClusterArn = "arn:aws:ecs:us-east-1:123456789012:cluster/prod"
+	testServiceArn = "arn:aws:ecs:us-east-1:123456789012:service/prod/api"
+	testTaskArn    = "arn:aws:ecs:us-east-1:123456789012:task/prod/0b69d5c0d0a946ab8c7e5c1e4a7d1f3e"
+	testTaskDefArn = "arn:aws:ecs:us-east-1:123456789012:task-definition/api:42"
+)
+
+// A STOPPED task of the api service with the given containers
+func stoppedTask(stoppedReason string, containers ...ContainerInfo) ECSTaskDetail {
+	return ECSTaskDetail{
+		ClusterArn:        testClusterArn,
+		TaskArn:           testTaskArn,
+		TaskDefinitionArn: testTaskDefArn,
+		Group:             "service:api",
+		LastStatus:        "STOPPED",
+		StoppedReason:     stoppedReason,
+		StartedBy:         "ecs-svc/4271158118824739872",
+		Containers:        containers,
+	}
+}
+
+func deploymentEvent(t *testing.T, eventName, reason string) events.CloudWatchEvent {
+	return testEvent(t, "ECS Deployment State Change", ECSDeplomentDetail{
+		EventName:    eventName,
+		DeploymentID: "ecs-svc/4271158118824739872",
+		Cluster:      testClusterArn,
+		Reason:       reason,
+	}, testServiceArn)
+}
+
+// Compares got with testdata/<name>.golden, or rewrites the file with -update
+func checkGolden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run go test -update to create it)", err)
+	}
+	if got != string(want) {
+		t.Errorf("output differs from %s (run go test -update to accept it)\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+// One alert per detail type the renderers see, covering every severity and
+// a task with several failed containers, as shouldAlert builds them
+func goldenAlerts(t *testing.T) map[string]Alert {
+	t.Helper()
+	withConfig(t, func(c *Config) {
+		c.AlertOnDeploySuccess = true
+		c.UnhealthyAlerts = true
+		c.CapacityAlerts = true
+		c.Location = time.UTC
+	})
+
+	logsDetail := events.CloudwatchLogsData{
+		MessageType: "DATA_MESSAGE",
+		LogGroup:    "/ecs/api",
+		LogStream:   "ecs/api/0b69d5c0d0a946ab8c7e5c1e4a7d1f3e",
+		LogEvents: []events.CloudwatchLogsLogEvent{
+			{ID: "1", Timestamp: testEventTime.UnixMilli(), Message: "panic: runtime error: invalid memory address"},
+			{ID: "2", Timestamp: testEventTime.UnixMilli(), Message: "goroutine 1 [running]:"},
+		},
+	}
+	unhealthy := stoppedTask("", ContainerInfo{Name: "app", Image: "repo/api:3f2c1ab", HealthStatus: "UNHEALTHY"})
+	unhealthy.LastStatus = "RUNNING"
+
+	cases := map[string]events.CloudWatchEvent{
+		"task_multi_container": testEvent(t, "ECS Task State Change", stoppedTask("Essential container in task exited",
+			ContainerInfo{Name: "app", Image: "repo/api:3f2c1ab", ExitCode: 137, Reason: "OutOfMemoryError: Container killed due to memory usage"},
+			ContainerInfo{Name: "worker", Image: "repo/worker:3f2c1ab", ExitCode: 1, Reason: "Essential container exited"},
+			ContainerInfo{Name: "log-router", Image: "amazon/aws-for-fluent-bit:2.32", ExitCode: 0},
+		)),
+		"task_failed_to_start": testEvent(t, "ECS Task State Change",
+			stoppedTask("CannotPullContainerError: pull image manifest has been retried 5 time(s)")),
+		"task_unhealthy":             testEvent(t, "ECS Task State Change", unhealthy),
+		"deployment_failed_rollback": deploymentEvent(t, "SERVICE_DEPLOYMENT_FAILED", "ECS deployment circuit breaker: rolling back to deploymentId ecs-svc/1234567890."),
+		"deployment_completed":       deploymentEvent(t, "SERVICE_DEPLOYMENT_COMPLETED", "ECS deployment ecs-svc/4271158118824739872 completed."),
+		"capacity_placement_failure": testEvent(t, serviceActionDetailType, ECSServiceActionDetail{
+			EventType:            "ERROR",
+			EventName:            "SERVICE_TASK_PLACEMENT_FAILURE",
+			ClusterArn:           testClusterArn,
+			CapacityProviderArns: []string{"arn:aws:ecs:us-east-1:123456789012:capacity-provider/FARGATE_SPOT"},
+			Reason:               "RESOURCE:FARGATE",
+		}, testServiceArn),
+		"guardduty_finding": testEvent(t, guardDutyDetailType, map[string]any{
+			"id":          "a2b4c6d8e0f1",
+			"arn":         "arn:aws:guardduty:us-east-1:123456789012:detector/d1/finding/a2b4c6d8e0f1",
+			"type":        "Recon:EC2/PortProbeUnprotectedPort",
+			"severity":    5,
+			"title":       "Unprotected port on EC2 instance i-0abc is being probed",
+			"description": "EC2 instance has an unprotected port which is being probed by a known malicious host.",
+			"accountId":   "123456789012",
+			"region":      "us-east-1",
+			"resource":    map[string]string{"resourceType": "Instance"},
+		}),
+		"rds_failure": testEvent(t, "RDS DB Instance Event", RDSEventDetail{
+			EventCategories:  []string{"failover"},
+			SourceType:       "DB_INSTANCE",
+			SourceArn:        "arn:aws:rds:us-east-1:123456789012:db:orders",
+			SourceIdentifier: "orders",
+			Message:          "Multi-AZ instance failover started.",
+			EventID:          "RDS-EVENT-0049",
+		}),
+		"acm_expiry": testEvent(t, acmExpiryDetailType, ACMExpiryDetail{DaysToExpiry: 40, CommonName: "api.example.com"},
+			"arn:aws:acm:us-east-1:123456789012:certificate/9f8e7d6c"),
+		"logs_match": testEvent(t, logsDetailType, logsDetail),
+		"unknown_detail_type": testEvent(t, "EC2 Instance State-change Notification",
+			json.RawMessage(`{"instance-id": "i-0abc", "state": "stopping"}`)),
+	}
+
+	alerts := make(map[string]Alert, len(cases)+1)
+	for name, event := range cases {
+		var (
+			ok    bool
+			alert Alert
+			err   error
+		)
+		if name == "unknown_detail_type" {
+			ok, alert = true, unknownEventAlert(event)
+		} else {
+			ok, alert, _, err = shouldAlert(event)
+		}
+		if err != nil || !ok {
+			t.Fatalf("%s: expected an alert, got ok=%v err=%v", name, ok, err)
+		}
+		alerts[name] = alert
+	}
+
+	var finding SecurityHubFinding
+	finding.ID = "arn:aws:securityhub:us-east-1:123456789012:subscription/aws-foundational-security-best-practices/v/1.0.0/S3.8/finding/1f2e"
+	finding.ProductName = "Security Hub"
+	finding.Title = "S3 general purpose buckets should block public access"
+	finding.Description = "This control checks whether an S3 general purpose bucket blocks public access."
+	finding.AwsAccountID = "123456789012"
+	finding.Severity.Label = "HIGH"
+	finding.Compliance.Status = "FAILED"
+	finding.Resources = append(finding.Resources, struct {
+		Type string `json:"Type"`
+		ID   string `json:"Id"`
+	}{"AwsS3Bucket", "arn:aws:s3:::orders-exports"})
+	alerts["security_hub_finding"] = securityHubAlert(testEvent(t, securityHubDetailType, SecurityHubDetail{}), finding)
+	return alerts
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+)
+
+// Version of alertDocument. Bump it on any change that could break a
+// consumer (renamed/removed fields, changed types); adding fields is fine.
+const alertSchemaVersion = 1
+
+// The stable JSON shape POSTed to JSON_WEBHOOK_URL:
+//
+//	{
+//	  "schemaVersion": 1,
+//	  "severity":   "critical" | "warning" | "info",
+//	  "title":      "ECS Task Failure: api",
+//	  "service":    "api",                     // omitted when not an ECS alert
+//	  "cluster":    "prod",                    // omitted when unknown
+//	  "detailType": "ECS Task State Change",   // EventBridge detail-type
+//	  "resource":   "arn:aws:ecs:...",         // ARN the alert is about
+//	  "fields":     {"Task ARN": "..."},       // labelled values
+//	  "details":    ["Container 'app' ..."],   // one entry per finding
+//	  "links":      ["https://..."],
+//	  "timestamp":  "2024-01-01T00:00:00Z",    // RFC 3339, UTC
+//	  "banner":     "Automated alert ...",     // NOTIFICATION_BANNER, omitted when unset
+//	  "incidentId": "a1b2c3",                  // shared by alerts with one dedup key
+//	  "sampled":    true                       // kept under INFO_SAMPLE_RATE, omitted otherwise
+//	}
+//
+// With WEBHOOK_SIGNING_SECRET set, requests carry X-Signature and
+// X-Signature-Timestamp headers, see signRequest.
+type alertDocument struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	Severity      Severity          `json:"severity"`
+	Title         string            `json:"title"`
+	Service       string            `json:"service,omitempty"`
+	Cluster       string            `json:"cluster,omitempty"`
+	DetailType    string            `json:"detailType,omitempty"`
+	Resource      string            `json:"resource,omitempty"`
+	Fields        map[string]string `json:"fields"`
+	Details       []string          `json:"details"`
+	Links         []string          `json:"links"`
+	Timestamp     time.Time         `json:"timestamp"`
+	Banner        string            `json:"banner,omitempty"`
+	IncidentID    string            `json:"incidentId,omitempty"`
+	Sampled       bool              `json:"sampled,omitempty"`
+}
+
+func newAlertDocument(alert Alert) alertDocument {
+	doc := alertDocument{
+		SchemaVersion: alertSchemaVersion,
+		Severity:      alert.Severity,
+		Title:         alert.Title,
+		Service:       alert.Service,
+		Cluster:       alert.Cluster,
+		DetailType:    alert.DetailType,
+		Resource:      alert.Resource,
+		Fields:        alert.Fields,
+		Details:       alert.Details,
+		Links:         alert.Links,
+		Timestamp:     alert.Timestamp.UTC(),
+		Banner:        alert.Banner,
+		IncidentID:    alert.IncidentID,
+		Sampled:       alert.Sampled,
+	}
+	// Consumers get empty collections rather than null
+	if doc.Fields == nil {
+		doc.Fields = map[string]string{}
+	}
+	if doc.Details == nil {
+		doc.Details = []string{}
+	}
+	if doc.Links == nil {
+		doc.Links = []string{}
+	}
+	return doc
+}
+
+func sendJSONNotification(ctx context.Context, alert Alert) error {
+	if cfg.JSONWebhookURL == "" {
+		slog.Info("JSON webhook URL not configured, skipping JSON notification")
+		return nil
+	}
+
+	payloadBytes, err := json.Marshal(newAlertDocument(alert))
+	if err != nil {
+		return err
+	}
+
+	return postSignedJSON(ctx, "JSON webhook", cfg.JSONWebhookURL, payloadBytes)
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"log/slog"
+	"strings"
+)
+
+// Translations of the fixed labels alerts are rendered with, by language.
+// Keys are the English labels; anything missing is shown in English. Values
+// that come from AWS (reasons, messages, names) are never translated.
+var translations = map[string]map[string]string{
+	"de": {
+		"Service":                      "Dienst",
+		"Cluster":                      "Cluster",
+		"Incident ID":                  "Vorfall-ID",
+		"Details":                      "Details",
+		"Time":                         "Zeit",
+		"Reason":                       "Grund",
+		"Message":                      "Nachricht",
+		"Event":                        "Ereignis",
+		"Task ARN":                     "Task-ARN",
+		"Deployment":                   "Deployment",
+		"Rolled Back From":             "Zurückgesetzt von",
+		"Rolled Back To":               "Zurückgesetzt auf",
+		"Escalated":                    "Eskaliert",
+		"Dropped During Quiet Hours":   "In Ruhezeiten verworfen",
+		"Suppressed During Cooldown":   "Während der Sperrzeit unterdrückt",
+		"Window":                       "Zeitfenster",
+		"Task Failures":                "Task-Fehler",
+		"Last Status":                  "Letzter Status",
+		"Pending For":                  "Ausstehend seit",
+		"In Progress For":              "Läuft seit",
+		"Log Group":                    "Protokollgruppe",
+		"Log Stream":                   "Protokollstream",
+		"Matches":                      "Treffer",
+		"Certificate":                  "Zertifikat",
+		"Domain":                       "Domain",
+		"Days To Expiry":               "Tage bis Ablauf",
+		"DB Instance":                  "DB-Instanz",
+		"Finding Type":                 "Befundtyp",
+		"Severity":                     "Schweregrad",
+		"Account":                      "Konto",
+		"Resource Type":                "Ressourcentyp",
+		"Region":                       "Region",
+		"Status":                       "Status",
+		"Undelivered":                  "Nicht zugestellt",
+		"Capacity Provider":            "Kapazitätsanbieter",
+		"Unhealthy Containers":         "Fehlerhafte Container",
+		"Restarts":                     "Neustarts",
+		"Likely Fix":                   "Mögliche Lösung",
+		"Source":                       "Quelle",
+		"Compliance":                   "Compliance",
+		"Full details attached below.": "Vollständige Details im Anhang.",
+	},
+	"es": {
+		"Service":                      "Servicio",
+		"Cluster":                      "Clúster",
+		"Incident ID":                  "ID de incidente",
+		"Details":                      "Detalles",
+		"Time":                         "Hora",
+		"Reason":                       "Motivo",
+		"Message":                      "Mensaje",
+		"Event":                        "Evento",
+		"Task ARN":                     "ARN de la tarea",
+		"Deployment":                   "Implementación",
+		"Rolled Back From":             "Revertido desde",
+		"Rolled Back To":               "Revertido a",
+		"Escalated":                    "Escalado",
+		"Dropped During Quiet Hours":   "Descartados en horas de silencio",
+		"Suppressed During Cooldown":   "Suprimidos durante el enfriamiento",
+		"Window":                       "Ventana",
+		"Task Failures":                "Fallos de tareas",
+		"Last Status":                  "Último estado",
+		"Pending For":                  "Pendiente desde hace",
+		"In Progress For":              "En curso desde hace",
+		"Log Group":                    "Grupo de registros",
+		"Log Stream":                   "Flujo de registros",
+		"Matches":                      "Coincidencias",
+		"Certificate":                  "Certificado",
+		"Domain":                       "Dominio",
+		"Days To Expiry":               "Días hasta el vencimiento",
+		"DB Instance":                  "Instancia de BD",
+		"Finding Type":                 "Tipo de hallazgo",
+		"Severity":                     "Gravedad",
+		"Account":                      "Cuenta",
+		"Resource Type":                "Tipo de recurso",
+		"Region":                       "Región",
+		"Status":                       "Estado",
+		"Undelivered":                  "No entregado",
+		"Capacity Provider":            "Proveedor de capacidad",
+		"Unhealthy Containers":         "Contenedores en mal estado",
+		"Restarts":                     "Reinicios",
+		"Likely Fix":                   "Posible solución",
+		"Source":                       "Origen",
+		"Compliance":                   "Cumplimiento",
+		"Full details attached below.": "Detalles completos adjuntos.",
+	},
+	"fr": {
+		"Service":                      "Service",
+		"Cluster":                      "Cluster",
+		"Incident ID":                  "ID d'incident",
+		"Details":                      "Détails",
+		"Time":                         "Heure",
+		"Reason":                       "Raison",
+		"Message":                      "Message",
+		"Event":                        "Événement",
+		"Task ARN":                     "ARN de la tâche",
+		"Deployment":                   "Déploiement",
+		"Rolled Back From":             "Annulé depuis",
+		"Rolled Back To":               "Revenu à",
+		"Escalated":                    "Escaladé",
+		"Dropped During Quiet Hours":   "Ignorées pendant les heures calmes",
+		"Suppressed During Cooldown":   "Supprimées pendant le délai de grâce",
+		"Window":                       "Fenêtre",
+		"Task Failures":                "Échecs de tâches",
+		"Last Status":                  "Dernier statut",
+		"Pending For":                  "En attente depuis",
+		"In Progress For":              "En cours depuis",
+		"Log Group":                    "Groupe de journaux",
+		"Log Stream":                   "Flux de journaux",
+		"Matches":                      "Correspondances",
+		"Certificate":                  "Certificat",
+		"Domain":                       "Domaine",
+		"Days To Expiry":               "Jours avant expiration",
+		"DB Instance":                  "Instance de BD",
+		"Finding Type":                 "Type de résultat",
+		"Severity":                     "Gravité",
+		"Account":                      "Compte",
+		"Resource Type":                "Type de ressource",
+		"Region":                       "Région",
+		"Status":                       "Statut",
+		"Undelivered":                  "Non distribué",
+		"Capacity Provider":            "Fournisseur de capacité",
+		"Unhealthy Containers":         "Conteneurs défaillants",
+		"Restarts":                     "Redémarrages",
+		"Likely Fix":                   "Solution probable",
+		"Source":                       "Source",
+		"Compliance":                   "Conformité",
+		"Full details attached below.": "Détails complets en pièce jointe.",
+	},
+	"pt": {
+		"Service":                      "Serviço",
+		"Cluster":                      "Cluster",
+		"Incident ID":                  "ID do incidente",
+		"Details":                      "Detalhes",
+		"Time":                         "Hora",
+		"Reason":                       "Motivo",
+		"Message":                      "Mensagem",
+		"Event":                        "Evento",
+		"Task ARN":                     "ARN da tarefa",
+		"Deployment":                   "Implantação",
+		"Rolled Back From":             "Revertido de",
+		"Rolled Back To":               "Revertido para",
+		"Escalated":                    "Escalado",
+		"Dropped During Quiet Hours":   "Descartados no horário de silêncio",
+		"Suppressed During Cooldown":   "Suprimidos durante o intervalo",
+		"Window":                       "Janela",
+		"Task Failures":                "Falhas de tarefas",
+		"Last Status":                  "Último status",
+		"Pending For":                  "Pendente há",
+		"In Progress For":              "Em andamento há",
+		"Log Group":                    "Grupo de logs",
+		"Log Stream":                   "Fluxo de logs",
+		"Matches":                      "Correspondências",
+		"Certificate":                  "Certificado",
+		"Domain":                       "Domínio",
+		"Days To Expiry":               "Dias até expirar",
+		"DB Instance":                  "Instância de BD",
+		"Finding Type":                 "Tipo de descoberta",
+		"Severity":                     "Gravidade",
+		"Account":                      "Conta",
+		"Resource Type":                "Tipo de recurso",
+		"Region":                       "Região",
+		"Status":                       "Status",
+		"Undelivered":                  "Não entregue",
+		"Capacity Provider":            "Provedor de capacidade",
+		"Unhealthy Containers":         "Contêineres não íntegros",
+		"Restarts":                     "Reinícios",
+		"Likely Fix":                   "Possível solução",
+		"Source":                       "Origem",
+		"Compliance":                   "Conformidade",
+		"Full details attached below.": "Detalhes completos em anexo.",
+	},
+	"ja": {
+		"Service":                      "サービス",
+		"Cluster":                      "クラスター",
+		"Incident ID":                  "インシデント ID",
+		"Details":                      "詳細",
+		"Time":                         "時刻",
+		"Reason":                       "理由",
+		"Message":                      "メッセージ",
+		"Event":                        "イベント",
+		"Task ARN":                     "タスク ARN",
+		"Deployment":                   "デプロイ",
+		"Rolled Back From":             "ロールバック元",
+		"Rolled Back To":               "ロールバック先",
+		"Escalated":                    "エスカレーション",
+		"Dropped During Quiet Hours":   "サイレント時間中に破棄",
+		"Suppressed During Cooldown":   "クールダウン中に抑制",
+		"Window":                       "期間",
+		"Task Failures":                "タスク失敗",
+		"Last Status":                  "最終ステータス",
+		"Pending For":                  "保留時間",
+		"In Progress For":              "進行時間",
+		"Log Group":                    "ロググループ",
+		"Log Stream":                   "ログストリーム",
+		"Matches":                      "一致",
+		"Certificate":                  "証明書",
+		"Domain":                       "ドメイン",
+		"Days To Expiry":               "有効期限までの日数",
+		"DB Instance":                  "DB インスタンス",
+		"Finding Type":                 "検出結果タイプ",
+		"Severity":                     "重大度",
+		"Account":                      "アカウント",
+		"Resource Type":                "リソースタイプ",
+		"Region":                       "リージョン",
+		"Status":                       "ステータス",
+		"Undelivered":                  "未配信",
+		"Capacity Provider":            "キャパシティープロバイダー",
+		"Unhealthy Containers":         "異常なコンテナ",
+		"Restarts":                     "再起動",
+		"Likely Fix":                   "考えられる対処",
+		"Source":                       "ソース",
+		"Compliance":                   "コンプライアンス",
+		"Full details attached below.": "詳細は添付ファイルを参照してください。",
+	},
+}
+
+// Reduces LOCALE ("de", "de-DE", "de_DE.UTF-8") to a language with bundled
+// translations. English, unset and unknown locales render in English.
+func resolveLocale(locale string) string {
+	lang, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(locale)), ".")
+	lang, _, _ = strings.Cut(lang, "_")
+	lang, _, _ = strings.Cut(lang, "-")
+	if lang == "" || lang == "en" || lang == "c" || lang == "posix" {
+		return ""
+	}
+	if _, ok := translations[lang]; !ok {
+		slog.Warn("No translations for locale, using English", "locale", locale)
+		return ""
+	}
+	return lang
+}
+
+// Translates a fixed label into LOCALE's language, falling back to English
+func translate(english string) string {
+	if translated := translations[cfg.Locale][english]; translated != "" {
+		return translated
+	}
+	return english
+}
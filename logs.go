@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Detail-type given to CloudWatch Logs subscription deliveries once they are
+// wrapped as events, so they go through the same filters as EventBridge ones
+const logsDetailType = "CloudWatch Logs Match"
+
+// Longest log line copied into an alert; stack traces can run much longer
+const logLineMaxLength = 500
+
+// Reports whether a raw invocation payload is a CloudWatch Logs
+// subscription delivery rather than an EventBridge event
+func isLogsPayload(payload json.RawMessage) bool {
+	var probe struct {
+		AWSLogs *json.RawMessage `json:"awslogs"`
+	}
+	return json.Unmarshal(payload, &probe) == nil && probe.AWSLogs != nil
+}
+
+// Decodes the gzip-compressed awslogs.data of a subscription delivery and
+// wraps it as an event whose detail is the decoded batch
+func logsEvent(payload json.RawMessage) (events.CloudWatchEvent, error) {
+	var raw events.CloudwatchLogsEvent
+	if err := json.Unmarshal(payload, &raw); err != nil {
+		return events.CloudWatchEvent{}, fmt.Errorf("failed to unmarshal logs payload: %v", err)
+	}
+	data, err := raw.AWSLogs.Parse()
+	if err != nil {
+		return events.CloudWatchEvent{}, fmt.Errorf("failed to decode awslogs.data: %v", err)
+	}
+	detail, err := json.Marshal(data)
+	if err != nil {
+		return events.CloudWatchEvent{}, err
+	}
+
+	event := events.CloudWatchEvent{
+		DetailType: logsDetailType,
+		Source:     "aws.logs",
+		AccountID:  data.Owner,
+		Region:     cfg.AWSRegion,
+		Time:       time.Now(),
+		Detail:     detail,
+	}
+	if len(data.LogEvents) > 0 {
+		event.ID = data.LogEvents[0].ID
+		event.Time = time.UnixMilli(data.LogEvents[0].Timestamp)
+	}
+	return event, nil
+}
+
+// Builds an alert from the log lines in a delivery that match
+// LOG_ALERT_PATTERN. With no pattern every line matches, leaving the
+// filtering to the subscription filter. ok is false for CloudWatch's
+// control messages and for batches where nothing matched.
+func logsAlert(event events.CloudWatchEvent) (ok bool, alert Alert, err error) {
+	var data events.CloudwatchLogsData
+	if err := decodeDetail(event.Detail, &data); err != nil {
+		return false, Alert{}, fmt.Errorf("failed to unmarshal logs detail: %v", err)
+	}
+	// CloudWatch sends one when the subscription is created, to check the target
+	if data.MessageType == "CONTROL_MESSAGE" {
+		return false, Alert{}, nil
+	}
+
+	var lines []string
+	for _, e := range data.LogEvents {
+		msg := strings.TrimSpace(e.Message)
+		if cfg.LogAlertPattern != nil && !cfg.LogAlertPattern.matches(msg) {
+			continue
+		}
+		lines = append(lines, truncateRunes(msg, logLineMaxLength))
+	}
+	if len(lines) == 0 {
+		return false, Alert{}, nil
+	}
+
+	alert = Alert{
+		DetailType: event.DetailType,
+		Resource:   data.LogGroup + "/" + data.LogStream,
+		Severity:   SeverityCritical,
+		Title:      fmt.Sprintf("Log pattern matched: %s", data.LogGroup),
+		Fields: map[string]string{
+			"Log Group":  data.LogGroup,
+			"Log Stream": data.LogStream,
+			"Matches":    fmt.Sprintf("%d of %d lines", len(lines), len(data.LogEvents)),
+		},
+		Details:   lines,
+		Timestamp: event.Time,
+	}
+	if event.Region != "" {
+		alert.Links = []string{logStreamConsoleLink(event.Region, data.LogGroup, data.LogStream)}
+	}
+	return true, alert, nil
+}
+
+// The console escapes path segments twice, with "$" standing in for the
+// second "%": "/ecs/api" becomes "$252Fecs$252Fapi"
+func logStreamConsoleLink(region, group, stream string) string {
+	escape := func(s string) string {
+		return strings.ReplaceAll(url.QueryEscape(url.QueryEscape(s)), "%", "$")
+	}
+	return fmt.Sprintf("https://%s.console.aws.amazon.com/cloudwatch/home?region=%s#logsV2:log-groups/log-group/%s/log-events/%s",
+		region, region, escape(group), escape(stream))
+}
@@ -4,74 +4,412 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
-	"net/http"
+	"log/slog"
+	"maps"
 	"os"
+	"strconv"
 	"strings"
+	"text/template"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
-	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	tagging "github.com/aws/aws-sdk-go-v2/service/resourcegroupstaggingapi"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/ses"
-	"github.com/aws/aws-sdk-go-v2/service/ses/types"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-xray-sdk-go/instrumentation/awsv2"
+	"github.com/aws/aws-xray-sdk-go/xray"
 )
 
 // Holds the env variables
 type Config struct {
-	SlackWebhookURL   string
-	SenderEmail       string
-	RecipientEmail    string
-	AWSRegion         string
-	MonitoredServices []string
+	SlackWebhookURL      string
+	SlackBotToken        string
+	SlackChannelID       string
+	SlackSnippetAt       int
+	SlackDisableUnfurl   bool
+	SlackThreadByDeploy  bool
+	SecuritySlackURL     string
+	SecurityHubLevels    []string
+	MattermostWebhookURL string
+	MattermostChannel    string
+	GoogleChatWebhookURL string
+	JSONWebhookURL       string
+	WebhookSecret        string
+	PagerDutyRoutingKey  string
+	PagerDutyAutoResolve bool
+	SMSNumbers           []string
+	ChatbotTopicARN      string
+	SenderEmail          string
+	SESIdentityCheck     bool
+	SenderName           string
+	RecipientEmail       string
+	SeverityRecipients   map[Severity][]string
+	AWSRegion            string
+	SESRegions           []string
+	Environment          string
+	Banner               string // NOTIFICATION_BANNER with the environment filled in
+	BannerExternalOnly   bool
+	AccountName          string
+	Location             *time.Location
+	Locale               string // language of alert labels, "" for English
+	MonitoredServices    []string
+	MonitoredTagKey      string
+	MonitoredTagValue    string
+	MonitoredTagTTL      time.Duration
+	AlertUnknownService  bool
+	StateTableName       string
+	DeployTimeout        time.Duration
+	DeployTaskWindow     time.Duration
+	SuppressDuringDeploy bool
+	DeployGrace          time.Duration
+	FirstFailureOnly     bool
+	PendingTimeout       time.Duration
+	AlertOnDeploySuccess bool
+	Cooldown             time.Duration
+	EscalateAfter        int
+	EscalateWindow       time.Duration
+	DedupKeyTemplate     *template.Template
+	CustomDedupKey       bool
+	DedupBypassCritical  bool
+	SubjectTemplates     map[string]*template.Template
+	Playbooks            []playbook
+	AggregateWindow      time.Duration
+	EmailDigest          bool
+	EmailDigestWindow    time.Duration
+	ScalingReasons       []reasonPattern
+	PlacementFailures    []reasonPattern
+	CapacityAlerts       bool
+	UnhealthyAlerts      bool
+	RestartThreshold     int
+	RestartWindow        time.Duration
+	DeployDedupWindow    time.Duration
+	AlertUnknownType     bool
+	ForceAlertReasons    []reasonPattern
+	LogAlertPattern      *reasonPattern
+	IgnoredContainers    []string
+	MinAlertExitCode     int
+	MaxContainerLines    int
+	SeverityRoutes       map[Severity][]string
+	ChannelOrder         []string
+	FallbackChannel      string
+	Profiles             map[string]profile
+	ActiveProfile        string
+	ProfileChannels      []string
+	StopOnFirstSuccess   bool
+	StrictMode           bool
+	InfoSampleRate       float64
+	SeverityIcons        map[Severity]string
+	MinSeverity          Severity
+	QuietHours           *quietHours
+	RDSAlertCategories   []string
+	WebhookMaxRetries    int
+	WebhookRetryOn       []statusRange
+	Sentry               *sentryTarget
+	HeartbeatURL         string
+	SendBudget           time.Duration
+	RepeatWindow         time.Duration
+	MaskARNs             bool
+	DLQQueueURL          string
+	AuditBucket          string
+	SlackEnabled         bool
+	SecuritySlackEnabled bool
+	MattermostEnabled    bool
+	GoogleChatEnabled    bool
+	JSONWebhookEnabled   bool
+	ChatbotEnabled       bool
+	PagerDutyEnabled     bool
+	SMSEnabled           bool
+	EmailEnabled         bool
 }
 
 type ECSDeplomentDetail struct {
-	EventName string `json:"eventName"`
-	Cluster   string `json:"cluster"`
-	Service   string `json:"service"`
-	Reason    string `json:"reason"`
+	EventName    string `json:"eventName"`
+	DeploymentID string `json:"deploymentId"`
+	Cluster      string `json:"cluster"`
+	Service      string `json:"service"`
+	Reason       string `json:"reason"`
 }
 
 type ECSTaskDetail struct {
-	ClusterArn    string          `json:"clusterArn"`
-	TaskArn       string          `json:"taskArn"`
-	Group         string          `json:"group"`
-	LastStatus    string          `json:"lastStatus"`
-	StoppedReason string          `json:"stoppedReason"`
-	Containers    []ContainerInfo `json:"containers"`
+	ClusterArn        string          `json:"clusterArn"`
+	TaskArn           string          `json:"taskArn"`
+	TaskDefinitionArn string          `json:"taskDefinitionArn"`
+	Group             string          `json:"group"`
+	LastStatus        string          `json:"lastStatus"`
+	StoppedReason     string          `json:"stoppedReason"`
+	StartedBy         string          `json:"startedBy"`
+	Containers        []ContainerInfo `json:"containers"`
+	// Set for tasks launched through a capacity provider strategy
+	CapacityProviderName string `json:"capacityProviderName"`
 }
 
 type ContainerInfo struct {
-	Name     string `json:"name"`
-	Image    string `json:"image"`
-	ExitCode int    `json:"exitCode"`
-	Reason   string `json:"reason"`
+	Name            string           `json:"name"`
+	Image           string           `json:"image"`
+	ExitCode        int              `json:"exitCode"`
+	Reason          string           `json:"reason"`
+	RuntimeID       string           `json:"runtimeId"`
+	HealthStatus    string           `json:"healthStatus"`
+	NetworkBindings []NetworkBinding `json:"networkBindings"`
+}
+
+type NetworkBinding struct {
+	BindIP        string `json:"bindIP"`
+	ContainerPort int    `json:"containerPort"`
+	HostPort      int    `json:"hostPort"`
+	Protocol      string `json:"protocol"`
 }
 
 var (
-	sesClient *ses.Client
-	cfg       Config
+	sesClient  *ses.Client
+	cfg        Config
+	baseLogger = slog.New(slog.NewJSONHandler(os.Stdout, nil))
 )
 
 func init() {
-	servicesEnv := os.Getenv("MONITORED_SERVICES")
-	var servicesList []string
-	if servicesEnv != "" {
-		servicesList = strings.Split(servicesEnv, ",")
-		// Trim spaces just in case
-		for i := range servicesList {
-			servicesList[i] = strings.TrimSpace(servicesList[i])
+	slog.SetDefault(baseLogger)
+
+	scalingPatterns, err := parseReasonPatterns(envListDefault("SCALING_REASON_PATTERNS", defaultScalingReasonPatterns))
+	if err != nil {
+		log.Fatalf("invalid SCALING_REASON_PATTERNS, %v", err)
+	}
+
+	placementPatterns, err := parseReasonPatterns(envListDefault("PLACEMENT_FAILURE_PATTERNS", defaultPlacementFailurePatterns))
+	if err != nil {
+		log.Fatalf("invalid PLACEMENT_FAILURE_PATTERNS, %v", err)
+	}
+
+	forcePatterns, err := parseReasonPatterns(envList("FORCE_ALERT_REASONS"))
+	if err != nil {
+		log.Fatalf("invalid FORCE_ALERT_REASONS, %v", err)
+	}
+
+	var logPattern *reasonPattern
+	if spec := os.Getenv("LOG_ALERT_PATTERN"); spec != "" {
+		patterns, err := parseReasonPatterns([]string{spec})
+		if err != nil {
+			log.Fatalf("invalid LOG_ALERT_PATTERN, %v", err)
 		}
+		logPattern = &patterns[0]
+	}
+
+	channelOrder, err := parseChannelOrder(envList("CHANNEL_ORDER"))
+	if err != nil {
+		log.Fatalf("invalid CHANNEL_ORDER, %v", err)
+	}
+
+	retryStatuses, err := parseRetryStatuses(envListDefault("WEBHOOK_RETRY_STATUSES", defaultWebhookRetryStatuses))
+	if err != nil {
+		log.Fatalf("invalid WEBHOOK_RETRY_STATUSES, %v", err)
+	}
+
+	securityHubLevels, err := parseSecurityHubSeverities(envListDefault("SECURITY_HUB_SEVERITIES", defaultSecurityHubSeverities))
+	if err != nil {
+		log.Fatalf("invalid SECURITY_HUB_SEVERITIES, %v", err)
+	}
+
+	fallback, err := parseChannelOrder(envList("FALLBACK_CHANNEL"))
+	if err != nil {
+		log.Fatalf("invalid FALLBACK_CHANNEL, %v", err)
+	}
+	if len(fallback) > 1 {
+		log.Fatalf("invalid FALLBACK_CHANNEL, only one channel can be the fallback")
+	}
+
+	sentry, err := parseSentryDSN(envTarget("SENTRY_DSN"))
+	if err != nil {
+		log.Fatalf("invalid SENTRY_DSN, %v", err)
+	}
+
+	severityRoutes, err := parseSeverityRoutes(os.Getenv("SEVERITY_ROUTES"))
+	if err != nil {
+		log.Fatalf("invalid SEVERITY_ROUTES, %v", err)
+	}
+
+	// Set but empty means no icons at all, so unset is told apart from empty
+	iconSpec, ok := os.LookupEnv("SEVERITY_ICONS")
+	if !ok {
+		iconSpec = defaultSeverityIcons
+	}
+	severityIcons, err := parseSeverityIcons(iconSpec)
+	if err != nil {
+		log.Fatalf("invalid SEVERITY_ICONS, %v", err)
 	}
+
+	minSeverity, err := parseMinSeverity(os.Getenv("MIN_SEVERITY"))
+	if err != nil {
+		log.Fatalf("invalid MIN_SEVERITY, %v", err)
+	}
+
+	quietHours, err := parseQuietHours(os.Getenv("QUIET_HOURS"))
+	if err != nil {
+		log.Fatalf("invalid QUIET_HOURS, %v", err)
+	}
+
+	smsNumbers, err := parseSMSNumbers(splitList(envTarget("SMS_NUMBERS")))
+	if err != nil {
+		log.Fatalf("invalid SMS_NUMBERS, %v", err)
+	}
+
+	subjectTemplates, err := parseSubjectTemplates(os.Getenv("SUBJECT_TEMPLATE"))
+	if err != nil {
+		log.Fatalf("invalid SUBJECT_TEMPLATE, %v", err)
+	}
+
+	monitoredTagKey, monitoredTagValue, err := parseMonitoredTag(os.Getenv("MONITORED_SERVICES_TAG"))
+	if err != nil {
+		log.Fatalf("invalid MONITORED_SERVICES_TAG, %v", err)
+	}
+
+	playbooks, err := parsePlaybooks(os.Getenv("PLAYBOOKS"))
+	if err != nil {
+		log.Fatalf("invalid PLAYBOOKS, %v", err)
+	}
+
+	profiles, err := parseProfiles(os.Getenv("PROFILES"))
+	if err != nil {
+		log.Fatalf("invalid PROFILES, %v", err)
+	}
+	activeProfile := os.Getenv("ACTIVE_PROFILE")
+	if _, ok := profiles[activeProfile]; activeProfile != "" && !ok {
+		log.Fatalf("invalid ACTIVE_PROFILE, no profile %q in PROFILES", activeProfile)
+	}
+
+	dedupTemplate, err := parseDedupKeyTemplate(envDefault("DEDUP_KEY_TEMPLATE", defaultDedupKeyTemplate))
+	if err != nil {
+		log.Fatalf("invalid DEDUP_KEY_TEMPLATE, %v", err)
+	}
+
 	// Load configuration from environment variables or a config file
 	cfg = Config{
-		SlackWebhookURL:   os.Getenv("SLACK_WEBHOOK_URL"),
-		SenderEmail:       os.Getenv("SENDER_EMAIL"),
-		RecipientEmail:    os.Getenv("RECIPIENT_EMAIL"),
-		AWSRegion:         os.Getenv("AWS_REGION"),
-		MonitoredServices: servicesList,
+		SlackWebhookURL:      envTarget("SLACK_WEBHOOK_URL"),
+		SlackBotToken:        os.Getenv("SLACK_BOT_TOKEN"),
+		SlackChannelID:       envTarget("SLACK_CHANNEL_ID"),
+		SlackSnippetAt:       envInt("SLACK_SNIPPET_THRESHOLD", 3000),
+		SlackDisableUnfurl:   envBool("SLACK_DISABLE_UNFURL", true),
+		SlackThreadByDeploy:  envBool("SLACK_THREAD_BY_DEPLOYMENT", false),
+		SecuritySlackURL:     envTarget("SECURITY_SLACK_WEBHOOK_URL"),
+		SecurityHubLevels:    securityHubLevels,
+		MattermostWebhookURL: envTarget("MATTERMOST_WEBHOOK_URL"),
+		MattermostChannel:    envTarget("MATTERMOST_CHANNEL"),
+		GoogleChatWebhookURL: envTarget("GOOGLE_CHAT_WEBHOOK_URL"),
+		JSONWebhookURL:       envTarget("JSON_WEBHOOK_URL"),
+		WebhookSecret:        envTarget("WEBHOOK_SIGNING_SECRET"),
+		ChatbotTopicARN:      envTarget("CHATBOT_SNS_TOPIC_ARN"),
+		PagerDutyRoutingKey:  envTarget("PAGERDUTY_ROUTING_KEY"),
+		PagerDutyAutoResolve: envBool("PAGERDUTY_AUTO_RESOLVE", false),
+		SMSNumbers:           smsNumbers,
+		SenderEmail:          os.Getenv("SENDER_EMAIL"),
+		SESIdentityCheck:     envBool("SES_IDENTITY_CHECK", true),
+		SenderName:           os.Getenv("SENDER_NAME"),
+		RecipientEmail:       envTarget("RECIPIENT_EMAIL"),
+		SeverityRecipients: map[Severity][]string{
+			SeverityCritical: splitList(envTarget("RECIPIENT_EMAIL_CRITICAL")),
+			SeverityWarning:  splitList(envTarget("RECIPIENT_EMAIL_WARNING")),
+			SeverityInfo:     splitList(envTarget("RECIPIENT_EMAIL_INFO")),
+		},
+		AWSRegion:            os.Getenv("AWS_REGION"),
+		SESRegions:           envList("SES_REGIONS"),
+		Environment:          os.Getenv("ENVIRONMENT"),
+		Banner:               strings.ReplaceAll(os.Getenv("NOTIFICATION_BANNER"), "{environment}", os.Getenv("ENVIRONMENT")),
+		BannerExternalOnly:   envBool("BANNER_EXTERNAL_ONLY", false),
+		AccountName:          os.Getenv("ACCOUNT_NAME"),
+		Location:             resolveLocation(os.Getenv("TIMEZONE"), os.Getenv("AWS_REGION")),
+		Locale:               resolveLocale(os.Getenv("LOCALE")),
+		MonitoredServices:    envList("MONITORED_SERVICES"),
+		MonitoredTagKey:      monitoredTagKey,
+		MonitoredTagValue:    monitoredTagValue,
+		MonitoredTagTTL:      time.Duration(envInt("MONITORED_TAG_TTL_SECONDS", 300)) * time.Second,
+		AlertUnknownService:  envBool("ALERT_ON_UNKNOWN_SERVICE", true),
+		StateTableName:       os.Getenv("STATE_TABLE_NAME"),
+		DeployTimeout:        time.Duration(envInt("DEPLOY_TIMEOUT_MINUTES", 30)) * time.Minute,
+		DeployTaskWindow:     time.Duration(envInt("TASK_CORRELATION_SECONDS", 300)) * time.Second,
+		SuppressDuringDeploy: envBool("SUPPRESS_DURING_DEPLOY", false),
+		DeployGrace:          time.Duration(envInt("DEPLOY_GRACE_SECONDS", 120)) * time.Second,
+		FirstFailureOnly:     envBool("ALERT_FIRST_FAILURE_ONLY", false),
+		PendingTimeout:       time.Duration(envInt("PENDING_TIMEOUT_SECONDS", 600)) * time.Second,
+		AlertOnDeploySuccess: envBool("ALERT_ON_DEPLOY_SUCCESS", false),
+		Cooldown:             time.Duration(envInt("COOLDOWN_SECONDS", 0)) * time.Second,
+		EscalateAfter:        envInt("ESCALATE_AFTER", 0),
+		EscalateWindow:       time.Duration(envInt("ESCALATE_WINDOW_SECONDS", 3600)) * time.Second,
+		DedupKeyTemplate:     dedupTemplate,
+		CustomDedupKey:       os.Getenv("DEDUP_KEY_TEMPLATE") != "",
+		DedupBypassCritical:  envBool("DEDUP_BYPASS_CRITICAL", true),
+		SubjectTemplates:     subjectTemplates,
+		Playbooks:            playbooks,
+		AggregateWindow:      time.Duration(envInt("AGGREGATE_WINDOW_SECONDS", 0)) * time.Second,
+		EmailDigest:          envBool("EMAIL_DIGEST", false),
+		EmailDigestWindow:    time.Duration(envInt("EMAIL_DIGEST_SECONDS", 300)) * time.Second,
+		ScalingReasons:       scalingPatterns,
+		PlacementFailures:    placementPatterns,
+		CapacityAlerts:       envBool("ALERT_ON_CAPACITY_FAILURES", true),
+		UnhealthyAlerts:      envBool("ALERT_ON_UNHEALTHY_TASKS", false),
+		RestartThreshold:     envInt("RESTART_ALERT_THRESHOLD", 0),
+		RestartWindow:        time.Duration(envInt("RESTART_WINDOW_SECONDS", 600)) * time.Second,
+		DeployDedupWindow:    time.Duration(envInt("DEPLOYMENT_DEDUP_SECONDS", 300)) * time.Second,
+		AlertUnknownType:     envBool("ALERT_ON_UNKNOWN_DETAIL_TYPE", false),
+		ForceAlertReasons:    forcePatterns,
+		LogAlertPattern:      logPattern,
+		IgnoredContainers:    envList("IGNORED_CONTAINERS"),
+		MinAlertExitCode:     envInt("MIN_ALERT_EXIT_CODE", 1),
+		MaxContainerLines:    envInt("MAX_CONTAINER_LINES", 10),
+		SeverityRoutes:       severityRoutes,
+		ChannelOrder:         channelOrder,
+		FallbackChannel:      strings.Join(fallback, ""),
+		Profiles:             profiles,
+		ActiveProfile:        activeProfile,
+		StopOnFirstSuccess:   envBool("STOP_ON_FIRST_SUCCESS", false),
+		StrictMode:           envBool("STRICT_MODE", false),
+		InfoSampleRate:       envFloat("INFO_SAMPLE_RATE", 1),
+		SeverityIcons:        severityIcons,
+		MinSeverity:          minSeverity,
+		QuietHours:           quietHours,
+		RDSAlertCategories:   envListDefault("RDS_ALERT_CATEGORIES", defaultRDSAlertCategories),
+		WebhookMaxRetries:    envInt("WEBHOOK_MAX_RETRIES", 2),
+		WebhookRetryOn:       retryStatuses,
+		Sentry:               sentry,
+		HeartbeatURL:         envTarget("HEARTBEAT_URL"),
+		SendBudget:           time.Duration(envInt("SEND_BUDGET_SECONDS", 10)) * time.Second,
+		RepeatWindow:         time.Duration(envInt("REPEAT_WINDOW_SECONDS", 0)) * time.Second,
+		MaskARNs:             envBool("MASK_ARNS", false),
+		DLQQueueURL:          os.Getenv("DLQ_SQS_URL"),
+		AuditBucket:          os.Getenv("AUDIT_LOG_BUCKET"),
+		SlackEnabled:         envBool("SLACK_ENABLED", true),
+		SecuritySlackEnabled: envBool("SECURITY_SLACK_ENABLED", true),
+		MattermostEnabled:    envBool("MATTERMOST_ENABLED", true),
+		GoogleChatEnabled:    envBool("GOOGLE_CHAT_ENABLED", true),
+		JSONWebhookEnabled:   envBool("JSON_WEBHOOK_ENABLED", true),
+		ChatbotEnabled:       envBool("CHATBOT_ENABLED", true),
+		PagerDutyEnabled:     envBool("PAGERDUTY_ENABLED", true),
+		SMSEnabled:           envBool("SMS_ENABLED", true),
+		EmailEnabled:         envBool("EMAIL_ENABLED", true),
+	}
+	if cfg.SendBudget <= 0 {
+		log.Fatalf("invalid SEND_BUDGET_SECONDS, must be positive")
+	}
+	if cfg.InfoSampleRate < 0 || cfg.InfoSampleRate > 1 {
+		log.Fatalf("invalid INFO_SAMPLE_RATE, must be between 0 and 1")
+	}
+	if cfg.EmailDigest && cfg.EmailDigestWindow <= 0 {
+		log.Fatalf("invalid EMAIL_DIGEST_SECONDS, must be positive")
+	}
+	if cfg.RestartThreshold > 0 && cfg.RestartWindow <= 0 {
+		log.Fatalf("invalid RESTART_WINDOW_SECONDS, must be positive")
+	}
+	if cfg.SlackSnippetAt < minSlackSnippetThreshold {
+		log.Fatalf("invalid SLACK_SNIPPET_THRESHOLD, must be at least %d", minSlackSnippetThreshold)
 	}
 
 	// Initialize AWS SDK
@@ -80,172 +418,729 @@ func init() {
 		log.Fatalf("unable to load SDK config, %v", err)
 	}
 
+	// With tracing on, every AWS call and outbound webhook becomes a
+	// subsegment of the invocation's X-Ray segment. This has to happen
+	// before any client is built from awsCfg.
+	if envBool("XRAY_ENABLED", false) {
+		awsv2.AWSV2Instrumentor(&awsCfg.APIOptions)
+		httpClient = xray.Client(httpClient)
+	}
+
+	// A webhook kept in SSM wins over the plain variable, which stays as the
+	// fallback if the parameter can't be read
+	if param := envTarget("SLACK_WEBHOOK_SSM_PARAM"); param != "" {
+		webhookURL, err := fetchSSMParameter(context.TODO(), ssm.NewFromConfig(awsCfg), param)
+		if err != nil {
+			slog.Warn("Unable to read Slack webhook from SSM, using SLACK_WEBHOOK_URL", "parameter", param, "error", err)
+		} else {
+			cfg.SlackWebhookURL = webhookURL
+		}
+	}
+
 	// Create SES client
 	sesClient = ses.NewFromConfig(awsCfg)
+	sesAWSConfig = awsCfg
+	sesFallbacks, err = parseSESFallbacks(os.Getenv("SENDER_EMAIL_FALLBACKS"), awsCfg)
+	if err != nil {
+		log.Fatalf("invalid SENDER_EMAIL_FALLBACKS, %v", err)
+	}
+
+	if envBool("ATTACH_METRIC_GRAPH", false) {
+		cloudwatchClient = cloudwatch.NewFromConfig(awsCfg)
+	}
+
+	// State-backed features stay off unless a table is configured
+	if cfg.StateTableName != "" {
+		dynamoClient = dynamodb.NewFromConfig(awsCfg)
+		states = dynamoStore{client: dynamoClient, table: cfg.StateTableName}
+		durableStates = true
+	}
+
+	if cfg.ChatbotTopicARN != "" || len(cfg.SMSNumbers) > 0 {
+		snsClient = sns.NewFromConfig(awsCfg)
+	}
+
+	if envBool("ENRICH_FROM_API", false) {
+		ecsClient = ecs.NewFromConfig(awsCfg)
+	}
+
+	if cfg.MonitoredTagKey != "" {
+		taggingClient = tagging.NewFromConfig(awsCfg)
+	}
+
+	if cfg.AuditBucket != "" {
+		s3Client = s3.NewFromConfig(awsCfg)
+	}
+
+	if envBool("ACCOUNT_ALIAS_LOOKUP", false) && cfg.AccountName == "" {
+		iamClient = iam.NewFromConfig(awsCfg)
+	}
+
+	if cfg.DLQQueueURL != "" {
+		sqsClient = sqs.NewFromConfig(awsCfg)
+	}
+
+	registerNotifiers()
 }
 
-func handleRequest(ctx context.Context, event events.CloudWatchEvent) error {
-	log.Printf("Received event: %v", event.DetailType)
+func handleRequest(ctx context.Context, event events.CloudWatchEvent) (err error) {
+	// Every log line for this invocation carries the event ID so one event
+	// can be followed across lines in CloudWatch Logs Insights. The Lambda
+	// runtime handles one invocation at a time per process, so swapping the
+	// default logger here is safe.
+	slog.SetDefault(baseLogger.With("correlation_id", event.ID))
+	invocationEventID = event.ID
+	summary = invocationSummary{}
+	defer func() {
+		if auditErr := flushAuditLog(ctx); auditErr != nil {
+			slog.Error("Error writing audit log", "error", auditErr)
+		}
+		summary.log(event.DetailType, err)
+	}()
 
-	var message string
-	var subject string
-	isAlert := false
+	slog.Info("Received event", "detail_type", event.DetailType)
 
-	switch event.DetailType {
-	case "ECS Deployment State Change":
-		var detail ECSDeplomentDetail
-		if err := json.Unmarshal(event.Detail, &detail); err != nil {
-			log.Printf("Error unmarshalling ECS deployment detail: %v", err)
-			return err
+	// Sweeps check the allow-list too, so this runs before them
+	refreshMonitoredServices(ctx)
+
+	if event.DetailType == "Scheduled Event" {
+		summary.decide("sweep", "")
+		return runScheduledSweeps(ctx)
+	}
+
+	if event.DetailType == configCheckDetailType {
+		summary.decide("config_check", "")
+		var report strings.Builder
+		passed := runConfigCheck(ctx, &report)
+		slog.Info("Config check finished", "passed", passed, "report", report.String())
+		return nil
+	}
+
+	if event.DetailType == securityHubDetailType {
+		var detail SecurityHubDetail
+		if err := decodeDetail(event.Detail, &detail); err != nil {
+			return rejectUnparseable(ctx, event, fmt.Errorf("failed to unmarshal Security Hub findings: %v", err))
 		}
-		message = fmt.Sprintf("ECS Deployment Event: %s\nCluster: %s\nService: %s\nReason: %s",
-			detail.EventName, detail.Cluster, detail.Service, detail.Reason)
-		subject = "ECS Deployment Alert"
-		isAlert = true
+		return notifySecurityHubFindings(ctx, event, detail)
+	}
 
-		if detail.EventName == "SERVICE_DEPLOYMENT_FAILED" {
-			isAlert = true
-			subject = fmt.Sprintf("ECS Service Rollback/Failure: %s", getResourceName(detail.Service))
-			message = fmt.Sprintf("*Service:* %s\n*Event:* %s\n*Reason:* %s\n*Cluster:* %s",
-				getResourceName(detail.Service), detail.EventName, detail.Reason, getResourceName(detail.Cluster))
+	if event.DetailType == "ECS Deployment State Change" {
+		if err := trackDeploymentEvent(ctx, event); err != nil {
+			slog.Error("Error tracking deployment", "error", err)
+		}
+		// The failures ALERT_FIRST_FAILURE_ONLY held back are reported once
+		// the deployment is over, whatever becomes of the event itself
+		ok, failures, err := deploymentFailureSummary(ctx, event)
+		if err != nil {
+			slog.Error("Error summarising deployment task failures", "error", err)
 		}
+		if ok {
+			sendCtx, cancel := context.WithTimeout(ctx, cfg.SendBudget)
+			notify(sendCtx, failures)
+			cancel()
+		}
+	}
 
+	if err := resolvePagerDutyIncident(ctx, event); err != nil {
+		slog.Error("Error resolving PagerDuty incident", "error", err)
+	}
+	if err := resetRestartCount(ctx, event); err != nil {
+		slog.Error("Error resetting restart count", "error", err)
+	}
+
+	if event.DetailType == "ECS Task State Change" {
+		if err := trackPendingTask(ctx, event); err != nil {
+			slog.Error("Error tracking pending task", "error", err)
+		}
+		if err := trackTaskHealth(ctx, event); err != nil {
+			slog.Error("Error tracking task health", "error", err)
+		}
+	}
+
+	ok, alert, skipReason, err := shouldAlert(event)
+	if err != nil {
+		return rejectUnparseable(ctx, event, err)
+	}
+	if !ok {
+		slog.Info("Event processed, no alert sent", "reason", skipReason)
+		summary.decide("skipped", skipReason)
+		return nil
+	}
+
+	reason, err := suppressReason(ctx, &alert)
+	if err != nil {
+		// STRICT_MODE: the state lookup may work on a retry, and sending
+		// without it could send duplicates
+		return err
+	}
+	if reason != "" {
+		slog.Info("Alert suppressed", "reason", reason, "service", alert.Service)
+		summary.decide("suppressed", reason)
+		return nil
+	}
+
+	if isDeploymentFailure(alert) {
+		if err := recordDeploymentFailure(ctx, alert); err != nil {
+			slog.Error("Error recording deployment failure", "error", err)
+		}
+		changes, err := taskDefinitionChanges(ctx, event)
+		if err != nil {
+			slog.Warn("Error comparing task definitions, sending without the diff", "error", err)
+		}
+		alert.Details = append(alert.Details, changes...)
+	}
+
+	// Caps the time all channels together may take, so a slow provider
+	// cancels the remaining sends instead of running the Lambda to its timeout
+	sendCtx, cancel := context.WithTimeout(ctx, cfg.SendBudget)
+	defer cancel()
+	notify(sendCtx, alert)
+	summary.decide("alerted", "")
+	return nil
+}
+
+// A malformed event fails the same way on every retry. With a DLQ
+// configured, park it there and report success so Lambda moves on;
+// STRICT_MODE keeps retries for transient errors, so it drops it.
+func rejectUnparseable(ctx context.Context, event events.CloudWatchEvent, err error) error {
+	if sqsClient == nil {
+		if cfg.StrictMode {
+			slog.Error("Dropping unparseable event", "detail_type", event.DetailType, "error", err)
+			summary.decide("dropped", err.Error())
+			return nil
+		}
+		return err
+	}
+	if dlqErr := sendToDLQ(ctx, event, err); dlqErr != nil {
+		slog.Error("Error sending event to DLQ", "error", dlqErr)
+		return err
+	}
+	summary.decide("dead_lettered", err.Error())
+	return nil
+}
+
+// Entry point for every invocation. Selects the PROFILES entry to use, then
+// unwraps CloudWatch Logs subscription payloads, which aren't EventBridge
+// events, into one, or handles each event of an SQS batch. Only an SQS
+// batch has a response: the messages SQS should deliver again. Successful
+// invocations ping HEARTBEAT_URL.
+func handleInvocation(ctx context.Context, payload json.RawMessage) (response any, err error) {
+	defer useProfile(profileName(payload))()
+	defer func() {
+		if err == nil {
+			pingHeartbeat(ctx)
+		}
+	}()
+
+	if isLogsPayload(payload) {
+		event, err := logsEvent(payload)
+		if err != nil {
+			slog.Error("Error decoding CloudWatch Logs payload", "error", err)
+			return nil, err
+		}
+		return nil, handleRequest(ctx, event)
+	}
+	if isSQSPayload(payload) {
+		return handleSQSBatch(ctx, payload)
+	}
+	var event events.CloudWatchEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal event: %v", err)
+	}
+	return nil, handleRequest(ctx, event)
+}
+
+// Work driven by the schedule rule rather than by an ECS event. The sweeps
+// read and write state under ctx; what they send shares one
+// SEND_BUDGET_SECONDS deadline, as an event's alert does.
+func runScheduledSweeps(ctx context.Context) error {
+	sendCtx, cancel := context.WithTimeout(ctx, cfg.SendBudget)
+	defer cancel()
+	return errors.Join(
+		sweepStuckDeployments(ctx, sendCtx),
+		sweepPendingTasks(ctx, sendCtx),
+		flushTaskAggregates(ctx, sendCtx),
+		flushEmailDigests(ctx, sendCtx),
+		checkSESIdentities(ctx, sendCtx),
+	)
+}
+
+// Decides from the event alone (plus static config) whether it is worth an
+// alert. skipReason explains a false result; err is only set when the event
+// detail can't be parsed.
+func shouldAlert(event events.CloudWatchEvent) (ok bool, alert Alert, skipReason string, err error) {
+	switch event.DetailType {
+	case guardDutyDetailType:
+		ok, alert, err = guardDutyAlert(event)
+		if err != nil {
+			return false, Alert{}, "", err
+		}
+		// Findings aren't about ECS services either
+		return true, alert, "", nil
+	case logsDetailType:
+		ok, alert, err = logsAlert(event)
+		if err != nil || !ok {
+			return false, Alert{}, "no log lines matched LOG_ALERT_PATTERN", err
+		}
+		return true, alert, "", nil
+	case acmExpiryDetailType:
+		ok, alert, err = acmExpiryAlert(event)
+		if err != nil {
+			return false, Alert{}, "", err
+		}
+		return true, alert, "", nil
+	case "RDS DB Instance Event":
+		ok, alert, err = rdsAlert(event)
+		if err != nil || !ok {
+			return false, Alert{}, "no alerting RDS event category", err
+		}
+		// MONITORED_SERVICES only filters ECS services
+		return true, alert, "", nil
+	case "ECS Deployment State Change":
+		ok, alert, err = deploymentAlert(event)
 	case "ECS Task State Change":
-		var detail ECSTaskDetail
-		if err := json.Unmarshal(event.Detail, &detail); err != nil {
-			return fmt.Errorf("failed to unmarshal task detail: %v", err)
-		}
-		serviceName := getServiceNameFromGroup(detail.Group)
-
-		// We only care if the task STOPPED and it wasn't a manual stop (exit code != 0)
-		if detail.LastStatus == "STOPPED" {
-			failedContainerFound := false
-			failureDetails := ""
-
-			for _, c := range detail.Containers {
-				// ExitCode is an int, check if it's non-zero
-				if c.ExitCode != 0 {
-					failedContainerFound = true
-					failureDetails += fmt.Sprintf("- Container '%s' exited with code %d (%s)\n", c.Name, c.ExitCode, c.Reason)
-				}
-			}
+		ok, alert, err = taskAlert(event)
+	case serviceActionDetailType:
+		ok, alert, err = capacityAlert(event)
+	default:
+		if !cfg.AlertUnknownType {
+			return false, Alert{}, "unhandled detail type", nil
+		}
+		// Not about a known service, so MONITORED_SERVICES doesn't apply
+		return true, unknownEventAlert(event), "", nil
+	}
 
-			// Also catch tasks that failed to start (no exit code, but stopped reason exists)
-			if !failedContainerFound && detail.StoppedReason != "" && detail.StoppedReason != "Scaling activity initiated by (deployment ...)" {
-				// Filter out normal scaling down events
-				if !strings.Contains(detail.StoppedReason, "Scaling activity") && !strings.Contains(detail.StoppedReason, "Service scheduler") {
-					failedContainerFound = true
-					failureDetails += fmt.Sprintf("- Task stopped: %s\n", detail.StoppedReason)
-				}
-			}
+	if err != nil {
+		return false, Alert{}, "", err
+	}
+	if !ok {
+		return false, Alert{}, "no alert conditions met", nil
+	}
+	if !isMonitored(alert.Service) {
+		return false, alert, fmt.Sprintf("service '%s' not in allowed list", alert.Service), nil
+	}
+	return true, alert, "", nil
+}
 
-			if failedContainerFound {
-				isAlert = true
-				subject = fmt.Sprintf("⚠️ ECS Task Failure: %s", serviceName)
-				message = fmt.Sprintf("*Service:* %s\n*Task ARN:* %s\n*Failure Details:*\n%s",
-					serviceName, detail.TaskArn, failureDetails)
-			}
+// Longest raw detail an unknown-event alert carries
+const unknownDetailMaxRunes = 2000
+
+// With ALERT_ON_UNKNOWN_DETAIL_TYPE, an info alert for an event no handler
+// knows, carrying its raw detail, so new event types get noticed
+func unknownEventAlert(event events.CloudWatchEvent) Alert {
+	alert := Alert{
+		DetailType: event.DetailType,
+		Severity:   SeverityInfo,
+		Title:      fmt.Sprintf("Unhandled event type %s received", event.DetailType),
+		Fields: map[string]string{
+			"Source":  event.Source,
+			"Region":  event.Region,
+			"Account": event.AccountID,
+		},
+		Details:   []string{truncateRunes(string(bytes.TrimSpace(event.Detail)), unknownDetailMaxRunes)},
+		Timestamp: event.Time,
+	}
+	if len(event.Resources) > 0 {
+		alert.Resource = event.Resources[0]
+	}
+	return alert
+}
+
+// Checks that need state from earlier invocations. Returns why the alert
+// should be dropped, or "" to send it; may annotate the alert on the way.
+// The error is only set in STRICT_MODE, when one of the lookups failed.
+func suppressReason(ctx context.Context, alert *Alert) (string, error) {
+	if !sampleInfoAlert(alert) {
+		return "info alert not sampled", nil
+	}
+
+	if alert.DetailType == "ECS Deployment State Change" {
+		duplicate, err := isDuplicateDeploymentEvent(ctx, *alert)
+		if err := stateError(err, "Error checking for a duplicate deployment event", "deployment", alert.Deployment); err != nil {
+			return "", err
+		}
+		if duplicate {
+			return "deployment event already received", nil
 		}
 	}
-	var serviceName string
-	if event.DetailType == "ECS Task State Change" {
-		var detail ECSTaskDetail
-		if err := json.Unmarshal(event.Detail, &detail); err == nil {
-			serviceName = getServiceNameFromGroup(detail.Group)
+
+	if alert.DetailType == unhealthyTaskDetailType {
+		// A task stays UNHEALTHY over several events; only the flip alerts
+		repeat, err := isRepeatTaskStatus(ctx, alert.Resource, "UNHEALTHY")
+		if err := stateError(err, "Error checking previous task health", "task_arn", alert.Resource); err != nil {
+			return "", err
 		}
-	} else if event.DetailType == "ECS Deployment State Change" {
-		var detail ECSDeplomentDetail
-		if err := json.Unmarshal(event.Detail, &detail); err == nil {
-			serviceName = getResourceName(detail.Service)
+		if repeat {
+			return "task health already reported", nil
+		}
+
+		deploying, err := isDeploying(ctx, *alert)
+		if err := stateError(err, "Error checking for a deployment in progress", "service", alert.Service); err != nil {
+			return "", err
+		}
+		if deploying {
+			return "service is deploying", nil
 		}
 	}
-	if len(cfg.MonitoredServices) > 0 {
-		if !contains(cfg.MonitoredServices, serviceName) {
-			log.Printf("Skipping alert for service '%s' (not in allowed list)", serviceName)
-			return nil
+
+	if alert.DetailType == "ECS Task State Change" {
+		// ECS re-emits STOPPED events; only the transition into STOPPED alerts
+		repeat, err := isRepeatTaskStatus(ctx, alert.Resource, "STOPPED")
+		if err := stateError(err, "Error checking previous task status", "task_arn", alert.Resource); err != nil {
+			return "", err
+		}
+		if repeat {
+			return "task status already reported", nil
+		}
+		// FORCE_ALERT_REASONS always alert, so nothing below may hold them
+		// back: not a deployment or scale-in, quiet hours or cooldown
+		if alert.Forced {
+			return "", nil
+		}
+
+		deploying, err := isDeploying(ctx, *alert)
+		if err := stateError(err, "Error checking for a deployment in progress", "service", alert.Service); err != nil {
+			return "", err
+		}
+		if deploying {
+			return "service is deploying", nil
+		}
+
+		scaleIn, err := isAPIScaleIn(ctx, *alert)
+		if err := stateError(err, "Error checking service for scale-in", "service", alert.Service); err != nil {
+			return "", err
+		}
+		if scaleIn {
+			return "service was scaling in", nil
+		}
+
+		restarts, err := countRestart(ctx, *alert)
+		if err := stateError(err, "Error counting task restarts", "family", alert.TaskFamily); err != nil {
+			return "", err
+		}
+		if restarts > 0 {
+			// A crash loop is what the restarts were building up to, so it
+			// pages and skips the grouping and cooldown below
+			alert.Severity = SeverityCritical
+			alert.Escalated = true
+			alert.setField("Restarts", fmt.Sprintf("%d restarts in %s — likely crash loop", restarts, restartWindowText()))
+			return "", nil
+		}
+
+		first, err := isFirstDeploymentFailure(ctx, *alert)
+		if err := stateError(err, "Error counting deployment task failures", "deployment", alert.Deployment); err != nil {
+			return "", err
+		}
+		if !first {
+			return "deployment already reported a task failure", nil
+		}
+
+		correlated, err := followsDeploymentFailure(ctx, *alert)
+		if err := stateError(err, "Error checking for a recent deployment failure"); err != nil {
+			return "", err
+		}
+		if correlated {
+			return "part of a deployment failure already reported for the service", nil
+		}
+
+		aggregated, err := aggregateTaskFailure(ctx, *alert)
+		if err := stateError(err, "Error aggregating task failure"); err != nil {
+			return "", err
+		}
+		if aggregated {
+			return "aggregated into the service's failure window", nil
 		}
 	}
 
-	if isAlert {
-		// Send Slack
-		if err := sendSlackNotification(message); err != nil {
-			log.Printf("Error sending Slack: %v", err)
-		} else {
-			log.Println("Slack notification sent")
+	// Quiet hours hold back everything but critical alerts; the first alert
+	// after the window reports how many were dropped
+	if cfg.QuietHours.contains(time.Now()) {
+		if alert.Severity != SeverityCritical {
+			if err := stateError(noteQuietDrop(ctx), "Error counting alert dropped in quiet hours"); err != nil {
+				return "", err
+			}
+			return "quiet hours, only critical alerts are sent", nil
+		}
+	} else if cfg.QuietHours != nil {
+		dropped, err := takeQuietDrops(ctx)
+		if err := stateError(err, "Error reading alerts dropped in quiet hours"); err != nil {
+			return "", err
+		}
+		if dropped > 0 {
+			alert.setField("Dropped During Quiet Hours", strconv.FormatInt(dropped, 10))
 		}
+	}
 
-		// Send Email
-		if err := sendEmail(subject, message); err != nil {
-			log.Printf("Error sending Email: %v", err)
-		} else {
-			log.Println("Email notification sent")
+	escalated, failures, err := recordFailure(ctx, *alert)
+	if err := stateError(err, "Error counting failure for escalation"); err != nil {
+		return "", err
+	}
+	if escalated {
+		// The page is what the repeated failures were building up to, so
+		// cooldown doesn't hold it back
+		alert.Escalated = true
+		alert.setField("Escalated", fmt.Sprintf("%d failures, none more than %s apart", failures, cfg.EscalateWindow))
+		return "", nil
+	}
+
+	if bypassesDedup(*alert) {
+		return "", nil
+	}
+	allowed, suppressed, err := checkCooldown(ctx, cooldownKey(*alert))
+	if err := stateError(err, "Error checking cooldown"); err != nil {
+		return "", err
+	}
+	if !allowed {
+		return "cooldown active for service and severity", nil
+	}
+	if suppressed > 0 {
+		alert.setField("Suppressed During Cooldown", strconv.FormatInt(suppressed, 10))
+	}
+	return "", nil
+}
+
+// Reports whether alerts for serviceName pass the MONITORED_SERVICES allow
+// list. Tasks that don't belong to a service can't be listed there, so with
+// ALERT_ON_UNKNOWN_SERVICE (the default) they always pass; set it false to
+// let the allow list drop them too.
+func isMonitored(serviceName string) bool {
+	services, tagged := monitoredServices()
+	if len(services) == 0 && !tagged {
+		return true
+	}
+	if serviceName == unknownServiceName && cfg.AlertUnknownService {
+		return true
+	}
+	return contains(services, serviceName)
+}
+
+// Sends the alert to every enabled channel its severity routes to. Failures
+// are logged per channel so one broken channel doesn't stop the others.
+func notify(ctx context.Context, alert Alert) {
+	if severityRank(alert.Severity) < severityRank(cfg.MinSeverity) {
+		slog.Info("Alert below MIN_SEVERITY, not sent", "title", alert.Title, "severity", alert.Severity,
+			"min_severity", cfg.MinSeverity)
+		recordAudit(alert, nil, nil)
+		return
+	}
+	// Applied here rather than when the alert is built, so dedup keys keep
+	// using the built-in title
+	alert.IncidentID = incidentID(alert)
+	// Before the template, so reason patterns see the built-in title
+	alert = applyPlaybook(alert)
+	alert = applySubjectTemplate(alert)
+	alert.Account = accountLabel(ctx, alert)
+	var sent, failed []string
+	for _, ch := range orderedChannels() {
+		n, registered := notifiers[ch.name]
+		switch {
+		case ch.name == cfg.FallbackChannel:
+			slog.Info("Notification skipped, channel only used as fallback", "channel", ch.label)
+		case !ch.enabled:
+			slog.Info("Notification skipped, channel disabled", "channel", ch.label, "flag", ch.flag)
+		case !registered:
+			slog.Info("Notification skipped, channel not configured", "channel", ch.label)
+		case len(cfg.ProfileChannels) > 0 && !contains(cfg.ProfileChannels, ch.name):
+			slog.Info("Notification skipped, not a channel of the active profile", "channel", ch.label)
+		case !routesAlert(ch, alert):
+			slog.Info("Notification skipped, not routed for severity", "channel", ch.label, "severity", alert.Severity)
+		case len(sent) > 0 && cfg.StopOnFirstSuccess && !ch.paging:
+			// Pages are never skipped: an escalation must reach whoever is on call
+			slog.Info("Notification skipped, already delivered", "channel", ch.label)
+		case ctx.Err() != nil:
+			slog.Warn("Notification skipped, send budget exhausted", "channel", ch.label, "error", ctx.Err())
+		default:
+			a := alertForChannel(ch, alert)
+			if !bypassesDedup(a) && isRecentRepeat(ch.name, a) {
+				slog.Info("Notification skipped, identical message sent recently", "channel", ch.label)
+				continue
+			}
+			if err := sendCounted(ctx, ch, n, a); err != nil {
+				slog.Error("Error sending notification", "channel", ch.label, "error", err)
+				reportSendFailure(ctx, ch.label, a, err)
+				failed = append(failed, ch.label)
+			} else {
+				slog.Info("Notification sent", "channel", ch.label)
+				recordSentMessage(ch.name, a)
+				sent = append(sent, ch.label)
+			}
+		}
+	}
+	if len(failed) > 0 {
+		if label := sendFallback(ctx, alert, failed); label != "" {
+			sent = append(sent, label)
 		}
-	} else {
-		log.Println("Event processed, no alert conditions met.")
 	}
+	recordAudit(alert, sent, failed)
+}
 
-	return nil
+// Shapes an alert for one channel: masked ARNs for external services,
+// capped details unless the channel takes them all, and the banner
+func alertForChannel(ch channel, alert Alert) Alert {
+	if ch.external && cfg.MaskARNs {
+		alert = maskAlertARNs(alert)
+	}
+	if !ch.fullDetails {
+		alert = capDetails(alert, cfg.MaxContainerLines)
+	}
+	if ch.external || !cfg.BannerExternalOnly {
+		alert.Banner = cfg.Banner
+	}
+	return alert
 }
 
-func contains(slice []string, item string) bool {
-	for _, s := range slice {
-		if s == item {
-			return true
+// Sends the alert through FALLBACK_CHANNEL after other channels failed to
+// take it. The fallback ignores SEVERITY_ROUTES, since it only carries
+// alerts that were routed somewhere and didn't arrive, but a critical-only
+// channel still gets nothing below critical. Returns the fallback's label
+// when it took the alert.
+func sendFallback(ctx context.Context, alert Alert, failed []string) string {
+	if cfg.FallbackChannel == "" {
+		return ""
+	}
+	var ch channel
+	for _, c := range channels() {
+		if c.name == cfg.FallbackChannel {
+			ch = c
 		}
 	}
-	return false
+	n, registered := notifiers[ch.name]
+	switch {
+	case !ch.enabled:
+		slog.Warn("Fallback skipped, channel disabled", "channel", ch.label, "flag", ch.flag)
+		return ""
+	case !registered:
+		slog.Warn("Fallback skipped, channel not configured", "channel", ch.label)
+		return ""
+	case ch.criticalOnly && alert.Severity != SeverityCritical:
+		slog.Info("Fallback skipped, channel only takes critical alerts", "channel", ch.label, "severity", alert.Severity)
+		return ""
+	}
+
+	a := alertForChannel(ch, alert)
+	a.Fields = maps.Clone(a.Fields)
+	if a.Fields == nil {
+		a.Fields = map[string]string{}
+	}
+	a.Fields["Undelivered"] = strings.Join(failed, ", ")
+	if err := sendCounted(ctx, ch, n, a); err != nil {
+		slog.Error("Error sending fallback notification", "channel", ch.label, "failed", failed, "error", err)
+		reportSendFailure(ctx, ch.label, a, err)
+		return ""
+	}
+	slog.Info("Fallback notification sent", "channel", ch.label, "failed", failed)
+	recordSentMessage(ch.name, a)
+	return ch.label
 }
 
-func sendSlackNotification(text string) error {
-	if cfg.SlackWebhookURL == "" {
-		log.Println("Slack webhook URL not configured, skipping Slack notification")
-		return nil
+// Reads an env variable, falling back to def when unset or empty
+func envDefault(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
 	}
+	return def
+}
 
-	payload := map[string]string{"text": text}
-	payloadBytes, err := json.Marshal(payload)
+// Reads a boolean env variable, falling back to def when unset or invalid
+func envBool(key string, def bool) bool {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		slog.Warn("Invalid boolean, using default", "key", key, "value", v, "default", def)
+		return def
+	}
+	return b
+}
 
-	resp, err := http.Post(cfg.SlackWebhookURL, "application/json", bytes.NewBuffer(payloadBytes))
+// Reads an integer env variable, falling back to def when unset or invalid
+func envInt(key string, def int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
 	if err != nil {
-		return fmt.Errorf("failed to send Slack notification: %v", err)
+		slog.Warn("Invalid integer, using default", "key", key, "value", v, "default", def)
+		return def
 	}
-	defer resp.Body.Close()
+	return n
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("received non-200 response from Slack: %s", resp.Status)
+// Reads a float env variable, falling back to def when unset or invalid
+func envFloat(key string, def float64) float64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
 	}
-	if resp.StatusCode >= 400 {
-		return fmt.Errorf("Slack API error: %s", resp.Status)
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		slog.Warn("Invalid number, using default", "key", key, "value", v, "default", def)
+		return def
 	}
+	return f
+}
 
-	return nil
+// Reads a variable that says where alerts go (webhooks, recipients). With
+// ENVIRONMENT set, KEY_<ENVIRONMENT> wins over KEY, e.g. SLACK_WEBHOOK_URL_PROD
+// when ENVIRONMENT=prod, so one artifact can carry every stack's targets.
+func envTarget(key string) string {
+	if env := os.Getenv("ENVIRONMENT"); env != "" {
+		if v := os.Getenv(key + "_" + strings.ToUpper(env)); v != "" {
+			return v
+		}
+	}
+	return os.Getenv(key)
 }
 
-func sendEmail(subject, body string) error {
-	if cfg.SenderEmail == "" || cfg.RecipientEmail == "" {
-		log.Println("Sender or recipient email not configured, skipping email notification")
-		return nil
+// Reads a comma-separated env variable, trimming spaces and dropping empty entries
+func envList(key string) []string {
+	return splitList(os.Getenv(key))
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		// Trim spaces just in case
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
 	}
+	return list
+}
 
-	input := &ses.SendEmailInput{
-		Destination: &types.Destination{
-			ToAddresses: []string{cfg.RecipientEmail},
-		},
-		Message: &types.Message{
-			Body: &types.Body{
-				Text: &types.Content{
-					Data: aws.String(body),
-				},
-			},
-			Subject: &types.Content{
-				Data: aws.String(subject),
-			},
-		},
-		Source: aws.String(cfg.SenderEmail),
+// Like envList, but returns def when the variable is unset
+func envListDefault(key string, def []string) []string {
+	if _, ok := os.LookupEnv(key); !ok {
+		return def
 	}
+	return envList(key)
+}
 
-	_, err := sesClient.SendEmail(context.TODO(), input)
-	return err
+func contains(slice []string, item string) bool {
+	for _, s := range slice {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
+
+// Unmarshals an event detail into v. Events re-published through SNS or by
+// hand sometimes carry the detail as a JSON-encoded string rather than an
+// object, so a quoted string is unquoted first.
+func decodeDetail(raw json.RawMessage, v any) error {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) > 0 && raw[0] == '"' {
+		var inner string
+		if err := json.Unmarshal(raw, &inner); err != nil {
+			return err
+		}
+		raw = json.RawMessage(inner)
+	}
+	return json.Unmarshal(raw, v)
 }
 
 // Helper to extract "my-service" from "arn:aws:ecs:us-east-1:123:service/my-service"
@@ -257,15 +1152,45 @@ func getResourceName(arn string) string {
 	return arn
 }
 
+// Helper to extract the tag from an image reference, e.g. "abc123" from
+// "123.dkr.ecr.us-east-1.amazonaws.com/repo:abc123". Digest-pinned images
+// ("repo@sha256:...") return the digest when no tag is present.
+func imageTag(image string) string {
+	ref, digest, _ := strings.Cut(image, "@")
+	// Only look for ":" after the last "/" so registry ports aren't mistaken for tags
+	name := ref[strings.LastIndex(ref, "/")+1:]
+	if i := strings.LastIndex(name, ":"); i >= 0 && i < len(name)-1 {
+		return name[i+1:]
+	}
+	return digest
+}
+
+// Service name used for tasks whose group names no service
+const unknownServiceName = "Unknown (Task run manually?)"
+
 // Helper to extract service name from group "service:my-service"
 func getServiceNameFromGroup(group string) string {
 	parts := strings.Split(group, ":")
 	if len(parts) > 1 {
 		return parts[1]
 	}
-	return "Unknown (Task run manually?)"
+	return unknownServiceName
 }
 
 func main() {
-	lambda.Start(handleRequest)
+	if len(os.Args) > 1 && (os.Args[1] == "--version" || os.Args[1] == "version") {
+		fmt.Printf("%s (commit %s, built %s)\n", Version, Commit, BuildDate)
+		return
+	}
+
+	// Validate config locally before deploying: `./bootstrap check-config`
+	if len(os.Args) > 1 && os.Args[1] == "check-config" {
+		if !runConfigCheck(context.Background(), os.Stdout) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	slog.Info("Starting ECS alerter", "version", Version, "commit", Commit, "build_date", BuildDate)
+	lambda.Start(handleInvocation)
 }
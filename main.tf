@@ -39,7 +39,37 @@ resource "aws_iam_policy" "lambda_logging_ses" {
         Resource = "arn:aws:logs:*:*:*"
       },
       {
-        Action   = ["ses:SendEmail", "ses:SendRawEmail"]
+        Action   = ["ses:SendEmail", "ses:SendRawEmail", "ses:GetIdentityVerificationAttributes"]
+        Effect   = "Allow"
+        Resource = "*"
+      },
+      {
+        Action   = ["cloudwatch:GetMetricWidgetImage"]
+        Effect   = "Allow"
+        Resource = "*"
+      },
+      {
+        Action   = ["dynamodb:GetItem", "dynamodb:PutItem", "dynamodb:UpdateItem", "dynamodb:DeleteItem", "dynamodb:Scan"]
+        Effect   = "Allow"
+        Resource = aws_dynamodb_table.alerter_state.arn
+      },
+      {
+        Action   = ["sqs:SendMessage"]
+        Effect   = "Allow"
+        Resource = aws_sqs_queue.poison_events.arn
+      },
+      {
+        Action   = ["ecs:DescribeServices", "ecs:DescribeTaskDefinition"]
+        Effect   = "Allow"
+        Resource = "*"
+      },
+      {
+        Action   = ["tag:GetResources"]
+        Effect   = "Allow"
+        Resource = "*"
+      },
+      {
+        Action   = ["xray:PutTraceSegments", "xray:PutTelemetryRecords"]
         Effect   = "Allow"
         Resource = "*"
       }
@@ -52,6 +82,162 @@ resource "aws_iam_role_policy_attachment" "lambda_logs" {
   policy_arn = aws_iam_policy.lambda_logging_ses.arn
 }
 
+# Publishing to the AWS Chatbot topic is only granted when one is configured
+resource "aws_iam_role_policy" "chatbot_publish" {
+  count = var.chatbot_sns_topic_arn == "" ? 0 : 1
+  name  = "ecs_alerter_chatbot_publish"
+  role  = aws_iam_role.lambda_exec_role.id
+
+  policy = jsonencode({
+    Version = "2012-10-17"
+    Statement = [
+      {
+        Action   = ["sns:Publish", "sns:GetTopicAttributes"]
+        Effect   = "Allow"
+        Resource = var.chatbot_sns_topic_arn
+      }
+    ]
+  })
+}
+
+# Writing the audit log is only granted when a bucket is named, and only
+# under its alerts/ prefix
+resource "aws_iam_role_policy" "audit_log_s3" {
+  count = var.audit_log_bucket == "" ? 0 : 1
+  name  = "ecs_alerter_audit_log_s3"
+  role  = aws_iam_role.lambda_exec_role.id
+
+  policy = jsonencode({
+    Version = "2012-10-17"
+    Statement = [
+      {
+        Action   = ["s3:PutObject"]
+        Effect   = "Allow"
+        Resource = "arn:aws:s3:::${var.audit_log_bucket}/alerts/*"
+      }
+    ]
+  })
+}
+
+data "aws_caller_identity" "current" {}
+
+# Reading the Slack webhook from SSM is only granted when a parameter is named.
+# SecureStrings under a customer managed KMS key also need kms:Decrypt on it.
+resource "aws_iam_role_policy" "slack_webhook_ssm" {
+  count = var.slack_webhook_ssm_param == "" ? 0 : 1
+  name  = "ecs_alerter_slack_webhook_ssm"
+  role  = aws_iam_role.lambda_exec_role.id
+
+  policy = jsonencode({
+    Version = "2012-10-17"
+    Statement = [
+      {
+        Action   = ["ssm:GetParameter"]
+        Effect   = "Allow"
+        Resource = "arn:aws:ssm:${var.aws_region}:${data.aws_caller_identity.current.account_id}:parameter/${trimprefix(var.slack_webhook_ssm_param, "/")}"
+      }
+    ]
+  })
+}
+
+# ListAccountAliases has no resource-level permissions
+resource "aws_iam_role_policy" "account_alias" {
+  count = var.account_alias_lookup && var.account_name == "" ? 1 : 0
+  name  = "ecs_alerter_account_alias"
+  role  = aws_iam_role.lambda_exec_role.id
+
+  policy = jsonencode({
+    Version = "2012-10-17"
+    Statement = [
+      {
+        Action   = ["iam:ListAccountAliases"]
+        Effect   = "Allow"
+        Resource = "*"
+      }
+    ]
+  })
+}
+
+# SMS goes straight to phone numbers, which have no ARN to scope the grant to
+resource "aws_iam_role_policy" "sms_publish" {
+  count = length(var.sms_numbers) == 0 ? 0 : 1
+  name  = "ecs_alerter_sms_publish"
+  role  = aws_iam_role.lambda_exec_role.id
+
+  policy = jsonencode({
+    Version = "2012-10-17"
+    Statement = [
+      {
+        Action   = ["sns:Publish"]
+        Effect   = "Allow"
+        Resource = "*"
+      }
+    ]
+  })
+}
+
+# Reading from the event queue is only granted when one is named
+resource "aws_iam_role_policy" "event_queue_receive" {
+  count = var.event_queue_arn == "" ? 0 : 1
+  name  = "ecs_alerter_event_queue_receive"
+  role  = aws_iam_role.lambda_exec_role.id
+
+  policy = jsonencode({
+    Version = "2012-10-17"
+    Statement = [
+      {
+        Action   = ["sqs:ReceiveMessage", "sqs:DeleteMessage", "sqs:GetQueueAttributes"]
+        Effect   = "Allow"
+        Resource = var.event_queue_arn
+      }
+    ]
+  })
+}
+
+# --- State Table (deployment tracking) ---
+resource "aws_dynamodb_table" "alerter_state" {
+  name         = "ecs-alerter-state"
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "pk"
+
+  attribute {
+    name = "pk"
+    type = "S"
+  }
+
+  ttl {
+    attribute_name = "expiresAt"
+    enabled        = true
+  }
+}
+
+# --- Poison Event Queue (events the alerter can't parse) ---
+resource "aws_sqs_queue" "poison_events" {
+  name                      = "ecs-alerter-poison-events"
+  message_retention_seconds = 1209600
+}
+
+# Events queued by EventBridge rules that target SQS instead of the Lambda.
+# The handler reports the messages that failed, so SQS only delivers those
+# again rather than the whole batch.
+resource "aws_lambda_event_source_mapping" "event_queue" {
+  count                   = var.event_queue_arn == "" ? 0 : 1
+  event_source_arn        = var.event_queue_arn
+  function_name           = aws_lambda_function.ecs_alerter.arn
+  batch_size              = 10
+  function_response_types = ["ReportBatchItemFailures"]
+}
+
+# Per-environment targets, e.g. { prod = { SLACK_WEBHOOK_URL = "..." } },
+# become SLACK_WEBHOOK_URL_PROD and are picked when ENVIRONMENT matches
+locals {
+  environment_target_variables = merge([
+    for env, vars in var.environment_targets : {
+      for key, value in vars : "${key}_${upper(env)}" => value
+    }
+  ]...)
+}
+
 # --- Lambda Function ---
 resource "aws_lambda_function" "ecs_alerter" {
   filename         = "lambda_function_payload.zip"
@@ -62,21 +248,123 @@ resource "aws_lambda_function" "ecs_alerter" {
   runtime          = "provided.al2023"
   timeout          = 10
 
+  tracing_config {
+    mode = var.xray_enabled ? "Active" : "PassThrough"
+  }
+
   # Here we inject the variables into the Lambda Environment
   environment {
-    variables = {
-      SLACK_WEBHOOK_URL = var.slack_webhook_url
-      SENDER_EMAIL      = var.sender_email
-      RECIPIENT_EMAIL   = var.recipient_email
-      AWS_REGION        = var.aws_region
-      MONITORED_SERVICES = join(",", var.monitored_services)
-    }
+    variables = merge({
+      SLACK_WEBHOOK_URL          = var.slack_webhook_url
+      SLACK_WEBHOOK_SSM_PARAM    = var.slack_webhook_ssm_param
+      SLACK_BOT_TOKEN            = var.slack_bot_token
+      SLACK_CHANNEL_ID           = var.slack_channel_id
+      SLACK_SNIPPET_THRESHOLD    = tostring(var.slack_snippet_threshold)
+      SLACK_DISABLE_UNFURL       = tostring(var.slack_disable_unfurl)
+      SLACK_THREAD_BY_DEPLOYMENT = tostring(var.slack_thread_by_deployment)
+      SECURITY_SLACK_WEBHOOK_URL = var.security_slack_webhook_url
+      SECURITY_HUB_SEVERITIES    = join(",", var.security_hub_severities)
+      MATTERMOST_WEBHOOK_URL     = var.mattermost_webhook_url
+      MATTERMOST_CHANNEL         = var.mattermost_channel
+      GOOGLE_CHAT_WEBHOOK_URL    = var.google_chat_webhook_url
+      JSON_WEBHOOK_URL           = var.json_webhook_url
+      WEBHOOK_SIGNING_SECRET     = var.webhook_signing_secret
+      CHATBOT_SNS_TOPIC_ARN      = var.chatbot_sns_topic_arn
+      PAGERDUTY_ROUTING_KEY      = var.pagerduty_routing_key
+      PAGERDUTY_AUTO_RESOLVE     = tostring(var.pagerduty_auto_resolve)
+      SMS_NUMBERS                = join(",", var.sms_numbers)
+      SENDER_EMAIL               = var.sender_email
+      SES_IDENTITY_CHECK         = tostring(var.ses_identity_check)
+      SENDER_EMAIL_FALLBACKS     = join(",", var.sender_email_fallbacks)
+      SES_REGIONS                = join(",", var.ses_regions)
+      SENDER_NAME                = var.sender_name
+      RECIPIENT_EMAIL            = var.recipient_email
+      RECIPIENT_EMAIL_CRITICAL   = join(",", var.recipient_email_critical)
+      RECIPIENT_EMAIL_WARNING    = join(",", var.recipient_email_warning)
+      RECIPIENT_EMAIL_INFO       = join(",", var.recipient_email_info)
+      ENVIRONMENT                = var.environment
+      NOTIFICATION_BANNER        = var.notification_banner
+      BANNER_EXTERNAL_ONLY       = tostring(var.banner_external_only)
+      ACCOUNT_NAME               = var.account_name
+      ACCOUNT_ALIAS_LOOKUP       = tostring(var.account_alias_lookup)
+      AWS_REGION                 = var.aws_region
+      TIMEZONE                   = var.timezone
+      LOCALE                     = var.locale
+      QUIET_HOURS                = var.quiet_hours
+      MONITORED_SERVICES         = join(",", var.monitored_services)
+      MONITORED_SERVICES_TAG     = var.monitored_services_tag
+      MONITORED_TAG_TTL_SECONDS  = tostring(var.monitored_tag_ttl_seconds)
+      ALERT_ON_UNKNOWN_SERVICE   = tostring(var.alert_on_unknown_service)
+      STATE_TABLE_NAME           = aws_dynamodb_table.alerter_state.name
+      DEPLOY_TIMEOUT_MINUTES     = tostring(var.deploy_timeout_minutes)
+      PENDING_TIMEOUT_SECONDS    = tostring(var.pending_timeout_seconds)
+      TASK_CORRELATION_SECONDS   = tostring(var.task_correlation_seconds)
+      SUPPRESS_DURING_DEPLOY     = tostring(var.suppress_during_deploy)
+      DEPLOY_GRACE_SECONDS       = tostring(var.deploy_grace_seconds)
+      ALERT_FIRST_FAILURE_ONLY   = tostring(var.alert_first_failure_only)
+      ALERT_ON_DEPLOY_SUCCESS    = tostring(var.alert_on_deploy_success)
+      COOLDOWN_SECONDS           = tostring(var.cooldown_seconds)
+      ESCALATE_AFTER             = tostring(var.escalate_after)
+      ESCALATE_WINDOW_SECONDS    = tostring(var.escalate_window_seconds)
+      RESTART_ALERT_THRESHOLD    = tostring(var.restart_alert_threshold)
+      RESTART_WINDOW_SECONDS     = tostring(var.restart_window_seconds)
+      DEPLOYMENT_DEDUP_SECONDS   = tostring(var.deployment_dedup_seconds)
+      DEDUP_KEY_TEMPLATE         = var.dedup_key_template
+      DEDUP_BYPASS_CRITICAL      = tostring(var.dedup_bypass_critical)
+      SUBJECT_TEMPLATE           = length(var.subject_templates) == 0 ? "" : jsonencode(var.subject_templates)
+      PLAYBOOKS                  = length(var.playbooks) == 0 ? "" : jsonencode(var.playbooks)
+      AGGREGATE_WINDOW_SECONDS   = tostring(var.aggregate_window_seconds)
+      EMAIL_DIGEST               = tostring(var.email_digest)
+      EMAIL_DIGEST_SECONDS       = tostring(var.email_digest_seconds)
+      SCALING_REASON_PATTERNS    = join(",", var.scaling_reason_patterns)
+      PLACEMENT_FAILURE_PATTERNS = join(",", var.placement_failure_patterns)
+      ALERT_ON_CAPACITY_FAILURES = tostring(var.alert_on_capacity_failures)
+      ALERT_ON_UNHEALTHY_TASKS   = tostring(var.alert_on_unhealthy_tasks)
+      ENRICH_FROM_API            = tostring(var.enrich_from_api)
+      FORCE_ALERT_REASONS        = join(",", var.force_alert_reasons)
+      MIN_ALERT_EXIT_CODE        = tostring(var.min_alert_exit_code)
+      MAX_CONTAINER_LINES        = tostring(var.max_container_lines)
+      RDS_ALERT_CATEGORIES       = join(",", var.rds_alert_categories)
+      LOG_ALERT_PATTERN          = var.log_alert_pattern
+      IGNORED_CONTAINERS         = join(",", var.ignored_containers)
+      SEVERITY_ROUTES            = join(";", [for sev, chans in var.severity_routes : "${sev}=${join(",", chans)}"])
+      SEVERITY_ICONS             = join(";", [for sev, icon in var.severity_icons : "${sev}=${icon}"])
+      MIN_SEVERITY               = var.min_severity
+      CHANNEL_ORDER              = join(",", var.channel_order)
+      FALLBACK_CHANNEL           = var.fallback_channel
+      PROFILES                   = length(var.profiles) == 0 ? "" : jsonencode(var.profiles)
+      ACTIVE_PROFILE             = var.active_profile
+      STOP_ON_FIRST_SUCCESS      = tostring(var.stop_on_first_success)
+      STRICT_MODE                = tostring(var.strict_mode)
+      INFO_SAMPLE_RATE           = tostring(var.info_sample_rate)
+      WEBHOOK_MAX_RETRIES        = tostring(var.webhook_max_retries)
+      WEBHOOK_RETRY_STATUSES     = join(",", var.webhook_retry_statuses)
+      SEND_BUDGET_SECONDS        = tostring(var.send_budget_seconds)
+      REPEAT_WINDOW_SECONDS      = tostring(var.repeat_window_seconds)
+      SENTRY_DSN                 = var.sentry_dsn
+      HEARTBEAT_URL              = var.heartbeat_url
+      MASK_ARNS                  = tostring(var.mask_arns)
+      AUDIT_LOG_BUCKET           = var.audit_log_bucket
+      DLQ_SQS_URL                = aws_sqs_queue.poison_events.url
+      ATTACH_METRIC_GRAPH        = tostring(var.attach_metric_graph)
+      SLACK_ENABLED              = tostring(var.slack_enabled)
+      SECURITY_SLACK_ENABLED     = tostring(var.security_slack_enabled)
+      MATTERMOST_ENABLED         = tostring(var.mattermost_enabled)
+      GOOGLE_CHAT_ENABLED        = tostring(var.google_chat_enabled)
+      JSON_WEBHOOK_ENABLED       = tostring(var.json_webhook_enabled)
+      CHATBOT_ENABLED            = tostring(var.chatbot_enabled)
+      PAGERDUTY_ENABLED          = tostring(var.pagerduty_enabled)
+      SMS_ENABLED                = tostring(var.sms_enabled)
+      EMAIL_ENABLED              = tostring(var.email_enabled)
+      XRAY_ENABLED               = tostring(var.xray_enabled)
+    }, local.environment_target_variables)
   }
 }
 
 # --- EventBridge Rules (Alert Logic) ---
 
 # Rule 1: Deployment Failures (Rollbacks)
+# IN_PROGRESS/COMPLETED are forwarded too so stuck deployments can be tracked
 resource "aws_cloudwatch_event_rule" "ecs_deployment_failure" {
   name        = "ecs-deployment-failure-rule"
   description = "Capture ECS Service Deployment Failures"
@@ -85,7 +373,7 @@ resource "aws_cloudwatch_event_rule" "ecs_deployment_failure" {
     source      = ["aws.ecs"]
     detail-type = ["ECS Deployment State Change"]
     detail = {
-      eventName = ["SERVICE_DEPLOYMENT_FAILED"]
+      eventName = ["SERVICE_DEPLOYMENT_FAILED", "SERVICE_DEPLOYMENT_IN_PROGRESS", "SERVICE_DEPLOYMENT_COMPLETED"]
     }
   })
 }
@@ -96,7 +384,34 @@ resource "aws_cloudwatch_event_target" "target_deployment_failure" {
   arn       = aws_lambda_function.ecs_alerter.arn
 }
 
-# Rule 2: Task Failures (Crashes)
+# Rule 1a: Services reaching steady state, so PagerDuty incidents can be resolved
+# and restart counts reset
+resource "aws_cloudwatch_event_rule" "ecs_service_steady_state" {
+  count       = var.pagerduty_auto_resolve || var.restart_alert_threshold > 0 ? 1 : 0
+  name        = "ecs-service-steady-state-rule"
+  description = "Capture ECS services reaching steady state to resolve PagerDuty incidents and reset restart counts"
+
+  event_pattern = jsonencode({
+    source      = ["aws.ecs"]
+    detail-type = ["ECS Service Action"]
+    detail = {
+      eventName = ["SERVICE_STEADY_STATE"]
+    }
+  })
+}
+
+resource "aws_cloudwatch_event_target" "target_service_steady_state" {
+  count     = var.pagerduty_auto_resolve || var.restart_alert_threshold > 0 ? 1 : 0
+  rule      = aws_cloudwatch_event_rule.ecs_service_steady_state[0].name
+  target_id = "SendToLambda"
+  arn       = aws_lambda_function.ecs_alerter.arn
+}
+
+# Rule 2: Task Failures (Crashes). Every stopped task is forwarded: EventBridge
+# only matches whole strings, so filtering on stoppedReason here would keep
+# reasons such as "Task failed ELB health checks in (target-group ...)" or a
+# placement failure from FORCE_ALERT_REASONS and the Lambda's own filters,
+# which already skip routine scale-in.
 resource "aws_cloudwatch_event_rule" "ecs_task_failure" {
   name        = "ecs-task-failure-rule"
   description = "Capture ECS Task Stops/Crashes"
@@ -106,10 +421,6 @@ resource "aws_cloudwatch_event_rule" "ecs_task_failure" {
     detail-type = ["ECS Task State Change"]
     detail = {
       lastStatus = ["STOPPED"]
-      stoppedReason = [
-        "Essential container in task exited",
-        "Task failed to start"
-      ]
     }
   })
 }
@@ -120,6 +431,167 @@ resource "aws_cloudwatch_event_target" "target_task_failure" {
   arn       = aws_lambda_function.ecs_alerter.arn
 }
 
+# Rule 2b: Task lifecycle before STOPPED, in one rule so each event reaches
+# the Lambda once. The pending sweep needs every status from PROVISIONING on,
+# since any status after PENDING clears the task again; ALERT_ON_UNHEALTHY_TASKS
+# only needs RUNNING, to alert on a failing health check and clear it.
+locals {
+  pre_stopped_task_statuses = distinct(concat(
+    var.pending_timeout_seconds > 0 ? ["PROVISIONING", "PENDING", "ACTIVATING", "RUNNING", "DEPROVISIONING"] : [],
+    var.alert_on_unhealthy_tasks ? ["RUNNING"] : [],
+  ))
+}
+
+resource "aws_cloudwatch_event_rule" "ecs_task_lifecycle" {
+  count       = length(local.pre_stopped_task_statuses) > 0 ? 1 : 0
+  name        = "ecs-task-lifecycle-rule"
+  description = "Track ECS tasks starting and running"
+
+  event_pattern = jsonencode({
+    source      = ["aws.ecs"]
+    detail-type = ["ECS Task State Change"]
+    detail = {
+      lastStatus = local.pre_stopped_task_statuses
+    }
+  })
+}
+
+resource "aws_cloudwatch_event_target" "target_task_lifecycle" {
+  count     = length(local.pre_stopped_task_statuses) > 0 ? 1 : 0
+  rule      = aws_cloudwatch_event_rule.ecs_task_lifecycle[0].name
+  target_id = "SendToLambda"
+  arn       = aws_lambda_function.ecs_alerter.arn
+}
+
+# Rule 2c: Tasks a service couldn't place, alerted when capacity was the cause
+resource "aws_cloudwatch_event_rule" "ecs_task_placement_failure" {
+  count       = var.alert_on_capacity_failures ? 1 : 0
+  name        = "ecs-task-placement-failure-rule"
+  description = "Capture ECS services that could not place tasks"
+
+  event_pattern = jsonencode({
+    source      = ["aws.ecs"]
+    detail-type = ["ECS Service Action"]
+    detail = {
+      eventName = ["SERVICE_TASK_PLACEMENT_FAILURE"]
+    }
+  })
+}
+
+resource "aws_cloudwatch_event_target" "target_task_placement_failure" {
+  count     = var.alert_on_capacity_failures ? 1 : 0
+  rule      = aws_cloudwatch_event_rule.ecs_task_placement_failure[0].name
+  target_id = "SendToLambda"
+  arn       = aws_lambda_function.ecs_alerter.arn
+}
+
+# Rule 3: RDS instance events (failover, failure, low storage, ...)
+resource "aws_cloudwatch_event_rule" "rds_instance_event" {
+  name        = "rds-instance-event-rule"
+  description = "Capture RDS DB instance events"
+
+  event_pattern = jsonencode({
+    source      = ["aws.rds"]
+    detail-type = ["RDS DB Instance Event"]
+    detail = {
+      EventCategories = var.rds_alert_categories
+    }
+  })
+}
+
+resource "aws_cloudwatch_event_target" "target_rds_instance_event" {
+  rule      = aws_cloudwatch_event_rule.rds_instance_event.name
+  target_id = "SendToLambda"
+  arn       = aws_lambda_function.ecs_alerter.arn
+}
+
+# Rule 3a: ACM certificates nearing expiry, sent daily from 45 days out
+resource "aws_cloudwatch_event_rule" "acm_certificate_expiry" {
+  name        = "acm-certificate-expiry-rule"
+  description = "Capture ACM certificates approaching expiration"
+
+  event_pattern = jsonencode({
+    source      = ["aws.acm"]
+    detail-type = ["ACM Certificate Approaching Expiration"]
+  })
+}
+
+resource "aws_cloudwatch_event_target" "target_acm_certificate_expiry" {
+  rule      = aws_cloudwatch_event_rule.acm_certificate_expiry.name
+  target_id = "SendToLambda"
+  arn       = aws_lambda_function.ecs_alerter.arn
+}
+
+# Rule 3b: GuardDuty findings, for the security Slack channel
+resource "aws_cloudwatch_event_rule" "guardduty_finding" {
+  count       = var.security_slack_webhook_url == "" ? 0 : 1
+  name        = "guardduty-finding-rule"
+  description = "Capture GuardDuty findings"
+
+  event_pattern = jsonencode({
+    source      = ["aws.guardduty"]
+    detail-type = ["GuardDuty Finding"]
+  })
+}
+
+resource "aws_cloudwatch_event_target" "target_guardduty_finding" {
+  count     = var.security_slack_webhook_url == "" ? 0 : 1
+  rule      = aws_cloudwatch_event_rule.guardduty_finding[0].name
+  target_id = "SendToLambda"
+  arn       = aws_lambda_function.ecs_alerter.arn
+}
+
+# Rule 3c: application logs, via a subscription filter per log group
+resource "aws_cloudwatch_log_subscription_filter" "log_alert" {
+  for_each        = toset(var.log_alert_log_groups)
+  name            = "ecs-alerter-log-alert"
+  log_group_name  = each.value
+  filter_pattern  = var.log_alert_filter_pattern
+  destination_arn = aws_lambda_function.ecs_alerter.arn
+  depends_on      = [aws_lambda_permission.allow_logs]
+}
+
+# Rule 3d: Security Hub findings at the alerting severities, for the
+# security Slack channel
+resource "aws_cloudwatch_event_rule" "security_hub_findings" {
+  count       = var.security_slack_webhook_url == "" ? 0 : 1
+  name        = "security-hub-findings-rule"
+  description = "Capture imported Security Hub findings"
+
+  event_pattern = jsonencode({
+    source      = ["aws.securityhub"]
+    detail-type = ["Security Hub Findings - Imported"]
+    detail = {
+      findings = {
+        Severity = {
+          Label = var.security_hub_severities
+        }
+      }
+    }
+  })
+}
+
+resource "aws_cloudwatch_event_target" "target_security_hub_findings" {
+  count     = var.security_slack_webhook_url == "" ? 0 : 1
+  rule      = aws_cloudwatch_event_rule.security_hub_findings[0].name
+  target_id = "SendToLambda"
+  arn       = aws_lambda_function.ecs_alerter.arn
+}
+
+# Rule 4: Scheduled sweep (stuck deployments and tasks, aggregated task failures, email digests,
+# SES identity verification)
+resource "aws_cloudwatch_event_rule" "stuck_deployment_sweep" {
+  name                = "ecs-stuck-deployment-sweep"
+  description         = "Periodically check for stuck ECS deployments and tasks and flush aggregated task failures and email digests"
+  schedule_expression = "rate(5 minutes)"
+}
+
+resource "aws_cloudwatch_event_target" "target_stuck_deployment_sweep" {
+  rule      = aws_cloudwatch_event_rule.stuck_deployment_sweep.name
+  target_id = "SendToLambda"
+  arn       = aws_lambda_function.ecs_alerter.arn
+}
+
 # --- Permissions ---
 resource "aws_lambda_permission" "allow_cloudwatch_deployment" {
   statement_id  = "AllowExecutionFromCloudWatchDeployment"
@@ -129,10 +601,88 @@ resource "aws_lambda_permission" "allow_cloudwatch_deployment" {
   source_arn    = aws_cloudwatch_event_rule.ecs_deployment_failure.arn
 }
 
+resource "aws_lambda_permission" "allow_cloudwatch_steady_state" {
+  count         = var.pagerduty_auto_resolve || var.restart_alert_threshold > 0 ? 1 : 0
+  statement_id  = "AllowExecutionFromCloudWatchSteadyState"
+  action        = "lambda:InvokeFunction"
+  function_name = aws_lambda_function.ecs_alerter.function_name
+  principal     = "events.amazonaws.com"
+  source_arn    = aws_cloudwatch_event_rule.ecs_service_steady_state[0].arn
+}
+
 resource "aws_lambda_permission" "allow_cloudwatch_task" {
   statement_id  = "AllowExecutionFromCloudWatchTask"
   action        = "lambda:InvokeFunction"
   function_name = aws_lambda_function.ecs_alerter.function_name
   principal     = "events.amazonaws.com"
   source_arn    = aws_cloudwatch_event_rule.ecs_task_failure.arn
-}
\ No newline at end of file
+}
+
+resource "aws_lambda_permission" "allow_cloudwatch_task_lifecycle" {
+  count         = length(local.pre_stopped_task_statuses) > 0 ? 1 : 0
+  statement_id  = "AllowExecutionFromCloudWatchTaskLifecycle"
+  action        = "lambda:InvokeFunction"
+  function_name = aws_lambda_function.ecs_alerter.function_name
+  principal     = "events.amazonaws.com"
+  source_arn    = aws_cloudwatch_event_rule.ecs_task_lifecycle[0].arn
+}
+
+resource "aws_lambda_permission" "allow_cloudwatch_task_placement" {
+  count         = var.alert_on_capacity_failures ? 1 : 0
+  statement_id  = "AllowExecutionFromCloudWatchTaskPlacement"
+  action        = "lambda:InvokeFunction"
+  function_name = aws_lambda_function.ecs_alerter.function_name
+  principal     = "events.amazonaws.com"
+  source_arn    = aws_cloudwatch_event_rule.ecs_task_placement_failure[0].arn
+}
+
+resource "aws_lambda_permission" "allow_cloudwatch_sweep" {
+  statement_id  = "AllowExecutionFromCloudWatchSweep"
+  action        = "lambda:InvokeFunction"
+  function_name = aws_lambda_function.ecs_alerter.function_name
+  principal     = "events.amazonaws.com"
+  source_arn    = aws_cloudwatch_event_rule.stuck_deployment_sweep.arn
+}
+
+resource "aws_lambda_permission" "allow_cloudwatch_guardduty" {
+  count         = var.security_slack_webhook_url == "" ? 0 : 1
+  statement_id  = "AllowExecutionFromCloudWatchGuardDuty"
+  action        = "lambda:InvokeFunction"
+  function_name = aws_lambda_function.ecs_alerter.function_name
+  principal     = "events.amazonaws.com"
+  source_arn    = aws_cloudwatch_event_rule.guardduty_finding[0].arn
+}
+
+resource "aws_lambda_permission" "allow_cloudwatch_security_hub" {
+  count         = var.security_slack_webhook_url == "" ? 0 : 1
+  statement_id  = "AllowExecutionFromCloudWatchSecurityHub"
+  action        = "lambda:InvokeFunction"
+  function_name = aws_lambda_function.ecs_alerter.function_name
+  principal     = "events.amazonaws.com"
+  source_arn    = aws_cloudwatch_event_rule.security_hub_findings[0].arn
+}
+
+resource "aws_lambda_permission" "allow_cloudwatch_rds" {
+  statement_id  = "AllowExecutionFromCloudWatchRDS"
+  action        = "lambda:InvokeFunction"
+  function_name = aws_lambda_function.ecs_alerter.function_name
+  principal     = "events.amazonaws.com"
+  source_arn    = aws_cloudwatch_event_rule.rds_instance_event.arn
+}
+
+resource "aws_lambda_permission" "allow_cloudwatch_acm" {
+  statement_id  = "AllowExecutionFromCloudWatchACM"
+  action        = "lambda:InvokeFunction"
+  function_name = aws_lambda_function.ecs_alerter.function_name
+  principal     = "events.amazonaws.com"
+  source_arn    = aws_cloudwatch_event_rule.acm_certificate_expiry.arn
+}
+
+resource "aws_lambda_permission" "allow_logs" {
+  for_each      = toset(var.log_alert_log_groups)
+  statement_id  = "AllowExecutionFromLogs-${md5(each.value)}"
+  action        = "lambda:InvokeFunction"
+  function_name = aws_lambda_function.ecs_alerter.function_name
+  principal     = "logs.amazonaws.com"
+  source_arn    = "arn:aws:logs:${var.aws_region}:${data.aws_caller_identity.current.account_id}:log-group:${each.value}:*"
+}
@@ -0,0 +1,565 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestShouldAlert(t *testing.T) {
+	app := func(code int) ContainerInfo {
+		return ContainerInfo{Name: "app", Image: "repo/api:3f2c1ab", ExitCode: code, Reason: "Essential container exited"}
+	}
+	running := stoppedTask("", ContainerInfo{Name: "app", HealthStatus: "HEALTHY"})
+	running.LastStatus = "RUNNING"
+	unhealthy := stoppedTask("", ContainerInfo{Name: "app", HealthStatus: "UNHEALTHY"})
+	unhealthy.LastStatus = "RUNNING"
+	pending := stoppedTask("")
+	pending.LastStatus = "PENDING"
+	runTask := stoppedTask("", app(1))
+	runTask.Group = "adhoc"
+	otherCluster := stoppedTask("", app(1))
+	otherCluster.ClusterArn = "arn:aws:ecs:us-east-1:123456789012:cluster/staging"
+
+	tests := []struct {
+		name   string
+		edit   func(c *Config)
+		tagged []string // a cached MONITORED_SERVICES_TAG result; nil for none
+		event  eventFixture
+
+		wantOK       bool
+		wantErr      bool
+		wantSkip     string // substring of the skip reason
+		wantSeverity Severity
+		wantTitle    string // substring of the title
+	}{
+		{
+			name:   "stopped task with failed container",
+			event:  taskFixture(stoppedTask("Essential container in task exited", app(1))),
+			wantOK: true, wantSeverity: SeverityCritical, wantTitle: "ECS Task Failure: api",
+		},
+		{
+			name:     "stopped task that exited cleanly",
+			event:    taskFixture(stoppedTask("", app(0))),
+			wantSkip: "no alert conditions met",
+		},
+		{
+			name:     "exit code below MIN_ALERT_EXIT_CODE",
+			edit:     func(c *Config) { c.MinAlertExitCode = 2 },
+			event:    taskFixture(stoppedTask("", app(1))),
+			wantSkip: "no alert conditions met",
+		},
+		{
+			name:   "exit code at MIN_ALERT_EXIT_CODE",
+			edit:   func(c *Config) { c.MinAlertExitCode = 2 },
+			event:  taskFixture(stoppedTask("", app(2))),
+			wantOK: true, wantSeverity: SeverityCritical,
+		},
+		{
+			name:   "negative exit code always alerts",
+			edit:   func(c *Config) { c.MinAlertExitCode = 2 },
+			event:  taskFixture(stoppedTask("", app(-1))),
+			wantOK: true, wantSeverity: SeverityCritical,
+		},
+		{
+			name:   "task that failed to start",
+			event:  taskFixture(stoppedTask("CannotPullContainerError: image not found")),
+			wantOK: true, wantSeverity: SeverityCritical,
+		},
+		{
+			name:     "task stopped by scale-in",
+			event:    taskFixture(stoppedTask("Scaling activity initiated by (deployment ecs-svc/4271158118824739872)")),
+			wantSkip: "no alert conditions met",
+		},
+		{
+			name:     "pending task",
+			event:    taskFixture(pending),
+			wantSkip: "no alert conditions met",
+		},
+		{
+			name:     "running task with healthy containers",
+			edit:     func(c *Config) { c.UnhealthyAlerts = true },
+			event:    taskFixture(running),
+			wantSkip: "no alert conditions met",
+		},
+		{
+			name:   "running task failing its health check",
+			edit:   func(c *Config) { c.UnhealthyAlerts = true },
+			event:  taskFixture(unhealthy),
+			wantOK: true, wantSeverity: SeverityWarning, wantTitle: "ECS Task Unhealthy",
+		},
+		{
+			name:     "running task failing its health check, unhealthy alerts off",
+			edit:     func(c *Config) { c.UnhealthyAlerts = false },
+			event:    taskFixture(unhealthy),
+			wantSkip: "no alert conditions met",
+		},
+		{
+			name:     "service not in MONITORED_SERVICES",
+			edit:     func(c *Config) { c.MonitoredServices = []string{"web"} },
+			event:    taskFixture(stoppedTask("", app(1))),
+			wantSkip: "service 'api' not in allowed list",
+		},
+		{
+			name:   "service in MONITORED_SERVICES",
+			edit:   func(c *Config) { c.MonitoredServices = []string{"web", "api"} },
+			event:  taskFixture(stoppedTask("", app(1))),
+			wantOK: true, wantSeverity: SeverityCritical,
+		},
+		{
+			// The allow-list names services only, whichever cluster runs them
+			name:   "monitored service in another cluster",
+			edit:   func(c *Config) { c.MonitoredServices = []string{"api"} },
+			event:  taskFixture(otherCluster),
+			wantOK: true, wantSeverity: SeverityCritical,
+		},
+		{
+			name: "task without a service, ALERT_ON_UNKNOWN_SERVICE on",
+			edit: func(c *Config) {
+				c.MonitoredServices = []string{"api"}
+				c.AlertUnknownService = true
+			},
+			event:  taskFixture(runTask),
+			wantOK: true, wantSeverity: SeverityCritical,
+		},
+		{
+			name: "task without a service, ALERT_ON_UNKNOWN_SERVICE off",
+			edit: func(c *Config) {
+				c.MonitoredServices = []string{"api"}
+				c.AlertUnknownService = false
+			},
+			event:    taskFixture(runTask),
+			wantSkip: "not in allowed list",
+		},
+		{
+			name: "tagged services replace MONITORED_SERVICES",
+			edit: func(c *Config) {
+				c.MonitoredTagKey = "alerting"
+				c.MonitoredServices = []string{"api"}
+			},
+			tagged:   []string{"web"},
+			event:    taskFixture(stoppedTask("", app(1))),
+			wantSkip: "service 'api' not in allowed list",
+		},
+		{
+			name:     "no service tagged",
+			edit:     func(c *Config) { c.MonitoredTagKey = "alerting" },
+			tagged:   []string{},
+			event:    taskFixture(stoppedTask("", app(1))),
+			wantSkip: "service 'api' not in allowed list",
+		},
+		{
+			name:   "tagged service",
+			edit:   func(c *Config) { c.MonitoredTagKey = "alerting" },
+			tagged: []string{"api"},
+			event:  taskFixture(stoppedTask("", app(1))),
+			wantOK: true, wantSeverity: SeverityCritical,
+		},
+		{
+			name: "tag query not run yet falls back to MONITORED_SERVICES",
+			edit: func(c *Config) {
+				c.MonitoredTagKey = "alerting"
+				c.MonitoredServices = []string{"web"}
+			},
+			event:    taskFixture(stoppedTask("", app(1))),
+			wantSkip: "service 'api' not in allowed list",
+		},
+		{
+			name:   "deployment failed",
+			event:  deploymentFixture("SERVICE_DEPLOYMENT_FAILED", "ECS deployment failed: tasks failed to start."),
+			wantOK: true, wantSeverity: SeverityCritical, wantTitle: "ECS Service Rollback/Failure: api",
+		},
+		{
+			name:   "deployment rolled back by the circuit breaker",
+			event:  deploymentFixture("SERVICE_DEPLOYMENT_FAILED", "ECS deployment circuit breaker: rolling back to deploymentId ecs-svc/1234567890."),
+			wantOK: true, wantSeverity: SeverityCritical, wantTitle: "circuit breaker triggered a rollback",
+		},
+		{
+			name:     "deployment in progress",
+			event:    deploymentFixture("SERVICE_DEPLOYMENT_IN_PROGRESS", ""),
+			wantSkip: "no alert conditions met",
+		},
+		{
+			name:     "deployment completed, success alerts off",
+			edit:     func(c *Config) { c.AlertOnDeploySuccess = false },
+			event:    deploymentFixture("SERVICE_DEPLOYMENT_COMPLETED", ""),
+			wantSkip: "no alert conditions met",
+		},
+		{
+			name:   "deployment completed, success alerts on",
+			edit:   func(c *Config) { c.AlertOnDeploySuccess = true },
+			event:  deploymentFixture("SERVICE_DEPLOYMENT_COMPLETED", ""),
+			wantOK: true, wantSeverity: SeverityInfo, wantTitle: "Deployment succeeded: api",
+		},
+		{
+			name:     "failed deployment of an unmonitored service",
+			edit:     func(c *Config) { c.MonitoredServices = []string{"web"} },
+			event:    deploymentFixture("SERVICE_DEPLOYMENT_FAILED", ""),
+			wantSkip: "service 'api' not in allowed list",
+		},
+		{
+			name: "capacity placement failure",
+			edit: func(c *Config) { c.CapacityAlerts = true },
+			event: fixture(serviceActionDetailType, ECSServiceActionDetail{
+				EventName: "SERVICE_TASK_PLACEMENT_FAILURE", ClusterArn: testClusterArn, Reason: "RESOURCE:FARGATE",
+			}, testServiceArn),
+			wantOK: true, wantSeverity: SeverityWarning, wantTitle: "ECS capacity unavailable",
+		},
+		{
+			name: "placement failure that isn't about capacity",
+			edit: func(c *Config) { c.CapacityAlerts = true },
+			event: fixture(serviceActionDetailType, ECSServiceActionDetail{
+				EventName: "SERVICE_TASK_PLACEMENT_FAILURE", ClusterArn: testClusterArn, Reason: "placement constraint unmet",
+			}, testServiceArn),
+			wantSkip: "no alert conditions met",
+		},
+		{
+			// Findings aren't about services, so the allow-list doesn't apply
+			name:   "GuardDuty finding",
+			edit:   func(c *Config) { c.MonitoredServices = []string{"web"} },
+			event:  fixture(guardDutyDetailType, map[string]any{"title": "Port probe", "severity": 8}),
+			wantOK: true, wantSeverity: SeverityCritical, wantTitle: "GuardDuty: Port probe",
+		},
+		{
+			name:   "RDS failover",
+			edit:   func(c *Config) { c.MonitoredServices = []string{"web"} },
+			event:  fixture("RDS DB Instance Event", RDSEventDetail{EventCategories: []string{"failover"}, SourceIdentifier: "orders"}),
+			wantOK: true, wantSeverity: SeverityCritical, wantTitle: "RDS failover: orders",
+		},
+		{
+			name:     "RDS event in no alerting category",
+			event:    fixture("RDS DB Instance Event", RDSEventDetail{EventCategories: []string{"backup"}, SourceIdentifier: "orders"}),
+			wantSkip: "no alerting RDS event category",
+		},
+		{
+			name:   "certificate expiring",
+			event:  fixture(acmExpiryDetailType, ACMExpiryDetail{DaysToExpiry: 5, CommonName: "api.example.com"}),
+			wantOK: true, wantSeverity: SeverityCritical, wantTitle: "expires in 5 days",
+		},
+		{
+			name:     "unknown detail type",
+			edit:     func(c *Config) { c.AlertUnknownType = false },
+			event:    fixture("EC2 Instance State-change Notification", map[string]string{"state": "stopping"}),
+			wantSkip: "unhandled detail type",
+		},
+		{
+			name:   "unknown detail type, ALERT_ON_UNKNOWN_DETAIL_TYPE on",
+			edit:   func(c *Config) { c.AlertUnknownType = true },
+			event:  fixture("EC2 Instance State-change Notification", map[string]string{"state": "stopping"}),
+			wantOK: true, wantSeverity: SeverityInfo, wantTitle: "Unhandled event type",
+		},
+		{
+			name:    "malformed task detail",
+			event:   fixture("ECS Task State Change", json.RawMessage(`{"containers": "not a list"}`)),
+			wantErr: true,
+		},
+		{
+			name:    "malformed deployment detail",
+			event:   fixture("ECS Deployment State Change", json.RawMessage(`[1, 2]`)),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withConfig(t, tt.edit)
+			if tt.tagged != nil {
+				setTaggedServices(tt.tagged)
+			}
+			ok, alert, skip, err := shouldAlert(tt.event(t))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if ok != tt.wantOK {
+				t.Fatalf("ok = %v (skip reason %q), want %v", ok, skip, tt.wantOK)
+			}
+			if !strings.Contains(skip, tt.wantSkip) {
+				t.Errorf("skip reason = %q, want it to contain %q", skip, tt.wantSkip)
+			}
+			if !ok {
+				return
+			}
+			if skip != "" {
+				t.Errorf("skip reason = %q for an alert", skip)
+			}
+			if alert.Severity != tt.wantSeverity {
+				t.Errorf("severity = %s, want %s", alert.Severity, tt.wantSeverity)
+			}
+			if !strings.Contains(alert.Title, tt.wantTitle) {
+				t.Errorf("title = %q, want it to contain %q", alert.Title, tt.wantTitle)
+			}
+		})
+	}
+}
+
+// Builds the event once the test's config is in place
+type eventFixture func(t *testing.T) events.CloudWatchEvent
+
+func fixture(detailType string, detail any, resources ...string) eventFixture {
+	return func(t *testing.T) events.CloudWatchEvent { return testEvent(t, detailType, detail, resources...) }
+}
+
+func taskFixture(detail ECSTaskDetail) eventFixture {
+	return fixture("ECS Task State Change", detail)
+}
+
+func deploymentFixture(eventName, reason string) eventFixture {
+	return func(t *testing.T) events.CloudWatchEvent { return deploymentEvent(t, eventName, reason) }
+}
+
+// Fails the first write of a count whose key starts with prefix, as a
+// throttled table would
+type failingOnceStore struct {
+	stateStore
+	prefix string
+	failed bool
+}
+
+func (s *failingOnceStore) IncrementSliding(ctx context.Context, key, member string, ttl time.Duration) (int64, error) {
+	if !s.failed && strings.HasPrefix(key, s.prefix) {
+		s.failed = true
+		return 0, errors.New("ProvisionedThroughputExceededException")
+	}
+	return s.stateStore.IncrementSliding(ctx, key, member, ttl)
+}
+
+// A check failing in STRICT_MODE makes Lambda redeliver the event. The
+// checks before it have already recorded the event, and must recognise the
+// redelivery rather than suppress it as a repeat; a different event is
+// still a repeat.
+func TestSuppressReasonRedelivery(t *testing.T) {
+	tests := []struct {
+		name     string
+		event    eventFixture
+		wantSkip string // for a later event reporting the same thing
+	}{
+		{
+			name:     "stopped task",
+			event:    taskFixture(stoppedTask("Essential container in task exited", ContainerInfo{Name: "app", ExitCode: 1})),
+			wantSkip: "task status already reported",
+		},
+		{
+			name:     "deployment event",
+			event:    deploymentFixture("SERVICE_DEPLOYMENT_FAILED", "ECS deployment circuit breaker: rolling back"),
+			wantSkip: "deployment event already received",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withConfig(t, func(c *Config) {
+				c.StrictMode = true
+				c.DeployDedupWindow = time.Minute
+				c.RestartThreshold = 3
+				c.RestartWindow = time.Hour
+				c.FirstFailureOnly = true
+				c.AggregateWindow = 5 * time.Minute
+				c.EscalateAfter = 5
+				c.EscalateWindow = time.Hour
+				c.Cooldown = 5 * time.Minute
+			})
+			states = &failingOnceStore{stateStore: states, prefix: escalateKeyPrefix}
+			savedID := invocationEventID
+			t.Cleanup(func() { invocationEventID = savedID })
+
+			event := tt.event(t)
+			ok, alert, _, err := shouldAlert(event)
+			if !ok || err != nil {
+				t.Fatalf("shouldAlert = %v, %v", ok, err)
+			}
+			suppress := func(eventID string) (string, error) {
+				invocationEventID = eventID
+				a := alert
+				return suppressReason(context.Background(), &a)
+			}
+
+			if _, err := suppress(event.ID); err == nil {
+				t.Fatal("the failing escalation count returned no error in STRICT_MODE")
+			}
+			if reason, err := suppress(event.ID); reason != "" || err != nil {
+				t.Fatalf("redelivery suppressed: reason %q, err %v", reason, err)
+			}
+			if alert.TaskFamily != "" {
+				if n, _ := getCount(context.Background(), restartKeyPrefix+alert.Cluster+"/"+alert.TaskFamily); n != 1 {
+					t.Errorf("restart count = %d after one event delivered twice, want 1", n)
+				}
+			}
+			if reason, _ := suppress("0d3c9a42-5d6e-4b8f-9a1c-2e7f6b5d4c3a"); !strings.Contains(reason, tt.wantSkip) {
+				t.Errorf("a later event: reason %q, want %q", reason, tt.wantSkip)
+			}
+		})
+	}
+}
+
+func TestDecodeDetail(t *testing.T) {
+	object := `{"taskArn": "` + testTaskArn + `", "lastStatus": "STOPPED", "containers": [{"name": "app", "exitCode": 1}]}`
+	quoted, _ := json.Marshal(object)
+
+	tests := []struct {
+		name    string
+		raw     string
+		wantErr bool
+	}{
+		{"object", object, false},
+		{"double-encoded string", string(quoted), false},
+		{"double-encoded with surrounding whitespace", "\n  " + string(quoted) + "  \n", false},
+		{"string that isn't JSON inside", `"not json"`, true},
+		{"unterminated string", `"{\"taskArn\": `, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var detail ECSTaskDetail
+			err := decodeDetail(json.RawMessage(tt.raw), &detail)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if detail.TaskArn != testTaskArn || detail.LastStatus != "STOPPED" || len(detail.Containers) != 1 || detail.Containers[0].ExitCode != 1 {
+				t.Errorf("decoded %+v", detail)
+			}
+		})
+	}
+}
+
+// Both forms of the same event alert identically
+func TestShouldAlertDoubleEncodedDetail(t *testing.T) {
+	withConfig(t, nil)
+	task := stoppedTask("Essential container in task exited", ContainerInfo{Name: "app", ExitCode: 1, Reason: "Essential container exited"})
+	object, _ := json.Marshal(task)
+	quoted, _ := json.Marshal(string(object))
+
+	_, want, _, err := shouldAlert(testEvent(t, "ECS Task State Change", json.RawMessage(object)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok, got, _, err := shouldAlert(testEvent(t, "ECS Task State Change", json.RawMessage(quoted)))
+	if err != nil || !ok {
+		t.Fatalf("double-encoded detail: ok=%v err=%v", ok, err)
+	}
+	if got.Title != want.Title || got.Resource != want.Resource || strings.Join(got.Details, "\n") != strings.Join(want.Details, "\n") {
+		t.Errorf("double-encoded detail gave %q %q, object gave %q %q", got.Title, got.Details, want.Title, want.Details)
+	}
+}
+
+// A forced alert skips the deployment, scale-in and cooldown checks that
+// would hold back any other task failure
+func TestSuppressReasonForcedAlert(t *testing.T) {
+	forced, err := parseReasonPatterns([]string{"OutOfMemoryError"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	withConfig(t, func(c *Config) {
+		c.ForceAlertReasons = forced
+		c.SuppressDuringDeploy = true
+		c.DeployTimeout = 30 * time.Minute
+		c.DeployGrace = 2 * time.Minute
+		c.Cooldown = 5 * time.Minute
+	})
+	ctx := context.Background()
+	savedID := invocationEventID
+	t.Cleanup(func() { invocationEventID = savedID })
+
+	// The service is deploying and an earlier alert started its cooldown
+	deploy := deploymentEvent(t, "SERVICE_DEPLOYMENT_IN_PROGRESS", "")
+	deploy.Time = time.Now()
+	if err := trackDeploymentEvent(ctx, deploy); err != nil {
+		t.Fatal(err)
+	}
+	states.Claim(ctx, cooldownKeyPrefix+cooldownKey(Alert{Service: "api", Severity: SeverityCritical}), "earlier-event", time.Hour)
+
+	for i, tt := range []struct {
+		reason   string
+		wantSkip string
+	}{
+		{"Essential container exited", "service is deploying"},
+		{"OutOfMemoryError: Container killed due to memory usage", ""},
+	} {
+		task := stoppedTask("Essential container in task exited", ContainerInfo{Name: "app", ExitCode: 137, Reason: tt.reason})
+		task.TaskArn += strconv.Itoa(i)
+		ok, alert, _, err := shouldAlert(testEvent(t, "ECS Task State Change", task))
+		if !ok || err != nil {
+			t.Fatalf("%s: shouldAlert = %v, %v", tt.reason, ok, err)
+		}
+		invocationEventID = "event-" + strconv.Itoa(i)
+		if skip, err := suppressReason(ctx, &alert); skip != tt.wantSkip || err != nil {
+			t.Errorf("%s: skip reason %q (err %v), want %q", tt.reason, skip, err, tt.wantSkip)
+		}
+	}
+}
+
+// The channels all run against one SEND_BUDGET_SECONDS deadline
+func TestHandleRequestSendBudget(t *testing.T) {
+	withConfig(t, func(c *Config) { c.SendBudget = 50 * time.Millisecond })
+	sent := captureAlerts(t)
+	var remaining time.Duration
+	notifiers["json"] = notifierFunc{"json", func(ctx context.Context, alert Alert) error {
+		deadline, ok := ctx.Deadline()
+		if !ok {
+			t.Error("the alert was sent without the SEND_BUDGET_SECONDS deadline")
+		}
+		remaining = time.Until(deadline)
+		*sent = append(*sent, alert)
+		return nil
+	}}
+
+	event := testEvent(t, "ECS Task State Change", stoppedTask("Essential container in task exited",
+		ContainerInfo{Name: "app", ExitCode: 137, Reason: "OutOfMemoryError"}))
+	if err := runHandleRequest(t, context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+	if len(*sent) != 1 {
+		t.Fatalf("sent %d alerts, want 1", len(*sent))
+	}
+	if remaining <= 0 || remaining > 50*time.Millisecond {
+		t.Errorf("%v of the send budget was left, want at most the 50ms budget", remaining)
+	}
+	if summary.decision != "alerted" {
+		t.Errorf("decision = %q, want alerted", summary.decision)
+	}
+}
+
+// What the scheduled sweeps send runs against SEND_BUDGET_SECONDS too
+func TestRunScheduledSweepsSendBudget(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.DeployTimeout = 30 * time.Minute
+		c.PendingTimeout = 10 * time.Minute
+		c.SendBudget = time.Minute
+	})
+	sent := captureAlerts(t)
+	var deadlines []bool
+	notifiers["json"] = notifierFunc{"json", func(ctx context.Context, alert Alert) error {
+		_, ok := ctx.Deadline()
+		deadlines = append(deadlines, ok)
+		*sent = append(*sent, alert)
+		return nil
+	}}
+
+	ctx := context.Background()
+	if err := trackDeploymentEvent(ctx, deploymentEvent(t, "SERVICE_DEPLOYMENT_IN_PROGRESS", "")); err != nil {
+		t.Fatal(err)
+	}
+	pending := stoppedTask("")
+	pending.LastStatus = "PENDING"
+	if err := trackPendingTask(ctx, testEvent(t, "ECS Task State Change", pending)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runScheduledSweeps(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(*sent) != 2 {
+		t.Fatalf("sweeps sent %d alerts, want the stuck deployment and the pending task", len(*sent))
+	}
+	for i, ok := range deadlines {
+		if !ok {
+			t.Errorf("%q was sent without the SEND_BUDGET_SECONDS deadline", (*sent)[i].Title)
+		}
+	}
+}
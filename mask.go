@@ -0,0 +1,48 @@
+package main
+
+import "regexp"
+
+// The account ID is the fifth colon-separated part of an ARN
+var arnAccountRe = regexp.MustCompile(`(arn:aws[a-z-]*:[a-z0-9-]+:[a-z0-9-]*:)(\d{12})(:)`)
+
+var accountIDRe = regexp.MustCompile(`^\d{12}$`)
+
+// Replaces the account ID in every ARN found in s with ****
+func maskARN(s string) string {
+	return arnAccountRe.ReplaceAllString(s, "${1}****${3}")
+}
+
+// Masks a bare account ID, as GuardDuty and Security Hub report it. An
+// account name from ACCOUNT_NAME or the IAM alias is kept.
+func maskAccountID(s string) string {
+	if accountIDRe.MatchString(s) {
+		return "****"
+	}
+	return s
+}
+
+// Returns a copy of the alert with account IDs masked in everything a
+// channel renders. Links are left alone: console URLs carry no account ID.
+func maskAlertARNs(a Alert) Alert {
+	a.Resource = maskARN(a.Resource)
+	a.Title = maskARN(a.Title)
+	a.Account = maskAccountID(a.Account)
+	if a.Fields != nil {
+		fields := make(map[string]string, len(a.Fields))
+		for label, value := range a.Fields {
+			fields[label] = maskARN(value)
+		}
+		if account, ok := fields["Account"]; ok {
+			fields["Account"] = maskAccountID(account)
+		}
+		a.Fields = fields
+	}
+	if a.Details != nil {
+		details := make([]string, len(a.Details))
+		for i, line := range a.Details {
+			details[i] = maskARN(line)
+		}
+		a.Details = details
+	}
+	return a
+}
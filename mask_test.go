@@ -0,0 +1,42 @@
+package main
+
+import "testing"
+
+func TestMaskAlertARNs(t *testing.T) {
+	alert := Alert{
+		Resource: testTaskArn,
+		Title:    "Task stopped in " + testClusterArn,
+		Account:  "123456789012",
+		Fields: map[string]string{
+			"Account": "123456789012",
+			"Service": "api",
+		},
+		Details: []string{"role arn:aws:iam::123456789012:role/api"},
+	}
+	got := maskAlertARNs(alert)
+	if got.Resource != "arn:aws:ecs:us-east-1:****:task/prod/0b69d5c0d0a946ab8c7e5c1e4a7d1f3e" {
+		t.Errorf("resource = %q", got.Resource)
+	}
+	if got.Title != "Task stopped in arn:aws:ecs:us-east-1:****:cluster/prod" {
+		t.Errorf("title = %q", got.Title)
+	}
+	if got.Account != "****" {
+		t.Errorf("account = %q, want it masked", got.Account)
+	}
+	if got.Fields["Account"] != "****" || got.Fields["Service"] != "api" {
+		t.Errorf("fields = %v", got.Fields)
+	}
+	if got.Details[0] != "role arn:aws:iam::****:role/api" {
+		t.Errorf("details = %v", got.Details)
+	}
+	if alert.Fields["Account"] != "123456789012" || alert.Details[0] != "role arn:aws:iam::123456789012:role/api" {
+		t.Error("the original alert was changed")
+	}
+}
+
+func TestMaskAlertARNsKeepsAccountName(t *testing.T) {
+	got := maskAlertARNs(Alert{Account: "prod-payments", Fields: map[string]string{"Account": "prod-payments"}})
+	if got.Account != "prod-payments" || got.Fields["Account"] != "prod-payments" {
+		t.Errorf("account name was masked: %q, %v", got.Account, got.Fields)
+	}
+}
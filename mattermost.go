@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+)
+
+// Mattermost accepts the Slack webhook shape. "channel" overrides the
+// webhook's default channel; "username" is left out on purpose because
+// Mattermost silently ignores it unless username overrides are enabled
+// server-side.
+type mattermostPayload struct {
+	Text    string `json:"text"`
+	Channel string `json:"channel,omitempty"`
+}
+
+func sendMattermostNotification(ctx context.Context, alert Alert) error {
+	if cfg.MattermostWebhookURL == "" {
+		slog.Info("Mattermost webhook URL not configured, skipping Mattermost notification")
+		return nil
+	}
+
+	payloadBytes, err := json.Marshal(mattermostPayload{Text: renderMarkdown(alert), Channel: cfg.MattermostChannel})
+	if err != nil {
+		return err
+	}
+
+	return postJSON(ctx, "Mattermost", cfg.MattermostWebhookURL, payloadBytes)
+}
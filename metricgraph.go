@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+)
+
+// Created in init only when ATTACH_METRIC_GRAPH is on
+var cloudwatchClient *cloudwatch.Client
+
+// Renders the service's ECS CPU and memory utilisation over the last hour as
+// a PNG. Returns nil when the feature is off, the alert isn't about an ECS
+// service, or the API call fails; the email then goes out without it.
+func metricGraph(ctx context.Context, alert Alert) []byte {
+	if cloudwatchClient == nil || alert.Service == "" || alert.Cluster == "" {
+		return nil
+	}
+
+	widget, err := json.Marshal(map[string]any{
+		"title":  "CPU / memory: " + alert.Service,
+		"region": cfg.AWSRegion,
+		"start":  "-PT1H",
+		"period": 60,
+		"width":  600,
+		"height": 300,
+		"metrics": [][]any{
+			{"AWS/ECS", "CPUUtilization", "ClusterName", alert.Cluster, "ServiceName", alert.Service},
+			{".", "MemoryUtilization", ".", ".", ".", "."},
+		},
+	})
+	if err != nil {
+		return nil
+	}
+
+	out, err := cloudwatchClient.GetMetricWidgetImage(ctx, &cloudwatch.GetMetricWidgetImageInput{
+		MetricWidget: aws.String(string(widget)),
+		OutputFormat: aws.String("png"),
+	})
+	if err != nil {
+		slog.Warn("Skipping metric graph attachment", "service", alert.Service, "error", err)
+		return nil
+	}
+	return out.MetricWidgetImage
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	tagging "github.com/aws/aws-sdk-go-v2/service/resourcegroupstaggingapi"
+	"github.com/aws/aws-sdk-go-v2/service/resourcegroupstaggingapi/types"
+)
+
+// Only set when MONITORED_SERVICES_TAG is
+var taggingClient *tagging.Client
+
+// The services the MONITORED_SERVICES_TAG query found, kept across warm
+// invocations for MONITORED_TAG_TTL_SECONDS. A failed query isn't cached,
+// so the next invocation tries again; until one succeeds, MONITORED_SERVICES
+// is used.
+var (
+	taggedServicesMu      sync.Mutex
+	taggedServices        []string
+	taggedServicesFetched time.Time
+)
+
+// Splits MONITORED_SERVICES_TAG, e.g. "alerting=enabled"
+func parseMonitoredTag(spec string) (key, value string, err error) {
+	if spec == "" {
+		return "", "", nil
+	}
+	key, value, ok := strings.Cut(spec, "=")
+	if !ok || strings.TrimSpace(key) == "" {
+		return "", "", fmt.Errorf("%q is not key=value", spec)
+	}
+	return strings.TrimSpace(key), strings.TrimSpace(value), nil
+}
+
+// Runs the tag query again once the cached result has expired. Called at the
+// start of each invocation, since isMonitored only reads the cache. On error
+// the previous result, if any, stays in use.
+func refreshMonitoredServices(ctx context.Context) {
+	if cfg.MonitoredTagKey == "" || taggingClient == nil {
+		return
+	}
+	taggedServicesMu.Lock()
+	defer taggedServicesMu.Unlock()
+	if !taggedServicesFetched.IsZero() && time.Since(taggedServicesFetched) < cfg.MonitoredTagTTL {
+		return
+	}
+	names, err := queryTaggedServices(ctx)
+	if err != nil {
+		slog.Warn("Error querying tagged services, keeping the previous allow-list", "error", err)
+		return
+	}
+	taggedServices, taggedServicesFetched = names, time.Now()
+	slog.Info("Resolved monitored services from tags", "tag", cfg.MonitoredTagKey+"="+cfg.MonitoredTagValue, "services", names)
+}
+
+// Replaces the cached tag query result; nil forgets it, so MONITORED_SERVICES
+// applies until the next query succeeds
+func setTaggedServices(names []string) {
+	taggedServicesMu.Lock()
+	defer taggedServicesMu.Unlock()
+	taggedServices, taggedServicesFetched = names, time.Time{}
+	if names != nil {
+		taggedServicesFetched = time.Now()
+	}
+}
+
+// The allow-list isMonitored checks: the tagged services once a query has
+// succeeded, else MONITORED_SERVICES. tagged is true for the former, where
+// an empty list means no service is tagged rather than all services.
+func monitoredServices() (services []string, tagged bool) {
+	if cfg.MonitoredTagKey == "" {
+		return cfg.MonitoredServices, false
+	}
+	taggedServicesMu.Lock()
+	defer taggedServicesMu.Unlock()
+	if taggedServicesFetched.IsZero() {
+		return cfg.MonitoredServices, false
+	}
+	return taggedServices, true
+}
+
+// Lists the names of the ECS services in this region carrying the tag
+func queryTaggedServices(ctx context.Context) ([]string, error) {
+	paginator := tagging.NewGetResourcesPaginator(taggingClient, &tagging.GetResourcesInput{
+		ResourceTypeFilters: []string{"ecs:service"},
+		TagFilters:          []types.TagFilter{{Key: aws.String(cfg.MonitoredTagKey), Values: []string{cfg.MonitoredTagValue}}},
+	})
+	names := []string{}
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range page.ResourceTagMappingList {
+			names = append(names, getResourceName(aws.ToString(r.ResourceARN)))
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import "context"
+
+// Sends alerts to one channel. Name is the channel's name in the table in
+// routing.go, which holds how alerts are routed to it.
+type Notifier interface {
+	Name() string
+	Send(ctx context.Context, alert Alert) error
+}
+
+// Adapts a plain send function, for channels with nothing else to hold
+type notifierFunc struct {
+	name string
+	send func(context.Context, Alert) error
+}
+
+func (n notifierFunc) Name() string { return n.name }
+
+func (n notifierFunc) Send(ctx context.Context, alert Alert) error { return n.send(ctx, alert) }
+
+// The notifiers of configured channels, by name. notify sends through these
+// and treats channels missing here as not configured.
+var notifiers = make(map[string]Notifier)
+
+func registerNotifier(n Notifier) {
+	notifiers[n.Name()] = n
+}
+
+// Registers a notifier for every channel whose target is set. Runs at the
+// end of init, once SSM lookups have filled in cfg. A new channel adds its
+// line here and its entry in the channel table.
+func registerNotifiers() {
+	if cfg.SlackWebhookURL != "" {
+		registerNotifier(slackNotifier{})
+	}
+	if cfg.SecuritySlackURL != "" {
+		registerNotifier(notifierFunc{"security_slack", sendSecuritySlackNotification})
+	}
+	if cfg.MattermostWebhookURL != "" {
+		registerNotifier(notifierFunc{"mattermost", sendMattermostNotification})
+	}
+	if cfg.GoogleChatWebhookURL != "" {
+		registerNotifier(notifierFunc{"googlechat", sendGoogleChatNotification})
+	}
+	if cfg.ChatbotTopicARN != "" {
+		registerNotifier(notifierFunc{"chatbot", sendChatbotNotification})
+	}
+	if cfg.PagerDutyRoutingKey != "" {
+		registerNotifier(notifierFunc{"pagerduty", sendPagerDutyNotification})
+	}
+	if len(cfg.SMSNumbers) > 0 {
+		registerNotifier(notifierFunc{"sms", sendSMSNotification})
+	}
+	if cfg.JSONWebhookURL != "" {
+		registerNotifier(notifierFunc{"json", sendJSONNotification})
+	}
+	if cfg.SenderEmail != "" && hasEmailRecipients() {
+		registerNotifier(emailNotifier{})
+	}
+}
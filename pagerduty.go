@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"maps"
+	"slices"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const pagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// With PAGERDUTY_AUTO_RESOLVE, the dedup keys of a service's triggered
+// incidents are listed under this prefix and the service's cluster/name
+// until it recovers and they are resolved
+const pagerDutyOpenKeyPrefix = "pdopen#"
+
+// One incident per service, or per resource for alerts that aren't about an
+// ECS service, matching what recordFailure counts. Empty for an alert with
+// neither, so PagerDuty opens an incident of its own. DEDUP_KEY_TEMPLATE,
+// when set, decides instead, as it does for cooldown.
+func pagerDutyDedupKey(alert Alert) string {
+	if cfg.CustomDedupKey {
+		return "ecs-alerter/" + dedupKey(alert)
+	}
+	key := escalationKey(alert)
+	if key == "" {
+		return ""
+	}
+	return "ecs-alerter/" + key
+}
+
+// A PagerDuty Events API v2 trigger event
+type pagerDutyEvent struct {
+	RoutingKey  string           `json:"routing_key"`
+	EventAction string           `json:"event_action"`
+	DedupKey    string           `json:"dedup_key,omitempty"`
+	Payload     pagerDutyPayload `json:"payload"`
+	Links       []pagerDutyLink  `json:"links,omitempty"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string            `json:"summary"`
+	Source        string            `json:"source"`
+	Severity      string            `json:"severity"`
+	Timestamp     string            `json:"timestamp,omitempty"`
+	Component     string            `json:"component,omitempty"`
+	Group         string            `json:"group,omitempty"`
+	Class         string            `json:"class,omitempty"`
+	CustomDetails map[string]string `json:"custom_details,omitempty"`
+}
+
+type pagerDutyLink struct {
+	Href string `json:"href"`
+}
+
+// Builds the trigger event for an alert. The dedup key is per service (or
+// resource), so repeated escalations for one service update a single
+// incident instead of opening a new one each time.
+func pagerDutyMessage(alert Alert) pagerDutyEvent {
+	source := alert.Resource
+	if source == "" {
+		source = alert.Service
+	}
+	event := pagerDutyEvent{
+		RoutingKey:  cfg.PagerDutyRoutingKey,
+		EventAction: "trigger",
+		DedupKey:    pagerDutyDedupKey(alert),
+		Payload: pagerDutyPayload{
+			// PagerDuty's severities include ours as-is
+			Summary:       truncateRunes(alert.subject(), 1024),
+			Source:        source,
+			Severity:      string(alert.Severity),
+			Component:     alert.Service,
+			Group:         alert.Cluster,
+			Class:         alert.DetailType,
+			CustomDetails: alert.Fields,
+		},
+	}
+	// The summary is too short to carry them, so the banner and incident ID
+	// ride along with the fields
+	if alert.Banner != "" || alert.IncidentID != "" {
+		details := maps.Clone(alert.Fields)
+		if details == nil {
+			details = make(map[string]string)
+		}
+		if alert.Banner != "" {
+			details["Banner"] = alert.Banner
+		}
+		if alert.IncidentID != "" {
+			details["Incident ID"] = alert.IncidentID
+		}
+		event.Payload.CustomDetails = details
+	}
+	if !alert.Timestamp.IsZero() {
+		event.Payload.Timestamp = alert.Timestamp.UTC().Format("2006-01-02T15:04:05Z")
+	}
+	for _, link := range alert.Links {
+		event.Links = append(event.Links, pagerDutyLink{Href: link})
+	}
+	return event
+}
+
+func sendPagerDutyNotification(ctx context.Context, alert Alert) error {
+	if cfg.PagerDutyRoutingKey == "" {
+		slog.Info("PagerDuty routing key not configured, skipping PagerDuty notification")
+		return nil
+	}
+
+	payloadBytes, err := json.Marshal(pagerDutyMessage(alert))
+	if err != nil {
+		return err
+	}
+
+	if err := postJSON(ctx, "PagerDuty", pagerDutyEventsURL, payloadBytes); err != nil {
+		return err
+	}
+	// Only services report recovering, so only their incidents are resolved
+	if cfg.PagerDutyAutoResolve && alert.Service != "" {
+		key := pagerDutyDedupKey(alert)
+		if _, err := states.Append(ctx, pagerDutyOpenKeyPrefix+alert.Cluster+"/"+alert.Service, key, stateTTL); err != nil {
+			slog.Warn("Error recording open PagerDuty incident, it won't be resolved automatically", "dedup_key", key, "error", err)
+		}
+	}
+	return nil
+}
+
+// The service an event says has recovered: a deployment that completed, or
+// the service reaching steady state
+func recoveredService(event events.CloudWatchEvent) (cluster, service string, ok bool) {
+	switch event.DetailType {
+	case "ECS Deployment State Change":
+		detail, err := parseDeploymentDetail(event)
+		if err != nil || detail.EventName != "SERVICE_DEPLOYMENT_COMPLETED" {
+			return "", "", false
+		}
+		return getResourceName(detail.Cluster), getResourceName(detail.Service), true
+	case serviceActionDetailType:
+		var detail struct {
+			EventName  string `json:"eventName"`
+			ClusterArn string `json:"clusterArn"`
+		}
+		if decodeDetail(event.Detail, &detail) != nil || detail.EventName != "SERVICE_STEADY_STATE" || len(event.Resources) == 0 {
+			return "", "", false
+		}
+		return getResourceName(detail.ClusterArn), getResourceName(event.Resources[0]), true
+	}
+	return "", "", false
+}
+
+// Sends a resolve event for each of the service's incidents when the event
+// shows the service recovered and incidents were triggered for it. Services
+// with no open incident are left alone, so routine deployments send nothing.
+func resolvePagerDutyIncident(ctx context.Context, event events.CloudWatchEvent) error {
+	if !cfg.PagerDutyAutoResolve || !cfg.PagerDutyEnabled || cfg.PagerDutyRoutingKey == "" {
+		return nil
+	}
+	cluster, service, ok := recoveredService(event)
+	if !ok {
+		return nil
+	}
+	openKey := pagerDutyOpenKeyPrefix + cluster + "/" + service
+	keys, err := states.List(ctx, openKey)
+	if err != nil || len(keys) == 0 {
+		return err
+	}
+
+	// Each trigger lists its key, so one incident can be listed many times
+	slices.Sort(keys)
+	for _, key := range slices.Compact(keys) {
+		payloadBytes, err := json.Marshal(map[string]string{
+			"routing_key":  cfg.PagerDutyRoutingKey,
+			"event_action": "resolve",
+			"dedup_key":    key,
+		})
+		if err != nil {
+			return err
+		}
+		if err := postJSON(ctx, "PagerDuty", pagerDutyEventsURL, payloadBytes); err != nil {
+			return err
+		}
+		slog.Info("PagerDuty incident resolved", "dedup_key", key, "detail_type", event.DetailType)
+	}
+	return states.Delete(ctx, openKey)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestPagerDutyDedupKey(t *testing.T) {
+	withConfig(t, nil)
+	tests := []struct {
+		name  string
+		alert Alert
+		want  string
+	}{
+		{"ECS service", Alert{Cluster: "prod", Service: "api", Resource: testTaskArn}, "ecs-alerter/prod/api"},
+		{"RDS instance", Alert{Resource: "arn:aws:rds:us-east-1:123456789012:db:orders"}, "ecs-alerter/arn:aws:rds:us-east-1:123456789012:db:orders"},
+		{"GuardDuty finding", Alert{Resource: "arn:aws:guardduty:us-east-1:123456789012:detector/d1/finding/a2b4"}, "ecs-alerter/arn:aws:guardduty:us-east-1:123456789012:detector/d1/finding/a2b4"},
+		{"neither", Alert{Title: "Unhandled event type"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pagerDutyDedupKey(tt.alert); got != tt.want {
+				t.Errorf("pagerDutyDedupKey = %q, want %q", got, tt.want)
+			}
+			if got := pagerDutyMessage(tt.alert).DedupKey; got != tt.want {
+				t.Errorf("trigger dedup_key = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// With DEDUP_KEY_TEMPLATE the template decides what one incident is, as it
+// does for cooldown
+func TestPagerDutyDedupKeyTemplate(t *testing.T) {
+	tmpl, err := parseDedupKeyTemplate("{{.Service}}/{{.ExitCode}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	withConfig(t, func(c *Config) {
+		c.DedupKeyTemplate = tmpl
+		c.CustomDedupKey = true
+	})
+	oom := Alert{Cluster: "prod", Service: "api", ExitCode: 137}
+	if got := pagerDutyMessage(oom).DedupKey; got != "ecs-alerter/api/137" {
+		t.Errorf("dedup_key = %q, want the template's key", got)
+	}
+	if got, other := pagerDutyDedupKey(oom), pagerDutyDedupKey(Alert{Cluster: "prod", Service: "api", ExitCode: 1}); got == other {
+		t.Errorf("alerts the template tells apart share the incident %q", got)
+	}
+}
+
+// Records the PagerDuty events sent instead of posting them
+type pagerDutyRecorder struct{ events []map[string]string }
+
+func (r *pagerDutyRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var event map[string]string
+	json.NewDecoder(req.Body).Decode(&event)
+	r.events = append(r.events, event)
+	return &http.Response{StatusCode: http.StatusAccepted, Body: io.NopCloser(strings.NewReader(`{"status":"success"}`)), Request: req}, nil
+}
+
+// Every incident triggered for a service under the template's keys is
+// resolved when the service recovers, each once
+func TestResolvePagerDutyIncidentTemplateKeys(t *testing.T) {
+	tmpl, err := parseDedupKeyTemplate("{{.Service}}/{{.ExitCode}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	withConfig(t, func(c *Config) {
+		c.DedupKeyTemplate = tmpl
+		c.CustomDedupKey = true
+		c.PagerDutyEnabled = true
+		c.PagerDutyAutoResolve = true
+		c.PagerDutyRoutingKey = "R0ut1ngK3y"
+		c.WebhookMaxRetries = 0
+	})
+	recorder := &pagerDutyRecorder{}
+	saved := httpClient
+	t.Cleanup(func() { httpClient = saved })
+	httpClient = &http.Client{Transport: recorder}
+	ctx := context.Background()
+
+	for _, code := range []int{137, 1, 137} {
+		alert := Alert{Severity: SeverityCritical, Title: "ECS Task Failure: api", Cluster: "prod", Service: "api", ExitCode: code}
+		if err := sendPagerDutyNotification(ctx, alert); err != nil {
+			t.Fatal(err)
+		}
+	}
+	recorder.events = nil
+
+	recovered := deploymentEvent(t, "SERVICE_DEPLOYMENT_COMPLETED", "")
+	if err := resolvePagerDutyIncident(ctx, recovered); err != nil {
+		t.Fatal(err)
+	}
+	var resolved []string
+	for _, e := range recorder.events {
+		if e["event_action"] != "resolve" {
+			t.Errorf("sent %q, want only resolves", e["event_action"])
+		}
+		resolved = append(resolved, e["dedup_key"])
+	}
+	if want := []string{"ecs-alerter/api/1", "ecs-alerter/api/137"}; !slices.Equal(resolved, want) {
+		t.Errorf("resolved %q, want %q", resolved, want)
+	}
+
+	// Nothing is left open to resolve again
+	recorder.events = nil
+	if err := resolvePagerDutyIncident(ctx, recovered); err != nil {
+		t.Fatal(err)
+	}
+	if len(recorder.events) != 0 {
+		t.Errorf("second recovery sent %d events", len(recorder.events))
+	}
+}
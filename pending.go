@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const pendingKeyPrefix = "pending#"
+
+// Likely causes by the status a task is stuck in, shown in the alert
+var pendingCauseHints = map[string]string{
+	"PROVISIONING": "Stuck in PROVISIONING usually means the task's network interface couldn't be created: check for free IP addresses in the task's subnets and for the account's ENI quota.",
+	"PENDING":      "Stuck in PENDING usually means the image or secrets can't be fetched: check the route to ECR and Secrets Manager (NAT gateway or VPC endpoints) and the task execution role.",
+}
+
+// A task waiting to start, for the pending task sweep
+type pendingTask struct {
+	StartedAt  time.Time `json:"startedAt"`
+	LastStatus string    `json:"lastStatus"`
+	Cluster    string    `json:"cluster"`
+	Group      string    `json:"group"`
+	Region     string    `json:"region"`
+	Alerted    bool      `json:"alerted,omitempty"`
+}
+
+// Records when a task entered PROVISIONING or PENDING so the scheduled sweep
+// can spot tasks that never start. Any later status removes the record.
+func trackPendingTask(ctx context.Context, event events.CloudWatchEvent) error {
+	if !durableStates || cfg.PendingTimeout <= 0 {
+		return nil
+	}
+	var detail ECSTaskDetail
+	if err := decodeDetail(event.Detail, &detail); err != nil || detail.TaskArn == "" {
+		// Parse errors are reported by the alerting path
+		return nil
+	}
+	key := pendingKeyPrefix + detail.TaskArn
+
+	switch detail.LastStatus {
+	case "PROVISIONING", "PENDING":
+		// Keeps the first time the task was seen pending across both statuses
+		var p pendingTask
+		found, err := getRecord(ctx, key, &p)
+		if err != nil {
+			return err
+		}
+		if !found {
+			p.StartedAt = event.Time
+			if p.StartedAt.IsZero() {
+				p.StartedAt = time.Now()
+			}
+		}
+		p.LastStatus, p.Cluster, p.Group, p.Region = detail.LastStatus, detail.ClusterArn, detail.Group, event.Region
+		return putRecord(ctx, key, p, taskStateTTL)
+	default:
+		return states.Delete(ctx, key)
+	}
+}
+
+// Runs on the schedule rule and alerts once for every task that has been
+// PROVISIONING or PENDING longer than PENDING_TIMEOUT_SECONDS.
+func sweepPendingTasks(ctx, sendCtx context.Context) error {
+	if !durableStates || cfg.PendingTimeout <= 0 {
+		return nil
+	}
+	keys, err := states.Keys(ctx, pendingKeyPrefix)
+	if err != nil {
+		return fmt.Errorf("failed to list pending tasks: %v", err)
+	}
+	cutoff := time.Now().Add(-cfg.PendingTimeout)
+
+	for _, key := range keys {
+		var p pendingTask
+		found, err := getRecord(ctx, key, &p)
+		if err != nil {
+			slog.Error("Error reading pending task", "key", key, "error", err)
+			continue
+		}
+		if !found || p.Alerted || !p.StartedAt.Before(cutoff) {
+			continue
+		}
+		taskArn := key[len(pendingKeyPrefix):]
+		serviceName := getServiceNameFromGroup(p.Group)
+
+		if isMonitored(serviceName) {
+			cluster := getResourceName(p.Cluster)
+			alert := Alert{
+				DetailType: "ECS Task State Change",
+				Resource:   taskArn,
+				Severity:   SeverityWarning,
+				Title:      fmt.Sprintf("ECS Task Stuck in %s: %s", p.LastStatus, serviceName),
+				Service:    serviceName,
+				Cluster:    cluster,
+				Fields: map[string]string{
+					"Task ARN":    taskArn,
+					"Last Status": p.LastStatus,
+					"Pending For": time.Since(p.StartedAt).Round(time.Minute).String(),
+				},
+				Links:     ecsConsoleLinks(p.Region, cluster, serviceName, taskArn),
+				Timestamp: time.Now(),
+			}
+			if hint := pendingCauseHints[p.LastStatus]; hint != "" {
+				alert.Details = []string{hint}
+			}
+			notify(sendCtx, alert)
+		}
+
+		// Alert once per task; the record goes when the task moves on or stops
+		p.Alerted = true
+		if err := putRecord(ctx, key, p, taskStateTTL); err != nil {
+			slog.Error("Error marking pending task as alerted", "key", key, "error", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSweepPendingTasks(t *testing.T) {
+	withConfig(t, func(c *Config) { c.PendingTimeout = 10 * time.Minute })
+	sent := captureAlerts(t)
+	ctx := context.Background()
+	track := func(arn, status string, at time.Time) {
+		t.Helper()
+		task := stoppedTask("")
+		task.TaskArn, task.LastStatus = arn, status
+		event := testEvent(t, "ECS Task State Change", task)
+		event.Time = at
+		if err := trackPendingTask(ctx, event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fresh := "arn:aws:ecs:us-east-1:123456789012:task/prod/fresh"
+
+	// PROVISIONING then PENDING keeps the first time the task was seen
+	track(testTaskArn, "PROVISIONING", testEventTime)
+	track(testTaskArn, "PENDING", time.Now())
+	track(fresh, "PENDING", time.Now())
+
+	if err := sweepPendingTasks(ctx, ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(*sent) != 1 {
+		t.Fatalf("sweep sent %d alerts, want 1 for the task pending since %s", len(*sent), testEventTime)
+	}
+	if got := (*sent)[0]; got.Title != "ECS Task Stuck in PENDING: api" || got.Resource != testTaskArn {
+		t.Errorf("alert = %q for %q", got.Title, got.Resource)
+	}
+
+	if err := sweepPendingTasks(ctx, ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(*sent) != 1 {
+		t.Error("second sweep alerted again")
+	}
+
+	track(testTaskArn, "RUNNING", time.Now())
+	if ok, _ := getRecord(ctx, pendingKeyPrefix+testTaskArn, &pendingTask{}); ok {
+		t.Error("task still tracked after it started running")
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// A PLAYBOOKS entry: what to try first, and where the runbook is, for
+// alerts of a detail-type, alerts whose reason matches a pattern, or both
+type playbook struct {
+	DetailType string `json:"detail_type"`
+	// Same syntax as SCALING_REASON_PATTERNS entries
+	Reason  string `json:"reason"`
+	Fix     string `json:"fix"`
+	Runbook string `json:"runbook"`
+
+	pattern *reasonPattern
+}
+
+// How specific a playbook is: one that names both a detail-type and a
+// reason beats one that only names a reason, which beats a detail-type
+func (p playbook) specificity() int {
+	n := 0
+	if p.pattern != nil {
+		n += 2
+	}
+	if p.DetailType != "" {
+		n++
+	}
+	return n
+}
+
+func (p playbook) matches(alert Alert) bool {
+	if p.DetailType != "" && p.DetailType != alert.DetailType {
+		return false
+	}
+	return p.pattern == nil || p.pattern.matches(alertReasonText(alert))
+}
+
+// Parses PLAYBOOKS, a JSON list such as
+// [{"detail_type": "ECS Task State Change", "reason": "/connection refused/",
+// "fix": "check DB connection string", "runbook": "https://..."}]
+func parsePlaybooks(spec string) ([]playbook, error) {
+	if strings.TrimSpace(spec) == "" {
+		return nil, nil
+	}
+	var playbooks []playbook
+	if err := json.Unmarshal([]byte(spec), &playbooks); err != nil {
+		return nil, fmt.Errorf("not a JSON list of playbooks: %v", err)
+	}
+	for i, p := range playbooks {
+		if p.Fix == "" && p.Runbook == "" {
+			return nil, fmt.Errorf("playbook %d has neither fix nor runbook", i+1)
+		}
+		if p.DetailType == "" && p.Reason == "" {
+			return nil, fmt.Errorf("playbook %d matches nothing, set detail_type or reason", i+1)
+		}
+		if p.Reason != "" {
+			patterns, err := parseReasonPatterns([]string{p.Reason})
+			if err != nil {
+				return nil, fmt.Errorf("playbook %d: %v", i+1, err)
+			}
+			playbooks[i].pattern = &patterns[0]
+		}
+	}
+	return playbooks, nil
+}
+
+// The text reason patterns are matched against: the title, the Reason
+// field, and every detail line
+func alertReasonText(alert Alert) string {
+	return strings.Join(append([]string{alert.Title, alert.Fields["Reason"]}, alert.Details...), "\n")
+}
+
+// Adds a "Likely Fix" field from the most specific matching playbook; the
+// first listed wins a tie
+func applyPlaybook(alert Alert) Alert {
+	var best *playbook
+	for i := range cfg.Playbooks {
+		p := &cfg.Playbooks[i]
+		if p.matches(alert) && (best == nil || p.specificity() > best.specificity()) {
+			best = p
+		}
+	}
+	if best == nil {
+		return alert
+	}
+	var parts []string
+	if best.Fix != "" {
+		parts = append(parts, best.Fix)
+	}
+	if best.Runbook != "" {
+		parts = append(parts, "runbook: "+best.Runbook)
+	}
+	alert.setField("Likely Fix", strings.Join(parts, "; "))
+	return alert
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// A named set of overrides from PROFILES, so one deployment can give each
+// EventBridge rule its own filters and routing. Unset fields keep the
+// values from the environment.
+type profile struct {
+	MonitoredServices []string `json:"monitored_services"`
+	IgnoredContainers []string `json:"ignored_containers"`
+	MinAlertExitCode  *int     `json:"min_alert_exit_code"`
+	// Same syntax as SEVERITY_ROUTES
+	SeverityRoutes string `json:"severity_routes"`
+	// When set, only these channels get the profile's alerts
+	Channels []string `json:"channels"`
+
+	routes map[Severity][]string
+}
+
+// Parses PROFILES, a JSON object from profile name to overrides, e.g.
+// {"payments": {"monitored_services": ["api"], "channels": ["slack"]}}
+func parseProfiles(spec string) (map[string]profile, error) {
+	if strings.TrimSpace(spec) == "" {
+		return nil, nil
+	}
+	var profiles map[string]profile
+	if err := json.Unmarshal([]byte(spec), &profiles); err != nil {
+		return nil, fmt.Errorf("not a JSON object of profile name to settings: %v", err)
+	}
+	for name, p := range profiles {
+		if p.SeverityRoutes != "" {
+			routes, err := parseSeverityRoutes(p.SeverityRoutes)
+			if err != nil {
+				return nil, fmt.Errorf("profile %q: %v", name, err)
+			}
+			p.routes = routes
+		}
+		channels, err := parseChannelOrder(p.Channels)
+		if err != nil {
+			return nil, fmt.Errorf("profile %q: %v", name, err)
+		}
+		p.Channels = channels
+		profiles[name] = p
+	}
+	return profiles, nil
+}
+
+// Returns c with the profile's overrides applied
+func (p profile) apply(c Config) Config {
+	if p.MonitoredServices != nil {
+		c.MonitoredServices = p.MonitoredServices
+		// A profile's own list beats the tag query
+		c.MonitoredTagKey = ""
+	}
+	if p.IgnoredContainers != nil {
+		c.IgnoredContainers = p.IgnoredContainers
+	}
+	if p.MinAlertExitCode != nil {
+		c.MinAlertExitCode = *p.MinAlertExitCode
+	}
+	if p.routes != nil {
+		c.SeverityRoutes = p.routes
+	}
+	if len(p.Channels) > 0 {
+		c.ProfileChannels = p.Channels
+	}
+	return c
+}
+
+// Picks the profile for an invocation: a top-level "profile" field in the
+// payload (set with an EventBridge input transformer), else ACTIVE_PROFILE
+func profileName(payload json.RawMessage) string {
+	var probe struct {
+		Profile string `json:"profile"`
+	}
+	if json.Unmarshal(payload, &probe) == nil && probe.Profile != "" {
+		return probe.Profile
+	}
+	return cfg.ActiveProfile
+}
+
+// Switches cfg to the named profile for one invocation and returns the func
+// that switches it back. The Lambda runtime handles one invocation at a time
+// per process, so swapping the package config is safe. Unknown names are
+// logged and leave cfg as it is.
+func useProfile(name string) (restore func()) {
+	if name == "" {
+		return func() {}
+	}
+	p, ok := cfg.Profiles[name]
+	if !ok {
+		slog.Warn("Unknown profile, using the default configuration", "profile", name)
+		return func() {}
+	}
+	base := cfg
+	cfg = p.apply(cfg)
+	return func() { cfg = base }
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// A daily window, in minutes after midnight in TIMEZONE, during which only
+// critical alerts are sent. end may be before start for windows that span
+// midnight, e.g. 22:00-07:00.
+type quietHours struct {
+	start, end int
+}
+
+// Parses QUIET_HOURS, e.g. "22:00-07:00". Empty means no quiet hours.
+func parseQuietHours(spec string) (*quietHours, error) {
+	if strings.TrimSpace(spec) == "" {
+		return nil, nil
+	}
+	from, to, ok := strings.Cut(spec, "-")
+	if !ok {
+		return nil, fmt.Errorf("%q is not HH:MM-HH:MM", spec)
+	}
+	start, err := parseClock(from)
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseClock(to)
+	if err != nil {
+		return nil, err
+	}
+	if start == end {
+		return nil, fmt.Errorf("%q starts and ends at the same time", spec)
+	}
+	return &quietHours{start: start, end: end}, nil
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, want HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Reports whether t falls inside the window, in the configured time zone
+func (q *quietHours) contains(t time.Time) bool {
+	if q == nil {
+		return false
+	}
+	if cfg.Location != nil {
+		t = t.In(cfg.Location)
+	}
+	m := t.Hour()*60 + t.Minute()
+	if q.start < q.end {
+		return m >= q.start && m < q.end
+	}
+	return m >= q.start || m < q.end
+}
+
+const quietDropsKey = "quiet#dropped"
+
+// Counts an alert dropped during quiet hours
+func noteQuietDrop(ctx context.Context) error {
+	_, err := states.Increment(ctx, quietDropsKey, eventToken(), stateTTL)
+	return err
+}
+
+// Returns how many alerts were dropped during quiet hours since the last
+// call, resetting the count
+func takeQuietDrops(ctx context.Context) (int64, error) {
+	dropped, err := getCount(ctx, quietDropsKey)
+	if err != nil || dropped == 0 {
+		return 0, err
+	}
+	return dropped, states.PutWithTTL(ctx, quietDropsKey, "0", stateTTL)
+}
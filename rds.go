@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+type RDSEventDetail struct {
+	EventCategories  []string `json:"EventCategories"`
+	SourceType       string   `json:"SourceType"`
+	SourceArn        string   `json:"SourceArn"`
+	SourceIdentifier string   `json:"SourceIdentifier"`
+	Message          string   `json:"Message"`
+	EventID          string   `json:"EventID"`
+}
+
+var defaultRDSAlertCategories = []string{"failure", "failover", "low storage"}
+
+// Builds an alert for an RDS DB instance event. ok is false when none of the
+// event's categories are in RDS_ALERT_CATEGORIES.
+func rdsAlert(event events.CloudWatchEvent) (ok bool, alert Alert, err error) {
+	var detail RDSEventDetail
+	if err := decodeDetail(event.Detail, &detail); err != nil {
+		return false, Alert{}, fmt.Errorf("failed to unmarshal RDS event detail: %v", err)
+	}
+
+	var matched []string
+	for _, c := range detail.EventCategories {
+		for _, want := range cfg.RDSAlertCategories {
+			if strings.EqualFold(c, want) {
+				matched = append(matched, c)
+			}
+		}
+	}
+	if len(matched) == 0 {
+		return false, Alert{}, nil
+	}
+
+	alert = Alert{
+		DetailType: event.DetailType,
+		Resource:   detail.SourceArn,
+		Severity:   SeverityCritical,
+		Title:      fmt.Sprintf("RDS %s: %s", strings.Join(matched, ", "), detail.SourceIdentifier),
+		Fields: map[string]string{
+			"DB Instance": detail.SourceIdentifier,
+			"Event":       detail.EventID,
+			"Message":     detail.Message,
+		},
+		Timestamp: event.Time,
+	}
+	if event.Region != "" && detail.SourceIdentifier != "" {
+		alert.Links = []string{fmt.Sprintf("https://%s.console.aws.amazon.com/rds/home?region=%s#database:id=%s",
+			event.Region, event.Region, detail.SourceIdentifier)}
+	}
+	return true, alert, nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"crypto/sha256"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How many recent messages are remembered per channel
+const repeatHistorySize = 32
+
+type sentMessage struct {
+	hash   [sha256.Size]byte
+	sentAt time.Time
+}
+
+// The last messages each channel delivered, newest last, for skipping exact
+// repeats within REPEAT_WINDOW_SECONDS. Lives in memory only, so it covers
+// a crash loop hitting one warm container rather than every Lambda.
+var (
+	recentMu       sync.Mutex
+	recentMessages = make(map[string][]sentMessage)
+)
+
+// What makes two messages the same: the subject, where the failure happened
+// and why. The timestamp, the task ARN and running counts such as restarts
+// differ on every repeat of a crash loop, so they are left out.
+func messageHash(alert Alert) [sha256.Size]byte {
+	parts := []string{
+		alert.subject(),
+		alert.Cluster,
+		alert.Service,
+		alert.Fields["Reason"],
+		strconv.Itoa(alert.ExitCode),
+	}
+	parts = append(parts, alert.Details...)
+	return sha256.Sum256([]byte(strings.Join(parts, "\n")))
+}
+
+// Reports whether the channel delivered this exact message within the window
+func isRecentRepeat(channel string, alert Alert) bool {
+	if cfg.RepeatWindow <= 0 {
+		return false
+	}
+	hash := messageHash(alert)
+	recentMu.Lock()
+	defer recentMu.Unlock()
+	for _, m := range recentMessages[channel] {
+		if m.hash == hash && time.Since(m.sentAt) < cfg.RepeatWindow {
+			return true
+		}
+	}
+	return false
+}
+
+// Remembers a delivered message, dropping the oldest once the channel's
+// history is full
+func recordSentMessage(channel string, alert Alert) {
+	if cfg.RepeatWindow <= 0 {
+		return
+	}
+	recentMu.Lock()
+	defer recentMu.Unlock()
+	history := append(recentMessages[channel], sentMessage{hash: messageHash(alert), sentAt: time.Now()})
+	if len(history) > repeatHistorySize {
+		history = history[len(history)-repeatHistorySize:]
+	}
+	recentMessages[channel] = history
+}
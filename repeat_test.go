@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func repeatAlert(taskArn string, at time.Time, restarts string) Alert {
+	return Alert{
+		DetailType: "ECS Task State Change",
+		Resource:   taskArn,
+		ExitCode:   137,
+		Severity:   SeverityCritical,
+		Title:      "ECS task failed: api",
+		Service:    "api",
+		Cluster:    "prod",
+		Fields:     map[string]string{"Restarts": restarts},
+		Details:    []string{"Container 'app' exited with code 137 (OutOfMemoryError)"},
+		Timestamp:  at,
+	}
+}
+
+func TestIsRecentRepeat(t *testing.T) {
+	withConfig(t, func(c *Config) { c.RepeatWindow = time.Minute })
+	saved := recentMessages
+	t.Cleanup(func() { recentMessages = saved })
+	recentMessages = make(map[string][]sentMessage)
+
+	first := repeatAlert(testTaskArn, testEventTime, "2")
+	recordSentMessage("Slack", first)
+
+	// The next task of a crash loop: new ARN, time and restart count
+	next := repeatAlert("arn:aws:ecs:us-east-1:123456789012:task/prod/7c1d", testEventTime.Add(20*time.Second), "3")
+	if !isRecentRepeat("Slack", next) {
+		t.Error("a repeat differing only in task, time and restarts was not recognised")
+	}
+	if isRecentRepeat("Email", next) {
+		t.Error("a repeat was matched against another channel's history")
+	}
+
+	other := next
+	other.ExitCode = 1
+	other.Details = []string{"Container 'app' exited with code 1 (Essential container exited)"}
+	if isRecentRepeat("Slack", other) {
+		t.Error("a different failure was taken for a repeat")
+	}
+	elsewhere := next
+	elsewhere.Service = "worker"
+	if isRecentRepeat("Slack", elsewhere) {
+		t.Error("the same failure in another service was taken for a repeat")
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// With RESTART_ALERT_THRESHOLD, task failures are counted per task family
+// over RESTART_WINDOW_SECONDS. The family key lets the service's recovery
+// find the count again, since steady-state events only name the service.
+const (
+	restartKeyPrefix       = "restart#"
+	restartFamilyKeyPrefix = "restartfamily#"
+)
+
+// The family of a task definition ARN, e.g. "api" for
+// "arn:aws:ecs:...:task-definition/api:42"
+func taskFamily(taskDefinitionArn string) string {
+	family, _, _ := strings.Cut(getResourceName(taskDefinitionArn), ":")
+	return family
+}
+
+// Counts a task failure against its family and returns the count when it
+// has just gone past RESTART_ALERT_THRESHOLD, 0 otherwise. Only the
+// crossing is reported, so later restarts in the window go through
+// cooldown like any other failure.
+func countRestart(ctx context.Context, alert Alert) (int64, error) {
+	if cfg.RestartThreshold <= 0 || alert.TaskFamily == "" {
+		return 0, nil
+	}
+	n, err := states.Increment(ctx, restartKeyPrefix+alert.Cluster+"/"+alert.TaskFamily, eventToken(), cfg.RestartWindow)
+	if err != nil {
+		return 0, err
+	}
+	if n == 1 && alert.Service != "" {
+		err := states.PutWithTTL(ctx, restartFamilyKeyPrefix+alert.Cluster+"/"+alert.Service, alert.TaskFamily, cfg.RestartWindow)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if n != int64(cfg.RestartThreshold)+1 {
+		return 0, nil
+	}
+	return n, nil
+}
+
+// Clears the restart count of a service that completed a deployment or
+// reached steady state, so a later crash loop is counted from scratch
+func resetRestartCount(ctx context.Context, event events.CloudWatchEvent) error {
+	if cfg.RestartThreshold <= 0 {
+		return nil
+	}
+	cluster, service, ok := recoveredService(event)
+	if !ok {
+		return nil
+	}
+	family, found, err := states.Get(ctx, restartFamilyKeyPrefix+cluster+"/"+service)
+	if err != nil || !found {
+		return err
+	}
+	slog.Info("Service stabilised, resetting restart count", "service", service, "family", family)
+	return states.Delete(ctx, restartKeyPrefix+cluster+"/"+family)
+}
+
+// RESTART_WINDOW_SECONDS as the Restarts field shows it, e.g. "10m", "45s"
+// or "1h30m", without the zero units Duration.String adds
+func restartWindowText() string {
+	text := cfg.RestartWindow.String()
+	if strings.HasSuffix(text, "m0s") {
+		text = strings.TrimSuffix(text, "0s")
+	}
+	if strings.HasSuffix(text, "h0m") {
+		text = strings.TrimSuffix(text, "0m")
+	}
+	return text
+}
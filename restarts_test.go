@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRestartWindowText(t *testing.T) {
+	for window, want := range map[time.Duration]string{
+		45 * time.Second:             "45s",
+		10 * time.Minute:             "10m",
+		90 * time.Second:             "1m30s",
+		time.Hour:                    "1h",
+		90 * time.Minute:             "1h30m",
+		time.Hour + 5*time.Second:    "1h0m5s",
+		2*time.Hour + 30*time.Minute: "2h30m",
+	} {
+		withConfig(t, func(c *Config) { c.RestartWindow = window })
+		if got := restartWindowText(); got != want {
+			t.Errorf("restartWindowText(%v) = %q, want %q", window, got, want)
+		}
+	}
+}
+
+// A service that stabilises has its count removed, so the next crash loop
+// is counted from one
+func TestResetRestartCount(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.RestartThreshold = 2
+		c.RestartWindow = 30 * time.Second
+	})
+	ctx := context.Background()
+	alert := Alert{Cluster: "prod", Service: "api", TaskFamily: "api"}
+	savedID := invocationEventID
+	t.Cleanup(func() { invocationEventID = savedID })
+	for _, id := range []string{"event-1", "event-2"} {
+		invocationEventID = id
+		if _, err := countRestart(ctx, alert); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := resetRestartCount(ctx, deploymentEvent(t, "SERVICE_DEPLOYMENT_COMPLETED", "")); err != nil {
+		t.Fatal(err)
+	}
+	if _, found, err := states.Get(ctx, restartKeyPrefix+"prod/api"); err != nil || found {
+		t.Errorf("restart count still stored after the reset: found=%v err=%v", found, err)
+	}
+
+	invocationEventID = "event-3"
+	if n, err := countRestart(ctx, alert); err != nil || n != 0 {
+		t.Fatalf("countRestart = %d, %v", n, err)
+	}
+	if n, _ := getCount(ctx, restartKeyPrefix+"prod/api"); n != 1 {
+		t.Errorf("count after the reset = %d, want 1", n)
+	}
+}
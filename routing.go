@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A notification channel as seen by notify. name is what SEVERITY_ROUTES
+// and CHANNEL_ORDER refer to and what its Notifier registers as, flag is
+// the env variable that switches the channel off.
+// external channels are third-party services that get masked ARNs when
+// MASK_ARNS is set. paging channels wake people up, so they only get an
+// alert when it escalated or SEVERITY_ROUTES names them for its severity.
+// criticalOnly channels cost money per message and never get anything below
+// critical, whatever the routes say. fullDetails channels get every detail
+// line; the rest are capped at MAX_CONTAINER_LINES. security channels get
+// security findings and nothing else.
+type channel struct {
+	name         string
+	label        string
+	flag         string
+	enabled      bool
+	external     bool
+	paging       bool
+	criticalOnly bool
+	fullDetails  bool
+	security     bool
+}
+
+func channels() []channel {
+	return []channel{
+		{name: "slack", label: "Slack", flag: "SLACK_ENABLED", enabled: cfg.SlackEnabled, external: true},
+		{name: "security_slack", label: "Security Slack", flag: "SECURITY_SLACK_ENABLED", enabled: cfg.SecuritySlackEnabled, external: true, security: true},
+		{name: "mattermost", label: "Mattermost", flag: "MATTERMOST_ENABLED", enabled: cfg.MattermostEnabled, external: true},
+		{name: "googlechat", label: "Google Chat", flag: "GOOGLE_CHAT_ENABLED", enabled: cfg.GoogleChatEnabled, external: true},
+		{name: "chatbot", label: "AWS Chatbot", flag: "CHATBOT_ENABLED", enabled: cfg.ChatbotEnabled, external: true},
+		{name: "pagerduty", label: "PagerDuty", flag: "PAGERDUTY_ENABLED", enabled: cfg.PagerDutyEnabled, external: true, paging: true},
+		{name: "sms", label: "SMS", flag: "SMS_ENABLED", enabled: cfg.SMSEnabled, criticalOnly: true},
+		{name: "json", label: "JSON webhook", flag: "JSON_WEBHOOK_ENABLED", enabled: cfg.JSONWebhookEnabled, fullDetails: true},
+		{name: "email", label: "Email", flag: "EMAIL_ENABLED", enabled: cfg.EmailEnabled, fullDetails: true},
+	}
+}
+
+// Returns the channels in send order: those named in CHANNEL_ORDER first,
+// in that order, then the rest in table order
+func orderedChannels() []channel {
+	all := channels()
+	ordered := make([]channel, 0, len(all))
+	for _, name := range cfg.ChannelOrder {
+		for _, ch := range all {
+			if ch.name == name {
+				ordered = append(ordered, ch)
+			}
+		}
+	}
+	for _, ch := range all {
+		if !contains(cfg.ChannelOrder, ch.name) {
+			ordered = append(ordered, ch)
+		}
+	}
+	return ordered
+}
+
+// Checks that CHANNEL_ORDER only names known channels
+func parseChannelOrder(names []string) ([]string, error) {
+	known := make(map[string]bool)
+	for _, ch := range channels() {
+		known[ch.name] = true
+	}
+	for i, name := range names {
+		name = strings.ToLower(name)
+		if !known[name] {
+			return nil, fmt.Errorf("unknown channel %q", name)
+		}
+		names[i] = name
+	}
+	return names, nil
+}
+
+// Parses SEVERITY_ROUTES, e.g. "critical=slack,email;warning=slack;info=log".
+// "log" (or an empty list) means log only. Severities that aren't listed,
+// and everything when the variable is unset, go to every channel.
+func parseSeverityRoutes(spec string) (map[Severity][]string, error) {
+	known := map[string]bool{"log": true}
+	for _, ch := range channels() {
+		known[ch.name] = true
+	}
+
+	routes := make(map[Severity][]string)
+	for _, rule := range strings.Split(spec, ";") {
+		if strings.TrimSpace(rule) == "" {
+			continue
+		}
+		sevName, list, ok := strings.Cut(rule, "=")
+		if !ok {
+			return nil, fmt.Errorf("rule %q is not severity=channels", rule)
+		}
+		sev := Severity(strings.ToLower(strings.TrimSpace(sevName)))
+		if sev != SeverityCritical && sev != SeverityWarning && sev != SeverityInfo {
+			return nil, fmt.Errorf("unknown severity %q", sevName)
+		}
+
+		names := []string{}
+		for _, name := range strings.Split(list, ",") {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name == "" {
+				continue
+			}
+			if !known[name] {
+				return nil, fmt.Errorf("unknown channel %q for %s", name, sev)
+			}
+			names = append(names, name)
+		}
+		routes[sev] = names
+	}
+	return routes, nil
+}
+
+// Icons used when SEVERITY_ICONS is unset: the warning sign failures have
+// always carried, and a tick for deploy confirmations
+const defaultSeverityIcons = "critical=⚠️;info=✅"
+
+// Parses SEVERITY_ICONS, e.g. "critical=[CRIT];warning=[WARN]". Severities
+// that aren't listed get no icon.
+func parseSeverityIcons(spec string) (map[Severity]string, error) {
+	icons := make(map[Severity]string)
+	for _, rule := range strings.Split(spec, ";") {
+		if strings.TrimSpace(rule) == "" {
+			continue
+		}
+		sevName, icon, ok := strings.Cut(rule, "=")
+		if !ok {
+			return nil, fmt.Errorf("rule %q is not severity=icon", rule)
+		}
+		sev := Severity(strings.ToLower(strings.TrimSpace(sevName)))
+		if sev != SeverityCritical && sev != SeverityWarning && sev != SeverityInfo {
+			return nil, fmt.Errorf("unknown severity %q", sevName)
+		}
+		icons[sev] = strings.TrimSpace(icon)
+	}
+	return icons, nil
+}
+
+// Orders severities from info (0) to critical (2)
+func severityRank(sev Severity) int {
+	switch sev {
+	case SeverityCritical:
+		return 2
+	case SeverityWarning:
+		return 1
+	}
+	return 0
+}
+
+// Parses MIN_SEVERITY; unset means info, i.e. no floor
+func parseMinSeverity(name string) (Severity, error) {
+	sev := Severity(strings.ToLower(strings.TrimSpace(name)))
+	switch sev {
+	case "":
+		return SeverityInfo, nil
+	case SeverityCritical, SeverityWarning, SeverityInfo:
+		return sev, nil
+	}
+	return "", fmt.Errorf("unknown severity %q", name)
+}
+
+// Reports whether notify should send alert to ch
+func routesAlert(ch channel, alert Alert) bool {
+	if ch.security {
+		return isSecurityAlert(alert)
+	}
+	// Findings only reach the other channels when the security channel is off
+	if isSecurityAlert(alert) && cfg.SecuritySlackEnabled && cfg.SecuritySlackURL != "" {
+		return false
+	}
+	// The identity check reports a broken email channel, so email can't carry it
+	if ch.name == "email" && alert.DetailType == sesIdentityDetailType {
+		return false
+	}
+	if ch.criticalOnly && alert.Severity != SeverityCritical {
+		return false
+	}
+	if ch.paging {
+		return alert.Escalated || contains(cfg.SeverityRoutes[alert.Severity], ch.name)
+	}
+	return routesTo(alert.Severity, ch.name)
+}
+
+// Reports whether alerts of severity sev should be sent to the named channel
+func routesTo(sev Severity, name string) bool {
+	names, ok := cfg.SeverityRoutes[sev]
+	if !ok {
+		return true
+	}
+	return contains(names, name)
+}
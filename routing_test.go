@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+func TestRoutesAlertSecurityFindings(t *testing.T) {
+	slack := channel{name: "slack", external: true}
+	security := channel{name: "security_slack", external: true, security: true}
+	finding := Alert{DetailType: guardDutyDetailType, Severity: SeverityCritical}
+	task := Alert{DetailType: "ECS Task State Change", Severity: SeverityCritical}
+
+	tests := []struct {
+		name    string
+		enabled bool
+		url     string
+		want    bool // whether the finding still reaches Slack
+	}{
+		{"security channel on", true, "https://hooks.slack.com/services/T0/B0/security", false},
+		{"security channel switched off", false, "https://hooks.slack.com/services/T0/B0/security", true},
+		{"security channel without a URL", true, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withConfig(t, func(c *Config) {
+				c.SecuritySlackEnabled = tt.enabled
+				c.SecuritySlackURL = tt.url
+				c.SeverityRoutes = nil
+			})
+			if got := routesAlert(slack, finding); got != tt.want {
+				t.Errorf("finding routed to Slack = %v, want %v", got, tt.want)
+			}
+			if !routesAlert(slack, task) {
+				t.Error("a task alert was kept from Slack")
+			}
+			if !routesAlert(security, finding) || routesAlert(security, task) {
+				t.Error("the security channel should take findings and nothing else")
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"hash/fnv"
+	"math/rand/v2"
+	"sync"
+)
+
+// One generator per service, seeded from the service name, so a given
+// sequence of info alerts is always sampled the same way
+var (
+	samplersMu sync.Mutex
+	samplers   = make(map[string]*rand.Rand)
+)
+
+// Reports whether an info alert is kept under INFO_SAMPLE_RATE, marking
+// kept ones Sampled so channels show readers they see only a share. Critical
+// and warning alerts are never sampled.
+func sampleInfoAlert(alert *Alert) bool {
+	if alert.Severity != SeverityInfo || cfg.InfoSampleRate >= 1 {
+		return true
+	}
+
+	samplersMu.Lock()
+	r, ok := samplers[alert.Service]
+	if !ok {
+		h := fnv.New64a()
+		h.Write([]byte(alert.Service))
+		r = rand.New(rand.NewPCG(h.Sum64(), 0))
+		samplers[alert.Service] = r
+	}
+	kept := r.Float64() < cfg.InfoSampleRate
+	samplersMu.Unlock()
+
+	alert.Sampled = kept
+	return kept
+}
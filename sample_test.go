@@ -0,0 +1,49 @@
+package main
+
+import (
+	"math/rand/v2"
+	"strings"
+	"testing"
+)
+
+// Sampling marks the alert for readers without touching the title the
+// dedup, cooldown and incident keys are built from
+func TestSampleInfoAlertKeepsKeys(t *testing.T) {
+	withConfig(t, func(c *Config) { c.InfoSampleRate = 0.5 })
+	saved := samplers
+	t.Cleanup(func() { samplers = saved })
+	samplers = make(map[string]*rand.Rand)
+
+	unsampled := Alert{Severity: SeverityInfo, Title: "Deployment succeeded: api", Service: "api", Cluster: "prod"}
+	kept := 0
+	for range 20 {
+		alert := unsampled
+		if !sampleInfoAlert(&alert) {
+			if alert.Sampled {
+				t.Error("a dropped alert was marked sampled")
+			}
+			continue
+		}
+		kept++
+		if !alert.Sampled || alert.Title != unsampled.Title {
+			t.Fatalf("kept alert: sampled=%v title=%q", alert.Sampled, alert.Title)
+		}
+		if !strings.HasSuffix(alert.subject(), "Deployment succeeded: api (sampled)") {
+			t.Errorf("subject = %q, want the sampling mark", alert.subject())
+		}
+		if dedupKey(alert) != dedupKey(unsampled) || cooldownKey(alert) != cooldownKey(unsampled) || incidentID(alert) != incidentID(unsampled) {
+			t.Error("sampling changed the alert's keys")
+		}
+		if doc := newAlertDocument(alert); !doc.Sampled || doc.Title != unsampled.Title {
+			t.Errorf("JSON document: sampled=%v title=%q", doc.Sampled, doc.Title)
+		}
+	}
+	if kept == 0 || kept == 20 {
+		t.Errorf("kept %d of 20 at a 0.5 sample rate", kept)
+	}
+
+	critical := Alert{Severity: SeverityCritical, Title: "ECS task failed: api", Service: "api"}
+	if !sampleInfoAlert(&critical) || critical.Sampled {
+		t.Errorf("a critical alert was sampled: sampled=%v", critical.Sampled)
+	}
+}
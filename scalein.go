@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+)
+
+// Only set when ENRICH_FROM_API is on
+var ecsClient *ecs.Client
+
+const (
+	// A stopped task is blamed on a desired-count drop seen this recently
+	scaleInWindow = 10 * time.Minute
+	// How long one DescribeServices answer is reused, so a burst of stopped
+	// tasks from one scale-in costs a single call
+	serviceCacheTTL = 30 * time.Second
+)
+
+type serviceSnapshot struct {
+	fetchedAt time.Time
+	desired   int32
+	// When desired last went down, as seen across snapshots
+	decreasedAt time.Time
+	// Service event messages from the scale-in window
+	events []string
+}
+
+// Snapshots are kept for the whole scale-in window, past serviceCacheTTL,
+// so a later fetch can tell whether the desired count went down
+var serviceCache = newTTLCache[string, *serviceSnapshot]()
+
+// Reports whether a stopped task looks like routine scale-in according to
+// the ECS API rather than its stopped reason: either the service's desired
+// count dropped recently, or a recent service event says ECS stopped this
+// very task. Catches scale-in whose reason text SCALING_REASON_PATTERNS
+// doesn't know.
+func isAPIScaleIn(ctx context.Context, alert Alert) (bool, error) {
+	if ecsClient == nil || alert.Service == unknownServiceName || alert.Cluster == "" {
+		return false, nil
+	}
+	snap, err := describeServiceCached(ctx, alert.Cluster, alert.Service)
+	if err != nil || snap == nil {
+		return false, err
+	}
+	if !snap.decreasedAt.IsZero() && time.Since(snap.decreasedAt) < scaleInWindow {
+		return true, nil
+	}
+	taskID := getResourceName(alert.Resource)
+	for _, msg := range snap.events {
+		// e.g. "(service api) has stopped 2 running tasks: (task 0f1e...) (task 9a8b...)."
+		if strings.Contains(msg, "has stopped") && strings.Contains(msg, taskID) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func describeServiceCached(ctx context.Context, cluster, service string) (*serviceSnapshot, error) {
+	key := cluster + "/" + service
+	prev, _ := serviceCache.Get(key)
+	if prev != nil && time.Since(prev.fetchedAt) < serviceCacheTTL {
+		return prev, nil
+	}
+
+	out, err := ecsClient.DescribeServices(ctx, &ecs.DescribeServicesInput{
+		Cluster:  aws.String(cluster),
+		Services: []string{service},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(out.Services) == 0 {
+		return nil, nil
+	}
+	svc := out.Services[0]
+
+	snap := &serviceSnapshot{fetchedAt: time.Now(), desired: svc.DesiredCount}
+	if prev != nil {
+		snap.decreasedAt = prev.decreasedAt
+		if svc.DesiredCount < prev.desired {
+			snap.decreasedAt = snap.fetchedAt
+		}
+	}
+	for _, e := range svc.Events {
+		if e.CreatedAt != nil && time.Since(*e.CreatedAt) < scaleInWindow {
+			snap.events = append(snap.events, aws.ToString(e.Message))
+		}
+	}
+
+	serviceCache.Set(key, snap, scaleInWindow)
+	return snap, nil
+}
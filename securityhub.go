@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const securityHubDetailType = "Security Hub Findings - Imported"
+
+// Security Hub re-imports a finding each time it is updated, so with
+// COOLDOWN_SECONDS a finding already alerted on is held back for one cooldown.
+// The event that alerted claims the finding, so its redelivery alerts again.
+const securityHubKeyPrefix = "securityhub#"
+
+var defaultSecurityHubSeverities = []string{"CRITICAL", "HIGH"}
+
+var securityHubSeverityLabels = []string{"CRITICAL", "HIGH", "MEDIUM", "LOW", "INFORMATIONAL"}
+
+// One event can carry several findings, each in the AWS Security Finding
+// Format
+type SecurityHubDetail struct {
+	Findings []SecurityHubFinding `json:"findings"`
+}
+
+type SecurityHubFinding struct {
+	ID           string `json:"Id"`
+	ProductName  string `json:"ProductName"`
+	Title        string `json:"Title"`
+	Description  string `json:"Description"`
+	AwsAccountID string `json:"AwsAccountId"`
+	RecordState  string `json:"RecordState"`
+	Severity     struct {
+		Label string `json:"Label"`
+	} `json:"Severity"`
+	Compliance struct {
+		Status string `json:"Status"`
+	} `json:"Compliance"`
+	Resources []struct {
+		Type string `json:"Type"`
+		ID   string `json:"Id"`
+	} `json:"Resources"`
+}
+
+// Upper-cases SECURITY_HUB_SEVERITIES and checks each is a Security Hub label
+func parseSecurityHubSeverities(entries []string) ([]string, error) {
+	labels := make([]string, 0, len(entries))
+	for _, e := range entries {
+		label := strings.ToUpper(e)
+		if !contains(securityHubSeverityLabels, label) {
+			return nil, fmt.Errorf("%q is not one of %s", e, strings.Join(securityHubSeverityLabels, ", "))
+		}
+		labels = append(labels, label)
+	}
+	return labels, nil
+}
+
+// Like GuardDuty, a high finding is critical here
+func securityHubSeverity(label string) Severity {
+	switch label {
+	case "CRITICAL", "HIGH":
+		return SeverityCritical
+	case "MEDIUM":
+		return SeverityWarning
+	default:
+		return SeverityInfo
+	}
+}
+
+// Reports whether a finding is worth an alert: active, and at one of the
+// SECURITY_HUB_SEVERITIES
+func qualifiesSecurityHubFinding(f SecurityHubFinding) bool {
+	return f.RecordState != "ARCHIVED" && contains(cfg.SecurityHubLevels, strings.ToUpper(f.Severity.Label))
+}
+
+func securityHubAlert(event events.CloudWatchEvent, f SecurityHubFinding) Alert {
+	resource, resourceType := "", ""
+	if len(f.Resources) > 0 {
+		resource, resourceType = f.Resources[0].ID, f.Resources[0].Type
+	}
+	alert := Alert{
+		DetailType: event.DetailType,
+		Resource:   resource,
+		Severity:   securityHubSeverity(strings.ToUpper(f.Severity.Label)),
+		Title:      fmt.Sprintf("Security Hub: %s", f.Title),
+		Fields: map[string]string{
+			"Severity":      f.Severity.Label,
+			"Compliance":    f.Compliance.Status,
+			"Resource Type": resourceType,
+			"Account":       f.AwsAccountID,
+			"Source":        f.ProductName,
+		},
+		Timestamp: event.Time,
+	}
+	if f.Description != "" {
+		alert.Details = []string{f.Description}
+	}
+	return alert
+}
+
+// Sends one alert per qualifying finding in the event. Findings skip the
+// ECS-specific suppression in suppressReason, whose per-service cooldown
+// would hold back all but the first finding of a batch; each finding has
+// its own repeat window instead. SEND_BUDGET_SECONDS caps the sends for
+// all findings together.
+func notifySecurityHubFindings(ctx context.Context, event events.CloudWatchEvent, detail SecurityHubDetail) error {
+	sendCtx, cancel := context.WithTimeout(ctx, cfg.SendBudget)
+	defer cancel()
+
+	sent := 0
+	for _, f := range detail.Findings {
+		if !qualifiesSecurityHubFinding(f) {
+			continue
+		}
+		if f.ID != "" && cfg.Cooldown > 0 {
+			token := eventToken()
+			holder, err := states.Claim(ctx, securityHubKeyPrefix+f.ID, token, cfg.Cooldown)
+			if err := stateError(err, "Error checking Security Hub finding"); err != nil {
+				return err
+			}
+			if holder != token {
+				slog.Info("Security Hub finding already alerted, skipping", "finding", f.ID)
+				continue
+			}
+		}
+		notify(sendCtx, securityHubAlert(event, f))
+		sent++
+	}
+	if sent == 0 {
+		summary.decide("skipped", "no new Security Hub finding at SECURITY_HUB_SEVERITIES")
+		return nil
+	}
+	summary.decide("alerted", "")
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestHandleRequestMalformedSecurityHubEvent(t *testing.T) {
+	malformed := json.RawMessage(`{"findings": "not a list"}`)
+
+	withConfig(t, nil)
+	if err := runHandleRequest(t, t.Context(), testEvent(t, securityHubDetailType, malformed)); err == nil {
+		t.Error("expected the unmarshal error to fail the invocation")
+	}
+
+	withConfig(t, func(c *Config) { c.StrictMode = true })
+	if err := runHandleRequest(t, t.Context(), testEvent(t, securityHubDetailType, malformed)); err != nil {
+		t.Errorf("STRICT_MODE should drop an unparseable event, got %v", err)
+	}
+	if summary.decision != "dropped" {
+		t.Errorf("decision = %q, want dropped", summary.decision)
+	}
+}
+
+func TestNotifySecurityHubFindingsSendBudget(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.SecurityHubLevels = []string{"HIGH"}
+		c.SendBudget = time.Minute
+	})
+	sent := captureAlerts(t)
+	var deadlines []bool
+	notifiers["json"] = notifierFunc{"json", func(ctx context.Context, alert Alert) error {
+		_, ok := ctx.Deadline()
+		deadlines = append(deadlines, ok)
+		*sent = append(*sent, alert)
+		return nil
+	}}
+
+	var detail SecurityHubDetail
+	for _, id := range []string{"finding/1", "finding/2"} {
+		var f SecurityHubFinding
+		f.ID, f.Title = id, "S3 general purpose buckets should block public access"
+		f.Severity.Label = "HIGH"
+		detail.Findings = append(detail.Findings, f)
+	}
+	if err := runHandleRequest(t, context.Background(), testEvent(t, securityHubDetailType, detail)); err != nil {
+		t.Fatal(err)
+	}
+	if len(*sent) != 2 {
+		t.Fatalf("sent %d alerts, want one per finding", len(*sent))
+	}
+	for i, ok := range deadlines {
+		if !ok {
+			t.Errorf("finding %d was sent without the SEND_BUDGET_SECONDS deadline", i+1)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Sentry reports get their own short deadline: they are usually sent after
+// a channel used up the send budget, which would otherwise cancel them too
+const sentryTimeout = 3 * time.Second
+
+// Where Sentry events for SENTRY_DSN are posted, with the key in the query
+// string as the store endpoint accepts
+type sentryTarget struct {
+	storeURL string
+}
+
+// Parses a DSN of the form https://<key>@<host>[/<path>]/<project>
+func parseSentryDSN(dsn string) (*sentryTarget, error) {
+	if dsn == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, err
+	}
+	key := u.User.Username()
+	path := strings.TrimSuffix(u.Path, "/")
+	i := strings.LastIndex(path, "/")
+	if u.Scheme == "" || u.Host == "" || key == "" || i < 0 || i == len(path)-1 {
+		return nil, fmt.Errorf("%q is not https://<key>@<host>/<project>", dsn)
+	}
+	store := url.URL{
+		Scheme:   u.Scheme,
+		Host:     u.Host,
+		Path:     path[:i] + "/api/" + path[i+1:] + "/store/",
+		RawQuery: url.Values{"sentry_version": {"7"}, "sentry_key": {key}}.Encode(),
+	}
+	return &sentryTarget{storeURL: store.String()}, nil
+}
+
+// A minimal Sentry event, see https://develop.sentry.dev/sdk/data-model/event-payloads/
+type sentryEvent struct {
+	EventID     string            `json:"event_id"`
+	Timestamp   string            `json:"timestamp"`
+	Level       string            `json:"level"`
+	Platform    string            `json:"platform"`
+	Logger      string            `json:"logger"`
+	Environment string            `json:"environment,omitempty"`
+	Message     string            `json:"message"`
+	Tags        map[string]string `json:"tags"`
+	Extra       map[string]string `json:"extra,omitempty"`
+}
+
+// ID of the EventBridge event being handled, for Sentry reports. The Lambda
+// runtime handles one invocation at a time per process, like the logger.
+var invocationEventID string
+
+// Reports a failed channel send to Sentry when SENTRY_DSN is set, so
+// failures of the alerter itself reach someone rather than only the logs.
+// Errors reporting are logged and otherwise ignored.
+func reportSendFailure(ctx context.Context, channel string, alert Alert, sendErr error) {
+	if cfg.Sentry == nil {
+		return
+	}
+	id := make([]byte, 16)
+	rand.Read(id)
+	event := sentryEvent{
+		EventID:     hex.EncodeToString(id),
+		Timestamp:   time.Now().UTC().Format(time.RFC3339),
+		Level:       "error",
+		Platform:    "go",
+		Logger:      "ecs-alerter",
+		Environment: cfg.Environment,
+		Message:     fmt.Sprintf("%s notification failed: %v", channel, sendErr),
+		Tags: map[string]string{
+			"channel":     channel,
+			"event_id":    invocationEventID,
+			"detail_type": alert.DetailType,
+		},
+		Extra: map[string]string{
+			"service": alert.Service,
+			"cluster": alert.Cluster,
+			"subject": alert.subject(),
+		},
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		slog.Error("Error encoding Sentry event", "error", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sentryTimeout)
+	defer cancel()
+	if err := postJSON(ctx, "Sentry", cfg.Sentry.storeURL, body); err != nil {
+		slog.Error("Error reporting send failure to Sentry", "error", err)
+	}
+}
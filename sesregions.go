@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ses"
+	"github.com/aws/aws-sdk-go-v2/service/ses/types"
+)
+
+// With SES_REGIONS, SENDER_EMAIL is sent from the first of those regions
+// where it, or its domain, is a verified identity. The choice is kept this
+// long before the regions are checked again.
+const sesRegionRecheck = time.Hour
+
+// The config per-region SES clients are made from, set at init
+var sesAWSConfig aws.Config
+
+// SES clients by region, made the first time a region is needed
+var (
+	sesClientsMu sync.Mutex
+	sesClients   = make(map[string]*ses.Client)
+)
+
+func sesClientFor(region string) *ses.Client {
+	if region == cfg.AWSRegion {
+		return sesClient
+	}
+	sesClientsMu.Lock()
+	defer sesClientsMu.Unlock()
+	if c, ok := sesClients[region]; ok {
+		return c
+	}
+	c := ses.NewFromConfig(sesAWSConfig, func(o *ses.Options) { o.Region = region })
+	sesClients[region] = c
+	return c
+}
+
+// The region picked for SENDER_EMAIL, while it is fresh. A round where no
+// region could be checked isn't kept, so the next send tries again.
+var (
+	sesPrimaryMu      sync.Mutex
+	sesPrimary        sesIdentity
+	sesPrimaryChecked time.Time
+)
+
+// The identity SENDER_EMAIL is sent from: in the Lambda's region without
+// SES_REGIONS, else in the first listed region where it is verified. When
+// none is, the Lambda's region is used and the send reports why.
+func primarySESIdentity(ctx context.Context) sesIdentity {
+	home := sesIdentity{Email: cfg.SenderEmail, Region: cfg.AWSRegion, client: sesClient}
+	if len(cfg.SESRegions) == 0 || cfg.SenderEmail == "" {
+		return home
+	}
+	sesPrimaryMu.Lock()
+	defer sesPrimaryMu.Unlock()
+	if !sesPrimaryChecked.IsZero() && time.Since(sesPrimaryChecked) < sesRegionRecheck {
+		return sesPrimary
+	}
+
+	checked := false
+	for _, region := range cfg.SESRegions {
+		id := sesIdentity{Email: cfg.SenderEmail, Region: region, client: sesClientFor(region)}
+		status, err := sesVerificationStatus(ctx, id)
+		if err != nil {
+			slog.Warn("Error checking SES identity in region", "sender", id.Email, "region", region, "error", err)
+			continue
+		}
+		checked = true
+		if status == types.VerificationStatusSuccess {
+			slog.Info("Selected SES region for sender", "sender", id.Email, "region", region)
+			sesPrimary, sesPrimaryChecked = id, time.Now()
+			return id
+		}
+	}
+	if checked {
+		slog.Warn("Sender not verified in any of SES_REGIONS, using the function's region", "sender", cfg.SenderEmail, "regions", cfg.SESRegions)
+		sesPrimary, sesPrimaryChecked = home, time.Now()
+	}
+	return home
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ses"
+	"github.com/aws/aws-sdk-go-v2/service/ses/types"
+)
+
+// Detail-type of the alerts raised when a sender identity stops being
+// verified. They go to every channel but email, which is the one broken.
+const sesIdentityDetailType = "SES Identity Check"
+
+// A lapsed identity is re-alerted at most this often
+const sesIdentityRealert = 24 * time.Hour
+
+const sesUnverifiedKeyPrefix = "sesunverified#"
+
+// Runs on the schedule rule: checks that SENDER_EMAIL and each fallback
+// identity are still verified in their region, either as an address or
+// through their domain, and alerts through the other channels when one
+// isn't
+func checkSESIdentities(ctx, sendCtx context.Context) error {
+	if !cfg.SESIdentityCheck || !cfg.EmailEnabled || cfg.SenderEmail == "" || !hasEmailRecipients() {
+		return nil
+	}
+	identities := append([]sesIdentity{primarySESIdentity(ctx)}, sesFallbacks...)
+	var errs []error
+	for _, id := range identities {
+		status, err := sesVerificationStatus(ctx, id)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to check SES identity %s (%s): %v", id.Email, id.Region, err))
+			continue
+		}
+		if status == types.VerificationStatusSuccess {
+			continue
+		}
+
+		key := sesUnverifiedKeyPrefix + id.Region + "/" + id.Email
+		n, err := states.Increment(ctx, key, "", sesIdentityRealert)
+		if err != nil {
+			slog.Warn("Error recording unverified SES identity", "sender", id.Email, "error", err)
+		} else if n > 1 {
+			slog.Info("SES identity still not verified, already alerted", "sender", id.Email, "region", id.Region)
+			continue
+		}
+		slog.Error("SES identity not verified", "sender", id.Email, "region", id.Region, "status", status)
+		notify(sendCtx, Alert{
+			DetailType: sesIdentityDetailType,
+			Resource:   id.Email,
+			Severity:   SeverityWarning,
+			Title:      fmt.Sprintf("SES sender identity not verified: %s", id.Email),
+			Fields: map[string]string{
+				"Region": id.Region,
+				"Status": string(status),
+			},
+			Details:   []string{"Email alerts from this identity will be rejected until it is verified again."},
+			Links:     []string{fmt.Sprintf("https://%s.console.aws.amazon.com/ses/home?region=%s#/identities", id.Region, id.Region)},
+			Timestamp: time.Now(),
+		})
+	}
+	return errors.Join(errs...)
+}
+
+// The verification status of an address, taking its domain's status when
+// the address itself isn't an identity. "NotStarted" when neither is.
+func sesVerificationStatus(ctx context.Context, id sesIdentity) (types.VerificationStatus, error) {
+	names := []string{id.Email}
+	if _, domain, ok := strings.Cut(id.Email, "@"); ok && domain != "" {
+		names = append(names, domain)
+	}
+	out, err := id.client.GetIdentityVerificationAttributes(ctx, &ses.GetIdentityVerificationAttributesInput{Identities: names})
+	if err != nil {
+		return "", err
+	}
+	status := types.VerificationStatusNotStarted
+	for _, name := range names {
+		attrs, ok := out.VerificationAttributes[name]
+		if !ok {
+			continue
+		}
+		if attrs.VerificationStatus == types.VerificationStatusSuccess {
+			return attrs.VerificationStatus, nil
+		}
+		status = attrs.VerificationStatus
+	}
+	return status, nil
+}
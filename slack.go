@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const slackAPIBase = "https://slack.com/api/"
+
+// Encodes the webhook body; a variable so tests can make encoding fail
+var marshalSlackPayload = json.Marshal
+
+// The smallest SLACK_SNIPPET_THRESHOLD accepted; below this a truncated
+// message would lose the title and fields along with the details
+const minSlackSnippetThreshold = 500
+
+// Builds the Slack webhook body for an alert, kept separate from the send so
+// the rendered output can be inspected without a webhook. Text over
+// SLACK_SNIPPET_THRESHOLD is truncated.
+func slackPayload(alert Alert) map[string]any {
+	payload := map[string]any{"text": truncateRunes(renderSlack(alert), cfg.SlackSnippetAt)}
+	// Console links would otherwise unfurl into large previews
+	if cfg.SlackDisableUnfurl {
+		payload["unfurl_links"] = false
+		payload["unfurl_media"] = false
+	}
+	return payload
+}
+
+type slackNotifier struct{}
+
+func (slackNotifier) Name() string { return "slack" }
+
+func (slackNotifier) Send(ctx context.Context, alert Alert) error {
+	return sendSlackNotification(ctx, alert)
+}
+
+func sendSlackNotification(ctx context.Context, alert Alert) error {
+	if cfg.SlackWebhookURL == "" {
+		slog.Info("Slack webhook URL not configured, skipping Slack notification")
+		return nil
+	}
+
+	if cfg.SlackThreadByDeploy && alert.Deployment != "" && cfg.SlackBotToken != "" && cfg.SlackChannelID != "" {
+		return postSlackDeploymentThread(ctx, alert)
+	}
+
+	// Long details (stack traces etc.) go up as a snippet when a bot token is
+	// available; otherwise the webhook message is truncated
+	if text := renderSlack(alert); utf8.RuneCountInString(text) > cfg.SlackSnippetAt && cfg.SlackBotToken != "" && cfg.SlackChannelID != "" {
+		err := uploadSlackSnippet(ctx, alert, text)
+		if err == nil {
+			return nil
+		}
+		slog.Warn("Slack snippet upload failed, falling back to truncated message", "error", err)
+	}
+
+	payloadBytes, err := marshalSlackPayload(slackPayload(alert))
+	if err != nil {
+		return fmt.Errorf("failed to encode Slack payload: %v", err)
+	}
+
+	return postJSONChecked(ctx, "Slack", cfg.SlackWebhookURL, payloadBytes, checkSlackWebhookResponse)
+}
+
+// Slack webhooks can answer 200 with an error such as "invalid_payload" or
+// "no_text" in the body; only a literal "ok" means the message was posted.
+func checkSlackWebhookResponse(body []byte) error {
+	switch text := strings.TrimSpace(string(body)); text {
+	case "ok":
+		return nil
+	case "":
+		return errors.New("empty response body")
+	default:
+		return errors.New(text)
+	}
+}
+
+// Uploads the full alert text as a snippet and shares it to SLACK_CHANNEL_ID
+// with a short summary as the message. Uses the external upload flow since
+// files.upload has been retired by Slack.
+func uploadSlackSnippet(ctx context.Context, alert Alert, text string) error {
+	var upload struct {
+		UploadURL string `json:"upload_url"`
+		FileID    string `json:"file_id"`
+	}
+	form := url.Values{
+		"filename": {"alert-details.txt"},
+		"length":   {strconv.Itoa(len(text))},
+	}
+	if err := callSlackAPI(ctx, "files.getUploadURLExternal", "application/x-www-form-urlencoded",
+		[]byte(form.Encode()), &upload); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, upload.UploadURL, strings.NewReader(text))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to upload Slack snippet: %v", err)
+	}
+	drainAndClose(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("received non-200 response uploading Slack snippet: %s", resp.Status)
+	}
+
+	summary := slackBold(alert.subject()) + "\n"
+	if alert.Banner != "" {
+		summary += alert.Banner + "\n"
+	}
+	summary += fmt.Sprintf("%s %s\n%s", slackBold(translate("Service")+":"), alert.Service, translate("Full details attached below."))
+	complete, err := json.Marshal(map[string]any{
+		"files":           []map[string]string{{"id": upload.FileID, "title": alert.subject()}},
+		"channel_id":      cfg.SlackChannelID,
+		"initial_comment": summary,
+	})
+	if err != nil {
+		return err
+	}
+	return callSlackAPI(ctx, "files.completeUploadExternal", "application/json", complete, nil)
+}
+
+// With SLACK_THREAD_BY_DEPLOYMENT, the first message about a deployment is
+// kept as the thread parent, by deployment ID, and later ones about the
+// same deployment reply to it
+const slackThreadKeyPrefix = "slackthread#"
+
+// Posts the alert with chat.postMessage, as a reply in its deployment's
+// thread when one has been started. Needs the chat:write scope.
+func postSlackDeploymentThread(ctx context.Context, alert Alert) error {
+	key := slackThreadKeyPrefix + alert.Deployment
+	parent, threaded, err := states.Get(ctx, key)
+	if err != nil {
+		slog.Warn("Error looking up Slack thread, starting a new one", "deployment", alert.Deployment, "error", err)
+		threaded = false
+	}
+
+	msg := slackPayload(alert)
+	msg["channel"] = cfg.SlackChannelID
+	if threaded && parent != "" {
+		msg["thread_ts"] = parent
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to encode Slack message: %v", err)
+	}
+	var posted struct {
+		TS string `json:"ts"`
+	}
+	if err := callSlackAPI(ctx, "chat.postMessage", "application/json; charset=utf-8", body, &posted); err != nil {
+		return err
+	}
+	if !threaded && posted.TS != "" {
+		if err := states.PutWithTTL(ctx, key, posted.TS, stateTTL); err != nil {
+			slog.Warn("Error saving Slack thread", "deployment", alert.Deployment, "error", err)
+		}
+	}
+	return nil
+}
+
+// Calls a Slack Web API method with the bot token. Slack reports most
+// failures as 200 with ok=false, so the body is always checked.
+func callSlackAPI(ctx context.Context, method, contentType string, body []byte, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, slackAPIBase+method, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Authorization", "Bearer "+cfg.SlackBotToken)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("slack %s failed: %v", method, err)
+	}
+	defer drainAndClose(resp.Body)
+
+	var raw json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return fmt.Errorf("slack %s returned %s with unreadable body: %v", method, resp.Status, err)
+	}
+	var status struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(raw, &status); err != nil {
+		return err
+	}
+	if !status.OK {
+		return fmt.Errorf("slack %s error: %s", method, status.Error)
+	}
+	if out != nil {
+		return json.Unmarshal(raw, out)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestSlackPayloadGolden(t *testing.T) {
+	for name, alert := range goldenAlerts(t) {
+		t.Run(name, func(t *testing.T) {
+			payload, err := json.MarshalIndent(slackPayload(alert), "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, "slack/"+name, string(payload)+"\n")
+		})
+	}
+}
+
+func TestCheckSlackWebhookResponse(t *testing.T) {
+	tests := []struct {
+		body    string
+		wantErr string
+	}{
+		{"ok", ""},
+		{"ok\n", ""},
+		{"invalid_payload", "invalid_payload"},
+		{"channel_not_found", "channel_not_found"},
+		{"no_text", "no_text"},
+		{"", "empty response body"},
+	}
+	for _, tt := range tests {
+		err := checkSlackWebhookResponse([]byte(tt.body))
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("body %q: unexpected error %v", tt.body, err)
+		case tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr):
+			t.Errorf("body %q: err = %v, want %q", tt.body, err, tt.wantErr)
+		}
+	}
+}
+
+// Slack answers 200 with the failure in the body; that is an error, and not
+// one worth retrying
+func TestSendSlackNotificationFailureBody(t *testing.T) {
+	for _, body := range []string{"ok", "invalid_payload", "channel_not_found"} {
+		t.Run(body, func(t *testing.T) {
+			var requests atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests.Add(1)
+				io.Copy(io.Discard, r.Body)
+				io.WriteString(w, body)
+			}))
+			defer srv.Close()
+			withConfig(t, func(c *Config) {
+				c.SlackWebhookURL = srv.URL
+				c.SlackBotToken = ""
+				c.WebhookMaxRetries = 2
+			})
+
+			err := sendSlackNotification(context.Background(), Alert{Severity: SeverityCritical, Title: "ECS Task Failure: api"})
+			if body == "ok" {
+				if err != nil {
+					t.Errorf("unexpected error %v", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), body) {
+				t.Errorf("err = %v, want it to report %q", err, body)
+			}
+			if n := requests.Load(); n != 1 {
+				t.Errorf("sent %d requests, want 1", n)
+			}
+		})
+	}
+}
+
+// A payload that fails to encode is reported and never posted
+func TestSendSlackNotificationMarshalFailure(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+	withConfig(t, func(c *Config) {
+		c.SlackWebhookURL = srv.URL
+		c.SlackBotToken = ""
+	})
+	saved := marshalSlackPayload
+	t.Cleanup(func() { marshalSlackPayload = saved })
+	marshalSlackPayload = func(any) ([]byte, error) {
+		return json.Marshal(map[string]any{"text": make(chan int)})
+	}
+
+	err := sendSlackNotification(context.Background(), Alert{Severity: SeverityCritical, Title: "ECS Task Failure: api"})
+	if err == nil || !strings.HasPrefix(err.Error(), "failed to encode Slack payload: ") {
+		t.Errorf("err = %v, want the encoding error", err)
+	}
+	if n := requests.Load(); n != 0 {
+		t.Errorf("posted %d requests after encoding failed", n)
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sns/types"
+)
+
+// One GSM-7 SMS segment, in septets. Longer texts are billed as several
+// messages, and a single character outside GSM-7 switches the whole text to
+// UCS-2 at 70 characters a segment, so the body is mapped to GSM-7 and cut
+// to fit a single one.
+const smsMaxLength = 160
+
+// The GSM 03.38 basic character set, one septet each
+const gsm7Basic = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?" +
+	"¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+
+// The extension table, two septets each: an escape and the character
+const gsm7Extended = "^{}\\[~]|€"
+
+// Stand-ins for characters outside GSM-7 that turn up in reasons and log
+// lines. Anything else becomes "?".
+var gsm7Replacer = strings.NewReplacer(
+	"…", "...", "‘", "'", "’", "'", "`", "'", "“", "\"", "”", "\"",
+	"–", "-", "—", "-", "\u00a0", " ", "\t", " ",
+)
+
+// Rewrites s in the GSM-7 character set
+func toGSM7(s string) string {
+	s = gsm7Replacer.Replace(s)
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(gsm7Basic, r) || strings.ContainsRune(gsm7Extended, r) {
+			return r
+		}
+		return '?'
+	}, s)
+}
+
+// The septets a GSM-7 text takes
+func gsm7Length(s string) int {
+	n := 0
+	for _, r := range s {
+		n++
+		if strings.ContainsRune(gsm7Extended, r) {
+			n++
+		}
+	}
+	return n
+}
+
+// Cuts a GSM-7 text to at most max septets, marking the cut with "..."
+func truncateGSM7(s string, max int) string {
+	if gsm7Length(s) <= max {
+		return s
+	}
+	n := 0
+	for i, r := range s {
+		size := gsm7Length(string(r))
+		if n+size > max-3 {
+			return s[:i] + "..."
+		}
+		n += size
+	}
+	return s
+}
+
+// SNS only accepts phone numbers in E.164 form
+var e164Re = regexp.MustCompile(`^\+[1-9]\d{6,14}$`)
+
+// Parses SMS_NUMBERS, rejecting anything SNS would refuse
+func parseSMSNumbers(numbers []string) ([]string, error) {
+	for _, n := range numbers {
+		if !e164Re.MatchString(n) {
+			return nil, fmt.Errorf("%q is not an E.164 number such as +14155550100", n)
+		}
+	}
+	return numbers, nil
+}
+
+// Builds the terse SMS text: incident ID and title, the most telling
+// reason, and the first console link when there is room for it beside the
+// text
+func smsMessage(alert Alert) string {
+	text := alert.displayTitle()
+	if alert.IncidentID != "" {
+		text = "[" + alert.IncidentID + "] " + text
+	}
+	if reason := smsReason(alert); reason != "" {
+		text += ": " + reason
+	}
+
+	text = toGSM7(text)
+
+	link := ""
+	if len(alert.Links) > 0 {
+		if l := toGSM7(alert.Links[0]); gsm7Length(l) <= smsMaxLength-40 {
+			link = l
+		}
+	}
+	room := smsMaxLength
+	if link != "" {
+		room -= gsm7Length(link) + 1
+	}
+	text = truncateGSM7(text, room)
+	if link != "" {
+		text += " " + link
+	}
+	return text
+}
+
+func smsReason(alert Alert) string {
+	if len(alert.Details) > 0 {
+		return alert.Details[0]
+	}
+	for _, label := range []string{"Reason", "Message"} {
+		if v := alert.Fields[label]; v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func sendSMSNotification(ctx context.Context, alert Alert) error {
+	if len(cfg.SMSNumbers) == 0 {
+		slog.Info("SMS numbers not configured, skipping SMS notification")
+		return nil
+	}
+
+	// smsMessage already keeps to one segment; this holds whatever it builds
+	// to the SNS limit like every other publish
+	message, truncated := fitSNSMessage(smsMessage(alert), snsMaxMessageBytes)
+	attributes := map[string]types.MessageAttributeValue{
+		// Transactional messages get delivery priority over promotional ones
+		"AWS.SNS.SMS.SMSType": {DataType: aws.String("String"), StringValue: aws.String("Transactional")},
+	}
+	if truncated {
+		attributes[snsTruncatedAttribute] = types.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String("true")}
+	}
+	var errs []error
+	for _, number := range cfg.SMSNumbers {
+		_, err := snsClient.Publish(ctx, &sns.PublishInput{
+			PhoneNumber:       aws.String(number),
+			Message:           aws.String(message),
+			MessageAttributes: attributes,
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to send SMS to %s: %v", maskPhone(number), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Keeps the last four digits so logs identify the recipient without exposing it
+func maskPhone(number string) string {
+	if len(number) <= 4 {
+		return "****"
+	}
+	return strings.Repeat("*", len(number)-4) + number[len(number)-4:]
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+)
+
+// Records the SNS Publish calls instead of making them
+type snsRecorder struct{ publishes []url.Values }
+
+func (r *snsRecorder) Do(req *http.Request) (*http.Response, error) {
+	body, _ := io.ReadAll(req.Body)
+	form, _ := url.ParseQuery(string(body))
+	r.publishes = append(r.publishes, form)
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"text/xml"}},
+		Body:       io.NopCloser(strings.NewReader(`<PublishResponse><PublishResult><MessageId>1</MessageId></PublishResult></PublishResponse>`)),
+		Request:    req,
+	}, nil
+}
+
+func withSNSRecorder(t *testing.T) *snsRecorder {
+	t.Helper()
+	saved := snsClient
+	t.Cleanup(func() { snsClient = saved })
+	recorder := &snsRecorder{}
+	snsClient = sns.New(sns.Options{Region: "us-east-1", Credentials: aws.AnonymousCredentials{}, HTTPClient: recorder})
+	return recorder
+}
+
+// Reads the message attributes of a recorded Publish, by name
+func snsAttributes(form url.Values) map[string]string {
+	attributes := make(map[string]string)
+	for i := 1; form.Has("MessageAttributes.entry." + strconv.Itoa(i) + ".Name"); i++ {
+		prefix := "MessageAttributes.entry." + strconv.Itoa(i)
+		attributes[form.Get(prefix+".Name")] = form.Get(prefix + ".Value.StringValue")
+	}
+	return attributes
+}
+
+func TestSendSMSNotification(t *testing.T) {
+	withConfig(t, func(c *Config) { c.SMSNumbers = []string{"+14155550100", "+14155550101"} })
+	recorder := withSNSRecorder(t)
+
+	alert := Alert{
+		Title:      "ECS task failed: api",
+		IncidentID: "a1b2c3",
+		Details:    []string{strings.Repeat("Container 'app' exited with code 137 (OutOfMemoryError) ", 5)},
+		Links:      []string{"https://console.aws.amazon.com/ecs/v2/clusters/prod/services/api"},
+	}
+	if err := sendSMSNotification(t.Context(), alert); err != nil {
+		t.Fatal(err)
+	}
+	if len(recorder.publishes) != 2 {
+		t.Fatalf("published %d messages, want one per number", len(recorder.publishes))
+	}
+	for i, form := range recorder.publishes {
+		if got := form.Get("PhoneNumber"); got != cfg.SMSNumbers[i] {
+			t.Errorf("publish %d went to %q", i, got)
+		}
+		if got := form.Get("Message"); got != smsMessage(alert) || gsm7Length(got) > smsMaxLength {
+			t.Errorf("publish %d message = %q (%d septets)", i, got, gsm7Length(got))
+		}
+		attributes := snsAttributes(form)
+		if attributes["AWS.SNS.SMS.SMSType"] != "Transactional" {
+			t.Errorf("publish %d attributes = %v, want a transactional SMS", i, attributes)
+		}
+		if _, ok := attributes[snsTruncatedAttribute]; ok {
+			t.Errorf("publish %d is marked truncated, but one segment fits in an SNS message", i)
+		}
+	}
+}
+
+func TestFitSNSMessage(t *testing.T) {
+	if got, cut := fitSNSMessage("short", snsMaxMessageBytes); got != "short" || cut {
+		t.Errorf("fitSNSMessage(short) = %q, %v", got, cut)
+	}
+	long := strings.Repeat("é", snsMaxMessageBytes)
+	got, cut := fitSNSMessage(long, snsMaxMessageBytes)
+	if !cut || len(got) > snsMaxMessageBytes || !strings.HasSuffix(got, truncatedMarker) {
+		t.Errorf("fitSNSMessage(long) is %d bytes, cut=%v", len(got), cut)
+	}
+}
+
+// A reason outside GSM-7 would switch the text to UCS-2, at 70 characters a
+// segment
+func TestSMSMessageGSM7(t *testing.T) {
+	alert := Alert{
+		Title:   "ECS task failed: api",
+		Details: []string{"Container ‘app’ exited — 内存不足 “OOM” {limit 512}… " + strings.Repeat("überfüllt ", 20)},
+		Links:   []string{"https://console.aws.amazon.com/ecs/v2/clusters/prod/services/api"},
+	}
+	got := smsMessage(alert)
+	for _, r := range got {
+		if !strings.ContainsRune(gsm7Basic, r) && !strings.ContainsRune(gsm7Extended, r) {
+			t.Errorf("message has %q, outside GSM-7: %q", r, got)
+		}
+	}
+	if n := gsm7Length(got); n > smsMaxLength {
+		t.Errorf("message takes %d septets, more than one segment: %q", n, got)
+	}
+	want := "ECS task failed: api: Container 'app' exited - ???? \"OOM\" {limit 512}... über"
+	if !strings.HasPrefix(got, want) {
+		t.Errorf("message = %q, want it to start %q", got, want)
+	}
+	if !strings.HasSuffix(got, "... "+alert.Links[0]) {
+		t.Errorf("message = %q, want the cut reason then the link", got)
+	}
+}
+
+func TestTruncateGSM7(t *testing.T) {
+	// Each brace takes two septets
+	got := truncateGSM7(strings.Repeat("{", 10), 10)
+	if got != "{{{..." || gsm7Length(got) > 10 {
+		t.Errorf("truncateGSM7 = %q (%d septets)", got, gsm7Length(got))
+	}
+	if got := truncateGSM7("short", 10); got != "short" {
+		t.Errorf("truncateGSM7(short) = %q", got)
+	}
+}
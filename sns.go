@@ -0,0 +1,16 @@
+package main
+
+// SNS rejects messages larger than 256 KB
+const snsMaxMessageBytes = 256 * 1024
+
+// Message attribute set on publishes whose body had to be cut down
+const snsTruncatedAttribute = "truncated"
+
+// Trims message so it fits in limit bytes, reporting whether anything was cut.
+// Independent of the SNS client so any SNS-based channel can share it.
+func fitSNSMessage(message string, limit int) (string, bool) {
+	if len(message) <= limit {
+		return message, false
+	}
+	return truncateText(message, limit), true
+}
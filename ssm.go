@@ -0,0 +1,21 @@
+package main
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+)
+
+// Reads a (usually SecureString) parameter, decrypted. Called from init, so
+// the value is fetched once per container and reused by every invocation.
+func fetchSSMParameter(ctx context.Context, client *ssm.Client, name string) (string, error) {
+	out, err := client.GetParameter(ctx, &ssm.GetParameterInput{
+		Name:           aws.String(name),
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(out.Parameter.Value), nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// State shared across invocations lives in a single DynamoDB table
+// (STATE_TABLE_NAME) keyed by a string partition key "pk". Items carry an
+// "expiresAt" epoch attribute so the table's TTL cleans up after us.
+var dynamoClient *dynamodb.Client
+
+// How long state items live before DynamoDB TTL removes them
+const stateTTL = 7 * 24 * time.Hour
+
+func attrS(v string) types.AttributeValue {
+	return &types.AttributeValueMemberS{Value: v}
+}
+
+func attrN(n int64) types.AttributeValue {
+	return &types.AttributeValueMemberN{Value: strconv.FormatInt(n, 10)}
+}
+
+func expiresAt(ttl time.Duration) types.AttributeValue {
+	return attrN(time.Now().Add(ttl).Unix())
+}
+
+func itemString(item map[string]types.AttributeValue, key string) string {
+	if v, ok := item[key].(*types.AttributeValueMemberS); ok {
+		return v.Value
+	}
+	return ""
+}
+
+func itemInt(item map[string]types.AttributeValue, key string) int64 {
+	if v, ok := item[key].(*types.AttributeValueMemberN); ok {
+		n, _ := strconv.ParseInt(v.Value, 10, 64)
+		return n
+	}
+	return 0
+}
+
+// Logs a failed state lookup and, in STRICT_MODE, returns it so the handler
+// fails and Lambda retries the event. These are the errors STRICT_MODE
+// treats as transient: state table reads and writes made while deciding
+// whether to suppress an alert, and the ECS DescribeServices call behind
+// ENRICH_FROM_API. Otherwise the failed check lets the alert through.
+// Returns nil for a nil err.
+func stateError(err error, msg string, args ...any) error {
+	if err == nil {
+		return nil
+	}
+	slog.Error(msg, append(args, "error", err)...)
+	if !cfg.StrictMode {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,442 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Keyed state with expiry, behind every feature that remembers something
+// between invocations. Keys carry the feature's prefix, as table partition
+// keys do. Records with several parts are stored as JSON values.
+type stateStore interface {
+	// Returns the value stored for key, ok=false when missing or expired
+	Get(ctx context.Context, key string) (value string, ok bool, err error)
+	// Stores value for key, replacing any value or count, until ttl from now
+	PutWithTTL(ctx context.Context, key, value string, ttl time.Duration) error
+	// Stores value for key until ttl from now and returns the unexpired
+	// value it replaced, as one step
+	Swap(ctx context.Context, key, value string, ttl time.Duration) (old string, ok bool, err error)
+	// Stores value for key unless an unexpired value is already there, and
+	// returns the value key holds afterwards, as one step. The caller that
+	// gets its own value back holds the key until it expires.
+	Claim(ctx context.Context, key, value string, ttl time.Duration) (holder string, err error)
+	// Removes key, whatever it holds
+	Delete(ctx context.Context, key string) error
+	// Adds one to the count at key and returns the new count. A missing or
+	// expired count starts again from zero and expires ttl after this call;
+	// later increments keep that expiry, so a count covers a fixed window.
+	// A non-empty member is counted once: incrementing again with a member
+	// already counted returns the count unchanged.
+	Increment(ctx context.Context, key, member string, ttl time.Duration) (int64, error)
+	// Like Increment, but every call moves the expiry to ttl from now, so
+	// the count lasts as long as increments arrive less than ttl apart
+	IncrementSliding(ctx context.Context, key, member string, ttl time.Duration) (int64, error)
+	// Appends value to the list at key and returns the list's new length.
+	// Like Increment, a new list expires ttl after the first append.
+	Append(ctx context.Context, key, value string, ttl time.Duration) (int64, error)
+	// Returns the list at key, nil when missing or expired
+	List(ctx context.Context, key string) ([]string, error)
+	// Returns the unexpired keys starting with prefix, for the sweeps
+	Keys(ctx context.Context, prefix string) ([]string, error)
+}
+
+// The store the state features use: the table when STATE_TABLE_NAME is set,
+// else memory that only lasts while Lambda reuses the container
+var states stateStore = newMemoryStore()
+
+// The value checks store to mark what the event being handled has done: its
+// event ID, so that when STRICT_MODE has Lambda redeliver the event after a
+// failed check, the checks that already ran recognise it rather than
+// treating it as a repeat. Invocations without an ID get a value of their
+// own.
+func eventToken() string {
+	if invocationEventID != "" {
+		return invocationEventID
+	}
+	return "local-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+}
+
+// Whether states outlives the container, i.e. is the table. Features that
+// hold alerts for a later invocation or sweep what earlier ones recorded
+// stay off without it, since memory state would be lost or never swept.
+var durableStates bool
+
+// Items are {pk, stateValue, expiresAt}, with lists kept in stateList and
+// the members a count has counted in stateMembers.
+// Counts and numeric values are stored as numbers so Increment can add to a
+// value PutWithTTL reset. DynamoDB TTL deletes expired items only
+// eventually, so reads check expiresAt too.
+type dynamoStore struct {
+	client *dynamodb.Client
+	table  string
+}
+
+func (s dynamoStore) Get(ctx context.Context, key string) (string, bool, error) {
+	out, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(s.table),
+		Key:       map[string]types.AttributeValue{"pk": attrS(key)},
+	})
+	if err != nil || !liveItem(out.Item) {
+		return "", false, err
+	}
+	return itemValue(out.Item), true, nil
+}
+
+func (s dynamoStore) PutWithTTL(ctx context.Context, key, value string, ttl time.Duration) error {
+	_, err := s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.table),
+		Item:      valueItem(key, value, ttl),
+	})
+	return err
+}
+
+func (s dynamoStore) Swap(ctx context.Context, key, value string, ttl time.Duration) (string, bool, error) {
+	out, err := s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:    aws.String(s.table),
+		Item:         valueItem(key, value, ttl),
+		ReturnValues: types.ReturnValueAllOld,
+	})
+	if err != nil || !liveItem(out.Attributes) {
+		return "", false, err
+	}
+	return itemValue(out.Attributes), true, nil
+}
+
+func (s dynamoStore) Claim(ctx context.Context, key, value string, ttl time.Duration) (string, error) {
+	_, err := s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(s.table),
+		Item:                valueItem(key, value, ttl),
+		ConditionExpression: aws.String("attribute_not_exists(pk) OR expiresAt <= :now"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":now": attrN(time.Now().Unix()),
+		},
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	})
+	var condErr *types.ConditionalCheckFailedException
+	if errors.As(err, &condErr) {
+		return itemValue(condErr.Item), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return value, nil
+}
+
+func (s dynamoStore) Delete(ctx context.Context, key string) error {
+	_, err := s.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(s.table),
+		Key:       map[string]types.AttributeValue{"pk": attrS(key)},
+	})
+	return err
+}
+
+func (s dynamoStore) Increment(ctx context.Context, key, member string, ttl time.Duration) (int64, error) {
+	return s.increment(ctx, key, member, ttl, "SET expiresAt = if_not_exists(expiresAt, :exp)")
+}
+
+func (s dynamoStore) IncrementSliding(ctx context.Context, key, member string, ttl time.Duration) (int64, error) {
+	return s.increment(ctx, key, member, ttl, "SET expiresAt = :exp")
+}
+
+func (s dynamoStore) increment(ctx context.Context, key, member string, ttl time.Duration, setExpiry string) (int64, error) {
+	update := "ADD stateValue :one " + setExpiry
+	cond := "(attribute_not_exists(pk) OR expiresAt > :now)"
+	values := map[string]types.AttributeValue{
+		":one": attrN(1),
+		":exp": expiresAt(ttl),
+		":now": attrN(time.Now().Unix()),
+	}
+	if member != "" {
+		update = "ADD stateValue :one, stateMembers :members " + setExpiry
+		cond += " AND NOT contains(stateMembers, :member)"
+		values[":members"] = &types.AttributeValueMemberSS{Value: []string{member}}
+		values[":member"] = attrS(member)
+	}
+	out, err := s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                           aws.String(s.table),
+		Key:                                 map[string]types.AttributeValue{"pk": attrS(key)},
+		UpdateExpression:                    aws.String(update),
+		ConditionExpression:                 aws.String(cond),
+		ExpressionAttributeValues:           values,
+		ReturnValues:                        types.ReturnValueUpdatedNew,
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	})
+	var condErr *types.ConditionalCheckFailedException
+	if errors.As(err, &condErr) {
+		if liveItem(condErr.Item) {
+			// The member was already counted
+			return itemInt(condErr.Item, "stateValue"), nil
+		}
+		// Expired but not yet removed by TTL: start a new count
+		item := valueItem(key, "1", ttl)
+		if member != "" {
+			item["stateMembers"] = values[":members"]
+		}
+		_, err := s.client.PutItem(ctx, &dynamodb.PutItemInput{TableName: aws.String(s.table), Item: item})
+		return 1, err
+	}
+	if err != nil {
+		return 0, err
+	}
+	return itemInt(out.Attributes, "stateValue"), nil
+}
+
+func (s dynamoStore) Append(ctx context.Context, key, value string, ttl time.Duration) (int64, error) {
+	entry := &types.AttributeValueMemberL{Value: []types.AttributeValue{attrS(value)}}
+	out, err := s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(s.table),
+		Key:                 map[string]types.AttributeValue{"pk": attrS(key)},
+		UpdateExpression:    aws.String("SET stateList = list_append(if_not_exists(stateList, :empty), :entry), expiresAt = if_not_exists(expiresAt, :exp)"),
+		ConditionExpression: aws.String("attribute_not_exists(pk) OR expiresAt > :now"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":empty": &types.AttributeValueMemberL{},
+			":entry": entry,
+			":exp":   expiresAt(ttl),
+			":now":   attrN(time.Now().Unix()),
+		},
+		ReturnValues: types.ReturnValueUpdatedNew,
+	})
+	var condErr *types.ConditionalCheckFailedException
+	if errors.As(err, &condErr) {
+		// Expired but not yet removed by TTL: start a new list
+		_, err := s.client.PutItem(ctx, &dynamodb.PutItemInput{
+			TableName: aws.String(s.table),
+			Item: map[string]types.AttributeValue{
+				"pk":        attrS(key),
+				"stateList": entry,
+				"expiresAt": expiresAt(ttl),
+			},
+		})
+		return 1, err
+	}
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(itemList(out.Attributes))), nil
+}
+
+func (s dynamoStore) List(ctx context.Context, key string) ([]string, error) {
+	out, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(s.table),
+		Key:       map[string]types.AttributeValue{"pk": attrS(key)},
+	})
+	if err != nil || !liveItem(out.Item) {
+		return nil, err
+	}
+	return itemList(out.Item), nil
+}
+
+func (s dynamoStore) Keys(ctx context.Context, prefix string) ([]string, error) {
+	paginator := dynamodb.NewScanPaginator(s.client, &dynamodb.ScanInput{
+		TableName:            aws.String(s.table),
+		FilterExpression:     aws.String("begins_with(pk, :prefix) AND expiresAt > :now"),
+		ProjectionExpression: aws.String("pk"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":prefix": attrS(prefix),
+			":now":    attrN(time.Now().Unix()),
+		},
+	})
+	var keys []string
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan %s keys: %v", prefix, err)
+		}
+		for _, item := range page.Items {
+			keys = append(keys, itemString(item, "pk"))
+		}
+	}
+	return keys, nil
+}
+
+func valueItem(key, value string, ttl time.Duration) map[string]types.AttributeValue {
+	val := attrS(value)
+	if _, err := strconv.ParseInt(value, 10, 64); err == nil {
+		val = &types.AttributeValueMemberN{Value: value}
+	}
+	return map[string]types.AttributeValue{
+		"pk":         attrS(key),
+		"stateValue": val,
+		"expiresAt":  expiresAt(ttl),
+	}
+}
+
+func liveItem(item map[string]types.AttributeValue) bool {
+	return item != nil && itemInt(item, "expiresAt") > time.Now().Unix()
+}
+
+func itemValue(item map[string]types.AttributeValue) string {
+	switch v := item["stateValue"].(type) {
+	case *types.AttributeValueMemberS:
+		return v.Value
+	case *types.AttributeValueMemberN:
+		return v.Value
+	}
+	return ""
+}
+
+func itemList(item map[string]types.AttributeValue) []string {
+	list, ok := item["stateList"].(*types.AttributeValueMemberL)
+	if !ok {
+		return nil
+	}
+	values := make([]string, 0, len(list.Value))
+	for _, v := range list.Value {
+		if s, ok := v.(*types.AttributeValueMemberS); ok {
+			values = append(values, s.Value)
+		}
+	}
+	return values
+}
+
+type memoryStore struct {
+	// Makes the read-and-write methods one step
+	mu      sync.Mutex
+	entries *ttlCache[string, memoryEntry]
+}
+
+type memoryEntry struct {
+	value     string
+	list      []string
+	members   []string
+	expiresAt time.Time
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{entries: newTTLCache[string, memoryEntry]()}
+}
+
+func (s *memoryStore) Get(_ context.Context, key string) (string, bool, error) {
+	e, ok := s.entries.Get(key)
+	return e.value, ok, nil
+}
+
+func (s *memoryStore) PutWithTTL(_ context.Context, key, value string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries.Set(key, memoryEntry{value: value, expiresAt: time.Now().Add(ttl)}, ttl)
+	return nil
+}
+
+func (s *memoryStore) Swap(_ context.Context, key, value string, ttl time.Duration) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, ok := s.entries.Swap(key, memoryEntry{value: value, expiresAt: time.Now().Add(ttl)}, ttl)
+	return old.value, ok, nil
+}
+
+func (s *memoryStore) Claim(_ context.Context, key, value string, ttl time.Duration) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.entries.Get(key); ok {
+		return e.value, nil
+	}
+	s.entries.Set(key, memoryEntry{value: value, expiresAt: time.Now().Add(ttl)}, ttl)
+	return value, nil
+}
+
+func (s *memoryStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries.Delete(key)
+	return nil
+}
+
+func (s *memoryStore) Increment(_ context.Context, key, member string, ttl time.Duration) (int64, error) {
+	return s.increment(key, member, ttl, false), nil
+}
+
+func (s *memoryStore) IncrementSliding(_ context.Context, key, member string, ttl time.Duration) (int64, error) {
+	return s.increment(key, member, ttl, true), nil
+}
+
+func (s *memoryStore) increment(key, member string, ttl time.Duration, sliding bool) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries.Get(key)
+	count, _ := strconv.ParseInt(e.value, 10, 64)
+	if ok && member != "" && slices.Contains(e.members, member) {
+		return count
+	}
+	if !ok || sliding {
+		e.expiresAt = time.Now().Add(ttl)
+	}
+	count++
+	e.value = strconv.FormatInt(count, 10)
+	if member != "" {
+		e.members = append(slices.Clip(e.members), member)
+	}
+	s.entries.Set(key, e, time.Until(e.expiresAt))
+	return count
+}
+
+func (s *memoryStore) Append(_ context.Context, key, value string, ttl time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries.Get(key)
+	if !ok {
+		e = memoryEntry{expiresAt: time.Now().Add(ttl)}
+	}
+	// A copy, so lists already handed out by List don't change
+	e.list = append(slices.Clip(e.list), value)
+	s.entries.Set(key, e, time.Until(e.expiresAt))
+	return int64(len(e.list)), nil
+}
+
+func (s *memoryStore) List(_ context.Context, key string) ([]string, error) {
+	e, _ := s.entries.Get(key)
+	return e.list, nil
+}
+
+func (s *memoryStore) Keys(_ context.Context, prefix string) ([]string, error) {
+	var keys []string
+	for _, k := range s.entries.Keys() {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	return keys, nil
+}
+
+// Reads a record stored with putRecord into v; ok is false when missing,
+// including for items an older version wrote without a value
+func getRecord(ctx context.Context, key string, v any) (ok bool, err error) {
+	raw, ok, err := states.Get(ctx, key)
+	if err != nil || !ok || raw == "" {
+		return false, err
+	}
+	if err := json.Unmarshal([]byte(raw), v); err != nil {
+		return false, fmt.Errorf("unreadable state %s: %v", key, err)
+	}
+	return true, nil
+}
+
+// Stores v as JSON, for records with several parts
+func putRecord(ctx context.Context, key string, v any, ttl time.Duration) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return states.PutWithTTL(ctx, key, string(raw), ttl)
+}
+
+// Reads a count or numeric value, 0 when missing
+func getCount(ctx context.Context, key string) (int64, error) {
+	v, _, err := states.Get(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := strconv.ParseInt(v, 10, 64)
+	return n, nil
+}
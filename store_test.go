@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	s := newMemoryStore()
+
+	if _, ok, _ := s.Get(ctx, "k"); ok {
+		t.Fatal("Get on an empty store found a value")
+	}
+	s.PutWithTTL(ctx, "k", "a", time.Hour)
+	if v, ok, _ := s.Get(ctx, "k"); !ok || v != "a" {
+		t.Errorf("Get = %q, %v; want a, true", v, ok)
+	}
+	if old, ok, _ := s.Swap(ctx, "k", "b", time.Hour); !ok || old != "a" {
+		t.Errorf("Swap returned %q, %v; want a, true", old, ok)
+	}
+	if old, ok, _ := s.Swap(ctx, "new", "b", time.Hour); ok || old != "" {
+		t.Errorf("Swap on a new key returned %q, %v; want nothing", old, ok)
+	}
+	s.Delete(ctx, "k")
+	if _, ok, _ := s.Get(ctx, "k"); ok {
+		t.Error("Get found a deleted key")
+	}
+
+	for want := int64(1); want <= 3; want++ {
+		if n, _ := s.Increment(ctx, "count", "", time.Hour); n != want {
+			t.Errorf("Increment = %d, want %d", n, want)
+		}
+		if n, _ := s.IncrementSliding(ctx, "sliding", "", time.Hour); n != want {
+			t.Errorf("IncrementSliding = %d, want %d", n, want)
+		}
+	}
+
+	first, _ := s.List(ctx, "list")
+	if len(first) != 0 {
+		t.Errorf("List on a new key = %q", first)
+	}
+	s.Append(ctx, "list", "x", time.Hour)
+	held, _ := s.List(ctx, "list")
+	if n, _ := s.Append(ctx, "list", "y", time.Hour); n != 2 {
+		t.Errorf("Append returned length %d, want 2", n)
+	}
+	if got, _ := s.List(ctx, "list"); !slices.Equal(got, []string{"x", "y"}) {
+		t.Errorf("List = %q, want [x y]", got)
+	}
+	if !slices.Equal(held, []string{"x"}) {
+		t.Errorf("a list returned earlier changed to %q", held)
+	}
+
+	s.PutWithTTL(ctx, "agg#api", "1", time.Hour)
+	s.PutWithTTL(ctx, "agg#worker", "1", time.Hour)
+	s.PutWithTTL(ctx, "agg#gone", "1", -time.Second)
+	keys, _ := s.Keys(ctx, "agg#")
+	slices.Sort(keys)
+	if !slices.Equal(keys, []string{"agg#api", "agg#worker"}) {
+		t.Errorf("Keys = %q, want the two unexpired agg# keys", keys)
+	}
+}
+
+func TestMemoryStoreClaimAndMembers(t *testing.T) {
+	ctx := context.Background()
+	s := newMemoryStore()
+
+	if holder, _ := s.Claim(ctx, "k", "event-a", time.Hour); holder != "event-a" {
+		t.Errorf("first Claim = %q, want event-a", holder)
+	}
+	if holder, _ := s.Claim(ctx, "k", "event-b", time.Hour); holder != "event-a" {
+		t.Errorf("second Claim = %q, want the first holder", holder)
+	}
+	s.PutWithTTL(ctx, "expired", "event-a", -time.Second)
+	if holder, _ := s.Claim(ctx, "expired", "event-b", time.Hour); holder != "event-b" {
+		t.Errorf("Claim over an expired value = %q, want event-b", holder)
+	}
+
+	for i, member := range []string{"event-a", "event-b", "event-a", "event-b", "event-c"} {
+		want := []int64{1, 2, 2, 2, 3}[i]
+		if n, _ := s.Increment(ctx, "count", member, time.Hour); n != want {
+			t.Errorf("Increment with %s = %d, want %d", member, n, want)
+		}
+	}
+	s.PutWithTTL(ctx, "count", "0", time.Hour)
+	if n, _ := s.Increment(ctx, "count", "event-a", time.Hour); n != 1 {
+		t.Errorf("Increment after a reset = %d, want 1", n)
+	}
+}
+
+func TestIncrementSlidingKeepsCountingWhileActive(t *testing.T) {
+	ctx := context.Background()
+	s := newMemoryStore()
+	ttl := 200 * time.Millisecond
+
+	// Each call lands inside the window the previous one extended, so the
+	// sliding count keeps going after the fixed window has started over
+	for range 5 {
+		s.Increment(ctx, "fixed", "", ttl)
+		s.IncrementSliding(ctx, "sliding", "", ttl)
+		time.Sleep(ttl / 4)
+	}
+	if n, _ := s.IncrementSliding(ctx, "sliding", "", ttl); n != 6 {
+		t.Errorf("sliding count = %d, want 6", n)
+	}
+	if n, _ := s.Increment(ctx, "fixed", "", ttl); n >= 6 {
+		t.Errorf("fixed count = %d, want it to have restarted", n)
+	}
+}
+
+func TestGetRecord(t *testing.T) {
+	withConfig(t, nil)
+	ctx := context.Background()
+
+	want := deploymentRecord{Service: "api", Cluster: "prod", StartedAt: testEventTime}
+	if err := putRecord(ctx, "deploy#a", want, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	var got deploymentRecord
+	if ok, err := getRecord(ctx, "deploy#a", &got); !ok || err != nil || got != want {
+		t.Errorf("getRecord = %+v, %v, %v; want %+v", got, ok, err, want)
+	}
+
+	// An item from before records, with no value
+	states.PutWithTTL(ctx, "deploy#old", "", time.Hour)
+	if ok, err := getRecord(ctx, "deploy#old", &got); ok || err != nil {
+		t.Errorf("getRecord on an item without a value = %v, %v; want missing", ok, err)
+	}
+
+	states.PutWithTTL(ctx, "deploy#bad", "{", time.Hour)
+	if _, err := getRecord(ctx, "deploy#bad", &got); err == nil {
+		t.Error("getRecord on unreadable JSON returned no error")
+	}
+}
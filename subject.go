@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"text/template"
+)
+
+// Values a SUBJECT_TEMPLATE can refer to. Title is the built-in subject,
+// so a template can wrap it rather than replace it.
+type subjectData struct {
+	Title      string
+	Service    string
+	Cluster    string
+	Severity   Severity
+	DetailType string
+	ExitCode   int
+	Fields     map[string]string
+}
+
+// Parses SUBJECT_TEMPLATE, a JSON object from detail-type to Go template, e.g.
+// {"ECS Task State Change": "PROD {{.Service}} crash (exit {{.ExitCode}})"}.
+// Each template is dry-run so unknown fields fail at init.
+func parseSubjectTemplates(spec string) (map[string]*template.Template, error) {
+	if strings.TrimSpace(spec) == "" {
+		return nil, nil
+	}
+	var raw map[string]string
+	if err := json.Unmarshal([]byte(spec), &raw); err != nil {
+		return nil, fmt.Errorf("not a JSON object of detail-type to template: %v", err)
+	}
+	templates := make(map[string]*template.Template, len(raw))
+	for detailType, text := range raw {
+		tmpl, err := template.New(detailType).Parse(text)
+		if err != nil {
+			return nil, err
+		}
+		if err := tmpl.Execute(new(strings.Builder), subjectData{}); err != nil {
+			return nil, err
+		}
+		templates[detailType] = tmpl
+	}
+	return templates, nil
+}
+
+// Replaces the alert's title with its detail-type's SUBJECT_TEMPLATE
+// rendering, if there is one. A template that fails at run time or renders
+// nothing keeps the built-in title.
+func applySubjectTemplate(alert Alert) Alert {
+	tmpl, ok := cfg.SubjectTemplates[alert.DetailType]
+	if !ok {
+		return alert
+	}
+	var b strings.Builder
+	err := tmpl.Execute(&b, subjectData{
+		Title:      alert.Title,
+		Service:    alert.Service,
+		Cluster:    alert.Cluster,
+		Severity:   alert.Severity,
+		DetailType: alert.DetailType,
+		ExitCode:   alert.ExitCode,
+		Fields:     alert.Fields,
+	})
+	if err != nil {
+		slog.Error("Error rendering subject template, using default subject", "detail_type", alert.DetailType, "error", err)
+		return alert
+	}
+	if subject := strings.TrimSpace(b.String()); subject != "" {
+		alert.Title = subject
+	}
+	return alert
+}
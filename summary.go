@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// What one invocation did, logged as a single "Invocation summary" line
+// when handleRequest returns so an invocation can be found with one grep.
+// Like invocationEventID it is reset per invocation, which is safe for the
+// same reason the logger swap is.
+type invocationSummary struct {
+	decision  string // alerted, suppressed, skipped, sweep, config_check, dead_lettered, dropped or error
+	reason    string
+	attempted []string
+	succeeded []string
+	sendTime  time.Duration
+}
+
+var summary invocationSummary
+
+func (s *invocationSummary) decide(decision, reason string) {
+	s.decision, s.reason = decision, reason
+}
+
+// Counts one send. A channel sent to twice in an invocation (a sweep
+// alerting for several services, say) is listed twice.
+func (s *invocationSummary) recordSend(channel string, err error, took time.Duration) {
+	s.attempted = append(s.attempted, channel)
+	if err == nil {
+		s.succeeded = append(s.succeeded, channel)
+	}
+	s.sendTime += took
+}
+
+func (s *invocationSummary) log(detailType string, err error) {
+	decision := s.decision
+	if err != nil {
+		decision = "error"
+	}
+	slog.Info("Invocation summary",
+		"detail_type", detailType,
+		"decision", decision,
+		"reason", s.reason,
+		"channels_attempted", s.attempted,
+		"channels_succeeded", s.succeeded,
+		"send_duration_ms", s.sendTime.Milliseconds(),
+		"error", err)
+}
+
+// Sends through one notifier, counting the send in the invocation summary
+func sendCounted(ctx context.Context, ch channel, n Notifier, alert Alert) error {
+	start := time.Now()
+	err := n.Send(ctx, alert)
+	summary.recordSend(ch.name, err, time.Since(start))
+	return err
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// Describes what the failed deployment changed compared with the previous
+// revision of its task definition family: images, environment variable
+// names (never values), and task or container CPU and memory. Needs
+// ENRICH_FROM_API. Returns nothing when the deployment can't be found on
+// the service any more.
+func taskDefinitionChanges(ctx context.Context, event events.CloudWatchEvent) ([]string, error) {
+	if ecsClient == nil {
+		return nil, nil
+	}
+	detail, err := parseDeploymentDetail(event)
+	if err != nil || detail.DeploymentID == "" {
+		return nil, err
+	}
+
+	out, err := ecsClient.DescribeServices(ctx, &ecs.DescribeServicesInput{
+		Cluster:  aws.String(detail.Cluster),
+		Services: []string{detail.Service},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var taskDefARN string
+	for _, svc := range out.Services {
+		for _, d := range svc.Deployments {
+			if aws.ToString(d.Id) == detail.DeploymentID {
+				taskDefARN = aws.ToString(d.TaskDefinition)
+			}
+		}
+	}
+	if taskDefARN == "" {
+		return nil, nil
+	}
+
+	failed, err := describeTaskDefinition(ctx, taskDefARN)
+	if err != nil {
+		return nil, err
+	}
+	name := fmt.Sprintf("%s:%d", aws.ToString(failed.Family), failed.Revision)
+	if failed.Revision <= 1 {
+		return []string{fmt.Sprintf("Task definition %s is the first revision, nothing to compare", name)}, nil
+	}
+	prevName := fmt.Sprintf("%s:%d", aws.ToString(failed.Family), failed.Revision-1)
+	prev, err := describeTaskDefinition(ctx, prevName)
+	if err != nil {
+		// Deleted revisions can no longer be described
+		return []string{fmt.Sprintf("Task definition %s, previous revision %s unavailable: %v", name, prevName, err)}, nil
+	}
+
+	changes := diffTaskDefinitions(prev, failed)
+	if len(changes) == 0 {
+		return []string{fmt.Sprintf("Task definition %s has no image, environment or size changes from %s", name, prevName)}, nil
+	}
+	return append([]string{fmt.Sprintf("Task definition %s changed from %s:", name, prevName)}, changes...), nil
+}
+
+func describeTaskDefinition(ctx context.Context, name string) (*types.TaskDefinition, error) {
+	out, err := ecsClient.DescribeTaskDefinition(ctx, &ecs.DescribeTaskDefinitionInput{TaskDefinition: aws.String(name)})
+	if err != nil {
+		return nil, err
+	}
+	return out.TaskDefinition, nil
+}
+
+// One line per change, task-level sizes first, then containers in the new
+// revision's order, then removed containers
+func diffTaskDefinitions(prev, next *types.TaskDefinition) []string {
+	var changes []string
+	change := func(label, before, after string) {
+		if before != after {
+			changes = append(changes, fmt.Sprintf("%s: %s → %s", label, orNone(before), orNone(after)))
+		}
+	}
+	change("Task CPU", aws.ToString(prev.Cpu), aws.ToString(next.Cpu))
+	change("Task memory", aws.ToString(prev.Memory), aws.ToString(next.Memory))
+
+	prevContainers := make(map[string]types.ContainerDefinition)
+	for _, c := range prev.ContainerDefinitions {
+		prevContainers[aws.ToString(c.Name)] = c
+	}
+	for _, c := range next.ContainerDefinitions {
+		name := aws.ToString(c.Name)
+		old, ok := prevContainers[name]
+		if !ok {
+			changes = append(changes, fmt.Sprintf("Container %s added (%s)", name, aws.ToString(c.Image)))
+			continue
+		}
+		delete(prevContainers, name)
+
+		change("Image of "+name, aws.ToString(old.Image), aws.ToString(c.Image))
+		change("CPU of "+name, units(old.Cpu), units(c.Cpu))
+		change("Memory of "+name, units(aws.ToInt32(old.Memory)), units(aws.ToInt32(c.Memory)))
+
+		added, removed := diffKeys(envKeys(old), envKeys(c))
+		if len(added) > 0 {
+			changes = append(changes, fmt.Sprintf("Env added to %s: %s", name, strings.Join(added, ", ")))
+		}
+		if len(removed) > 0 {
+			changes = append(changes, fmt.Sprintf("Env removed from %s: %s", name, strings.Join(removed, ", ")))
+		}
+	}
+	removed := make([]string, 0, len(prevContainers))
+	for name := range prevContainers {
+		removed = append(removed, name)
+	}
+	slices.Sort(removed)
+	for _, name := range removed {
+		changes = append(changes, fmt.Sprintf("Container %s removed", name))
+	}
+	return changes
+}
+
+// Container CPU and memory are 0 when not set
+func units(n int32) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(int(n))
+}
+
+// Names of a container's environment variables and secrets
+func envKeys(c types.ContainerDefinition) []string {
+	var keys []string
+	for _, kv := range c.Environment {
+		keys = append(keys, aws.ToString(kv.Name))
+	}
+	for _, s := range c.Secrets {
+		keys = append(keys, aws.ToString(s.Name))
+	}
+	return keys
+}
+
+func diffKeys(before, after []string) (added, removed []string) {
+	for _, k := range after {
+		if !slices.Contains(before, k) {
+			added = append(added, k)
+		}
+	}
+	for _, k := range before {
+		if !slices.Contains(after, k) {
+			removed = append(removed, k)
+		}
+	}
+	slices.Sort(added)
+	slices.Sort(removed)
+	return added, removed
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+const taskKeyPrefix = "task#"
+
+// Task records only need to outlive ECS re-emitting events for a task
+const taskStateTTL = 24 * time.Hour
+
+// Stores status as the task's last seen status and reports whether it was
+// already the last seen status, i.e. the event is a repeat rather than a
+// transition. Without a state table this only catches repeats delivered to
+// the same warm container.
+//
+// The status is stored with the event that made the transition, so a
+// redelivery of that event is still the transition.
+func isRepeatTaskStatus(ctx context.Context, taskArn, status string) (bool, error) {
+	if taskArn == "" {
+		return false, nil
+	}
+	key, token := taskKeyPrefix+taskArn, eventToken()
+	last, ok, err := states.Swap(ctx, key, status+"|"+token, taskStateTTL)
+	if err != nil || !ok {
+		return false, err
+	}
+	lastStatus, lastToken, _ := strings.Cut(last, "|")
+	if lastStatus != status || lastToken == token {
+		return false, nil
+	}
+	// Keep the transition's event rather than this repeat's
+	return true, states.PutWithTTL(ctx, key, last, taskStateTTL)
+}
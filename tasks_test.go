@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestIsRepeatTaskStatus(t *testing.T) {
+	withConfig(t, nil)
+	ctx := context.Background()
+
+	steps := []struct {
+		task, status string
+		want         bool
+	}{
+		{testTaskArn, "RUNNING", false},
+		{testTaskArn, "RUNNING", true},
+		{testTaskArn, "STOPPED", false},
+		{testTaskArn, "STOPPED", true},
+		{"arn:aws:ecs:us-east-1:123456789012:task/prod/other", "STOPPED", false},
+		{"", "STOPPED", false},
+		{"", "STOPPED", false},
+	}
+	for i, s := range steps {
+		got, err := isRepeatTaskStatus(ctx, s.task, s.status)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != s.want {
+			t.Errorf("step %d (%s %s): repeat=%v, want %v", i+1, s.task, s.status, got, s.want)
+		}
+	}
+}
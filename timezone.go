@@ -0,0 +1,62 @@
+package main
+
+import (
+	"log/slog"
+	"time"
+
+	// The provided.al2023 runtime has no zoneinfo database, so embed one
+	_ "time/tzdata"
+)
+
+// Local time zone of each AWS region's location, used when TIMEZONE is unset
+var regionTimezones = map[string]string{
+	"us-east-1":      "America/New_York",
+	"us-east-2":      "America/New_York",
+	"us-west-1":      "America/Los_Angeles",
+	"us-west-2":      "America/Los_Angeles",
+	"ca-central-1":   "America/Toronto",
+	"ca-west-1":      "America/Edmonton",
+	"mx-central-1":   "America/Mexico_City",
+	"sa-east-1":      "America/Sao_Paulo",
+	"eu-west-1":      "Europe/Dublin",
+	"eu-west-2":      "Europe/London",
+	"eu-west-3":      "Europe/Paris",
+	"eu-central-1":   "Europe/Berlin",
+	"eu-central-2":   "Europe/Zurich",
+	"eu-north-1":     "Europe/Stockholm",
+	"eu-south-1":     "Europe/Rome",
+	"eu-south-2":     "Europe/Madrid",
+	"me-south-1":     "Asia/Bahrain",
+	"me-central-1":   "Asia/Dubai",
+	"il-central-1":   "Asia/Jerusalem",
+	"af-south-1":     "Africa/Johannesburg",
+	"ap-south-1":     "Asia/Kolkata",
+	"ap-south-2":     "Asia/Kolkata",
+	"ap-east-1":      "Asia/Hong_Kong",
+	"ap-northeast-1": "Asia/Tokyo",
+	"ap-northeast-2": "Asia/Seoul",
+	"ap-northeast-3": "Asia/Tokyo",
+	"ap-southeast-1": "Asia/Singapore",
+	"ap-southeast-2": "Australia/Sydney",
+	"ap-southeast-3": "Asia/Jakarta",
+	"ap-southeast-4": "Australia/Melbourne",
+	"ap-southeast-5": "Asia/Kuala_Lumpur",
+	"ap-southeast-7": "Asia/Bangkok",
+}
+
+// Resolves the zone alert timestamps are shown in: an explicit TIMEZONE wins,
+// then the region's local zone, then UTC.
+func resolveLocation(timezone, region string) *time.Location {
+	if timezone == "" {
+		timezone = regionTimezones[region]
+	}
+	if timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		slog.Warn("Unknown time zone, using UTC", "timezone", timezone, "error", err)
+		return time.UTC
+	}
+	return loc
+}
@@ -4,6 +4,31 @@ variable "aws_region" {
   # No default = Must be supplied via TF_VAR_aws_region
 }
 
+variable "environment" {
+  type        = string
+  description = "Name of this stack's environment (e.g. prod, dev). Selects the matching entry of environment_targets."
+  default     = ""
+}
+
+variable "account_name" {
+  type        = string
+  description = "Name shown in brackets before every alert subject, e.g. prod-payments, to tell accounts apart. Takes precedence over account_alias_lookup."
+  default     = ""
+}
+
+variable "account_alias_lookup" {
+  type        = bool
+  description = "Prefix alert subjects with the account's IAM alias (looked up once per container via iam:ListAccountAliases), or its numeric ID when it has none."
+  default     = false
+}
+
+variable "environment_targets" {
+  type        = map(map(string))
+  description = "Webhooks and recipients per environment, e.g. { prod = { SLACK_WEBHOOK_URL = \"...\", RECIPIENT_EMAIL = \"oncall@example.com\" } }. Entries for the current environment override the plain variables. Keys: SLACK_WEBHOOK_URL, SLACK_WEBHOOK_SSM_PARAM, SLACK_CHANNEL_ID, SECURITY_SLACK_WEBHOOK_URL, MATTERMOST_WEBHOOK_URL, MATTERMOST_CHANNEL, GOOGLE_CHAT_WEBHOOK_URL, JSON_WEBHOOK_URL, CHATBOT_SNS_TOPIC_ARN, PAGERDUTY_ROUTING_KEY, SMS_NUMBERS, RECIPIENT_EMAIL."
+  sensitive   = true
+  default     = {}
+}
+
 variable "slack_webhook_url" {
   type        = string
   description = "Slack Webhook URL"
@@ -11,20 +36,602 @@ variable "slack_webhook_url" {
   # No default = Must be supplied via TF_VAR_slack_webhook_url
 }
 
+variable "slack_webhook_ssm_param" {
+  type        = string
+  description = "Name of an SSM parameter (SecureString) holding the Slack webhook URL. Read once per container and preferred over slack_webhook_url, which is used if the read fails. Leave empty to disable."
+  default     = ""
+}
+
+variable "security_slack_webhook_url" {
+  type        = string
+  description = "Slack webhook for the security channel. When set, GuardDuty and Security Hub findings are forwarded to the Lambda and go only to this webhook. Leave empty to disable."
+  sensitive   = true
+  default     = ""
+}
+
+variable "security_hub_severities" {
+  type        = list(string)
+  description = "Security Hub severity labels that alert, one alert per finding. Findings are forwarded when security_slack_webhook_url is set."
+  default     = ["CRITICAL", "HIGH"]
+}
+
+variable "slack_bot_token" {
+  type        = string
+  description = "Optional Slack bot token (files:write) used to upload long alert details as a snippet."
+  sensitive   = true
+  default     = ""
+}
+
+variable "slack_channel_id" {
+  type        = string
+  description = "Slack channel ID snippets are shared to. Required together with slack_bot_token."
+  default     = ""
+}
+
+variable "slack_snippet_threshold" {
+  type        = number
+  description = "Slack messages longer than this many characters are uploaded as a snippet (with a bot token) or truncated. At least 500."
+  default     = 3000
+}
+
+variable "slack_disable_unfurl" {
+  type        = bool
+  description = "Stop Slack from unfurling links (e.g. console URLs) in alerts."
+  default     = true
+}
+
+variable "slack_thread_by_deployment" {
+  type        = bool
+  description = "Post alerts about the same ECS deployment as replies in one Slack thread. Needs slack_bot_token (chat:write) and slack_channel_id."
+  default     = false
+}
+
+variable "mattermost_webhook_url" {
+  type        = string
+  description = "Mattermost incoming webhook URL. Leave empty to disable Mattermost."
+  sensitive   = true
+  default     = ""
+}
+
+variable "mattermost_channel" {
+  type        = string
+  description = "Optional Mattermost channel override (e.g. town-square). Empty uses the webhook's default channel."
+  default     = ""
+}
+
+variable "google_chat_webhook_url" {
+  type        = string
+  description = "Google Chat space webhook URL. Leave empty to disable Google Chat."
+  sensitive   = true
+  default     = ""
+}
+
+variable "json_webhook_url" {
+  type        = string
+  description = "URL that receives every alert as versioned JSON (schemaVersion field) for downstream processing. Leave empty to disable."
+  sensitive   = true
+  default     = ""
+}
+
+variable "webhook_signing_secret" {
+  type        = string
+  description = "Key for signing JSON webhook requests: each carries X-Signature (sha256= and the hex HMAC-SHA256 of \"<timestamp>.<body>\") and X-Signature-Timestamp (Unix seconds). Empty sends unsigned requests."
+  sensitive   = true
+  default     = ""
+}
+
+variable "chatbot_sns_topic_arn" {
+  type        = string
+  description = "SNS topic subscribed by AWS Chatbot. Alerts are published as Chatbot custom notifications. Leave empty to disable."
+  default     = ""
+}
+
+variable "pagerduty_routing_key" {
+  type        = string
+  description = "PagerDuty Events API v2 routing key. PagerDuty only gets escalated alerts, or severities that severity_routes sends to \"pagerduty\"."
+  sensitive   = true
+  default     = ""
+}
+
+variable "pagerduty_auto_resolve" {
+  type        = bool
+  description = "Resolve a service's PagerDuty incident when it recovers: a deployment completes or the service reaches steady state. Open incidents are tracked in the state table."
+  default     = false
+}
+
+variable "sms_numbers" {
+  type        = list(string)
+  description = "E.164 phone numbers (e.g. +14155550100) that get a one-segment SMS for critical alerts only. Leave empty to disable."
+  sensitive   = true
+  default     = []
+}
+
 variable "sender_email" {
   type        = string
   description = "SES Verified Sender Email"
   # No default = Must be supplied via TF_VAR_sender_email
 }
 
+variable "ses_identity_check" {
+  type        = bool
+  description = "On every scheduled sweep, check that sender_email and its fallbacks are still verified in SES (as an address or through their domain), and alert the other channels, at most daily, when one isn't."
+  default     = true
+}
+
+variable "sender_email_fallbacks" {
+  type        = list(string)
+  description = "Backup SES identities as email:region, tried in order when the primary is throttled or paused."
+  default     = []
+}
+
+variable "ses_regions" {
+  type        = list(string)
+  description = "Regions sender_email may be sent from; the first where it (or its domain) is verified in SES is used, rechecked hourly. Empty uses the function's region."
+  default     = []
+}
+
+variable "sender_name" {
+  type        = string
+  description = "Display name for the From header (e.g. Alerts). Empty sends the bare address."
+  default     = ""
+}
+
 variable "recipient_email" {
   type        = string
   description = "Email to receive alerts"
   # No default = Must be supplied via TF_VAR_recipient_email
 }
 
+variable "recipient_email_critical" {
+  type        = list(string)
+  description = "Addresses critical alerts are emailed to instead of recipient_email. Empty uses recipient_email."
+  default     = []
+}
+
+variable "recipient_email_warning" {
+  type        = list(string)
+  description = "Addresses warning alerts are emailed to instead of recipient_email. Empty uses recipient_email."
+  default     = []
+}
+
+variable "recipient_email_info" {
+  type        = list(string)
+  description = "Addresses info alerts are emailed to instead of recipient_email. Empty uses recipient_email."
+  default     = []
+}
+
+variable "timezone" {
+  type        = string
+  description = "IANA time zone for alert timestamps (e.g. Europe/London). Empty uses the region's local zone, or UTC if unknown."
+  default     = ""
+}
+
+variable "locale" {
+  type        = string
+  description = "Language for alert labels such as Service, Cluster and Reason: de, es, fr, ja or pt (region suffixes like de-DE are accepted). Empty or unknown uses English. Values from AWS are never translated."
+  default     = ""
+}
+
+variable "quiet_hours" {
+  type        = string
+  description = "Daily window in the alert time zone, e.g. \"22:00-07:00\", during which warning and info alerts are dropped and only critical ones are sent. The next alert afterwards reports how many were dropped. Leave empty to disable."
+  default     = ""
+}
+
 variable "monitored_services" {
   type        = list(string)
   description = "List of ECS Service names to monitor. Leave empty to monitor ALL services."
   default     = [] # Default is empty (Monitor Everything)
-}
\ No newline at end of file
+}
+
+variable "monitored_services_tag" {
+  type        = string
+  description = "Tag, as key=value (e.g. \"alerting=enabled\"), whose ECS services are monitored instead of monitored_services. monitored_services is still used until the first tag query succeeds. Empty disables the query."
+  default     = ""
+}
+
+variable "monitored_tag_ttl_seconds" {
+  type        = number
+  description = "How long a warm container reuses the tagged service list before querying again."
+  default     = 300
+}
+
+variable "alert_on_unknown_service" {
+  type        = bool
+  description = "Alert on failed tasks that belong to no service (e.g. run manually) even when monitored_services is set, since they can't be listed there."
+  default     = true
+}
+
+variable "deploy_timeout_minutes" {
+  type        = number
+  description = "Alert when an ECS deployment has been IN_PROGRESS longer than this many minutes."
+  default     = 30
+}
+
+variable "pending_timeout_seconds" {
+  type        = number
+  description = "Alert when an ECS task has been PROVISIONING or PENDING longer than this, e.g. from subnet IP or ENI exhaustion. Forwards every task start to the Lambda. 0 disables."
+  default     = 600
+}
+
+variable "task_correlation_seconds" {
+  type        = number
+  description = "After a deployment-failure alert, drop task-failure alerts for the same service for this many seconds, as they belong to the same incident. 0 disables."
+  default     = 300
+}
+
+variable "suppress_during_deploy" {
+  type        = bool
+  description = "Drop task-stop alerts for a service while it has a deployment in progress (and for deploy_grace_seconds after it ends), since deployments stop old tasks on purpose. Deployments running past deploy_timeout_minutes stop counting."
+  default     = false
+}
+
+variable "deploy_grace_seconds" {
+  type        = number
+  description = "How long after a deployment completes or fails its service's task stops are still treated as part of it, when suppress_during_deploy is on."
+  default     = 120
+}
+
+variable "alert_first_failure_only" {
+  type        = bool
+  description = "Send only the first task failure of each service deployment and hold the rest until the deployment completes or fails, then send one summary with the failure count."
+  default     = false
+}
+
+variable "alert_on_deploy_success" {
+  type        = bool
+  description = "Send an info-level message when an ECS deployment completes. Route info to non-paging channels with severity_routes."
+  default     = false
+}
+
+variable "cooldown_seconds" {
+  type        = number
+  description = "Minimum seconds between alerts for the same service and severity. Suppressed alerts are counted in the next one. 0 disables."
+  default     = 0
+}
+
+variable "escalate_after" {
+  type        = number
+  description = "Page PagerDuty once a service has failed this many times with no gap longer than escalate_window_seconds. 0 disables escalation."
+  default     = 0
+}
+
+variable "escalate_window_seconds" {
+  type        = number
+  description = "Quiet period after which a service's escalation failure count starts again from zero."
+  default     = 3600
+}
+
+variable "restart_alert_threshold" {
+  type        = number
+  description = "Page as a likely crash loop once a task family fails more than this many times within restart_window_seconds. The count resets when the service stabilises. 0 disables it."
+  default     = 0
+}
+
+variable "restart_window_seconds" {
+  type        = number
+  description = "Window restart_alert_threshold counts task failures over."
+  default     = 600
+}
+
+variable "deployment_dedup_seconds" {
+  type        = number
+  description = "Drop a deployment state change ECS repeats for the same deployment and event within this many seconds. 0 disables."
+  default     = 300
+}
+
+variable "dedup_key_template" {
+  type        = string
+  description = "Go template deciding which alerts are duplicates, over .Service, .Cluster, .Subject, .Severity, .DetailType and .ExitCode. Empty uses {{.Service}}|{{.Cluster}}|{{.Subject}}; when set it also keys the cooldown."
+  default     = ""
+}
+
+variable "dedup_bypass_critical" {
+  type        = bool
+  description = "Send critical alerts whatever the cooldown and repeat-window state, so only warnings and info are suppressed as duplicates. Aggregation and deployment correlation still group critical task failures."
+  default     = true
+}
+
+variable "subject_templates" {
+  type        = map(string)
+  description = "Go templates for alert subjects keyed by EventBridge detail-type, e.g. { \"ECS Task State Change\" = \"PROD {{.Service}} crash (exit {{.ExitCode}})\" }. Fields: Title (the default subject), Service, Cluster, Severity, DetailType, ExitCode, Fields. Unlisted detail-types keep the default subject."
+  default     = {}
+}
+
+variable "playbooks" {
+  type        = any
+  description = "Remediation hints added to matching alerts as a \"Likely Fix\" field, e.g. [{ detail_type = \"ECS Task State Change\", reason = \"/connection refused/\", fix = \"check DB connection string\", runbook = \"https://...\" }]. reason uses the scaling_reason_patterns syntax against the title, reason and details. The most specific match wins: detail_type and reason, then reason, then detail_type."
+  default     = []
+}
+
+variable "aggregate_window_seconds" {
+  type        = number
+  description = "Group task failures per service over this window: the first alerts immediately, the rest arrive as one \"N tasks failing\" summary. 0 disables."
+  default     = 0
+}
+
+variable "email_digest" {
+  type        = bool
+  description = "Collect email alerts into one digest per email_digest_seconds, sent by the scheduled sweep, while the other channels stay real-time. Needs state_table_name; without it emails are sent one by one."
+  default     = false
+}
+
+variable "email_digest_seconds" {
+  type        = number
+  description = "Length of each email digest bucket, when email_digest is on."
+  default     = 300
+}
+
+variable "scaling_reason_patterns" {
+  type        = list(string)
+  description = "Stopped reasons treated as routine scale-in and never alerted. Plain entries match as substrings; wrap in /.../ for a regex."
+  default     = ["Scaling activity", "Service scheduler"]
+}
+
+variable "placement_failure_patterns" {
+  type        = list(string)
+  description = "Stopped reasons that mean a task couldn't be placed. They always alert, even when a scaling_reason_patterns entry such as \"Service scheduler\" matches too. Same syntax as scaling_reason_patterns."
+  default     = ["unable to place", "no container instance met"]
+}
+
+variable "alert_on_capacity_failures" {
+  type        = bool
+  description = "Alert when ECS can't place a service's tasks for lack of capacity (Fargate, container instances or a service quota), naming the capacity provider."
+  default     = true
+}
+
+variable "alert_on_unhealthy_tasks" {
+  type        = bool
+  description = "Warn when a running task's container starts failing its health check, without waiting for the task to stop. Forwards every RUNNING task state change to the function."
+  default     = false
+}
+
+variable "enrich_from_api" {
+  type        = bool
+  description = "Call ECS DescribeServices on task failures and drop those that coincide with a desired-count decrease or a recent \"has stopped\" service event, even when the stopped reason isn't a known scaling one. Deployment failures also list what changed from the previous task definition revision."
+  default     = false
+}
+
+variable "force_alert_reasons" {
+  type        = list(string)
+  description = "Stopped or container reasons that always alert, even from ignored containers, below MIN_ALERT_EXIT_CODE or matching a scaling pattern, e.g. [\"OutOfMemoryError\", \"Task failed ELB health checks\"]. Plain entries match as substrings; wrap in /.../ for a regex."
+  default     = []
+}
+
+variable "ignored_containers" {
+  type        = list(string)
+  description = "Container names (e.g. log-router sidecars) whose exit codes never trigger an alert."
+  default     = []
+}
+
+variable "min_alert_exit_code" {
+  type        = number
+  description = "Container exit codes from 1 up to (but not including) this value don't alert. Only affects exit codes, not stopped-reason detection. Default 1 alerts on any nonzero code."
+  default     = 1
+}
+
+variable "max_container_lines" {
+  type        = number
+  description = "Failed-container lines shown in chat messages before \"… and N more\". Email and the JSON webhook always get the full list. 0 shows all."
+  default     = 10
+}
+
+variable "rds_alert_categories" {
+  type        = list(string)
+  description = "RDS event categories that raise an alert."
+  default     = ["failure", "failover", "low storage"]
+}
+
+variable "log_alert_log_groups" {
+  type        = list(string)
+  description = "CloudWatch Logs groups (e.g. /ecs/api) to subscribe the alerter to, so matching application log lines alert too."
+  default     = []
+}
+
+variable "log_alert_filter_pattern" {
+  type        = string
+  description = "CloudWatch Logs filter pattern deciding which lines of log_alert_log_groups are delivered to the alerter, e.g. \"?ERROR ?Exception\"."
+  default     = "ERROR"
+}
+
+variable "log_alert_pattern" {
+  type        = string
+  description = "Further filter on delivered log lines before alerting. A plain value matches as a substring; wrap in /.../ for a regex. Empty alerts on every delivered line."
+  default     = ""
+}
+
+variable "severity_routes" {
+  type        = map(list(string))
+  description = "Channels per severity, e.g. { critical = [\"slack\", \"email\"], info = [\"log\"] }. Channels: slack, security_slack, mattermost, googlechat, chatbot, pagerduty, sms, json, email, log. Unlisted severities go to every channel except pagerduty."
+  default     = {}
+}
+
+variable "severity_icons" {
+  type        = map(string)
+  description = "Prefix for alert subjects per severity, in every channel. Emoji or plain text such as \"[CRIT]\". Severities left out get no prefix."
+  default = {
+    critical = "⚠️"
+    info     = "✅"
+  }
+}
+
+variable "min_severity" {
+  type        = string
+  description = "Lowest severity sent anywhere: info, warning or critical. Alerts below it are only logged. Empty sends everything."
+  default     = ""
+}
+
+variable "channel_order" {
+  type        = list(string)
+  description = "Channels to try first, in this order, e.g. [\"email\", \"slack\"]. Channels left out follow in their default order."
+  default     = []
+}
+
+variable "fallback_channel" {
+  type        = string
+  description = "Channel (e.g. \"email\") that only gets an alert when sending it to another channel failed. Empty for none."
+  default     = ""
+}
+
+variable "profiles" {
+  type        = any
+  description = "Named overrides, e.g. { payments = { monitored_services = [\"api\"], channels = [\"slack\"], severity_routes = \"critical=slack,pagerduty\", ignored_containers = [], min_alert_exit_code = 2 } }. An invocation uses the profile named by a top-level \"profile\" field in its payload (set with an EventBridge input transformer), else active_profile."
+  default     = {}
+}
+
+variable "active_profile" {
+  type        = string
+  description = "Profile from profiles used when the event doesn't name one. Empty uses the plain settings."
+  default     = ""
+}
+
+variable "stop_on_first_success" {
+  type        = bool
+  description = "Stop sending an alert once one channel has delivered it, following channel_order. PagerDuty is still sent when an alert escalates."
+  default     = false
+}
+
+variable "strict_mode" {
+  type        = bool
+  description = "Fail the invocation so Lambda retries the event when a state table or ECS API lookup fails, instead of sending the alert without it. Unparseable events are never retried: they go to the DLQ or are dropped."
+  default     = false
+}
+
+variable "info_sample_rate" {
+  type        = number
+  description = "Share (0.0-1.0) of info-severity alerts to send; the ones sent are marked \"(sampled)\". Sampling is seeded per service, so it is reproducible. Critical and warning alerts are never sampled."
+  default     = 1
+}
+
+variable "webhook_max_retries" {
+  type        = number
+  description = "Retries for webhook sends on network errors and webhook_retry_statuses, with jittered exponential backoff."
+  default     = 2
+}
+
+variable "webhook_retry_statuses" {
+  type        = list(string)
+  description = "HTTP statuses webhook sends retry on, e.g. [\"429\", \"502\", \"503\"]; \"5xx\" covers a whole class. Other non-2xx statuses fail straight away."
+  default     = ["429", "5xx"]
+}
+
+variable "send_budget_seconds" {
+  type        = number
+  description = "Total time all notification sends for one event may take. Sends still pending when it runs out are cancelled and logged."
+  default     = 10
+}
+
+variable "repeat_window_seconds" {
+  type        = number
+  description = "Skip re-sending a message to a channel when the exact same text was sent there within this many seconds. Kept in memory per warm Lambda container, unlike dedup and cooldown. 0 disables."
+  default     = 0
+}
+
+variable "sentry_dsn" {
+  type        = string
+  description = "Sentry DSN. When set, notifications that fail to send are reported to Sentry with the channel, EventBridge event ID and error."
+  sensitive   = true
+  default     = ""
+}
+
+variable "heartbeat_url" {
+  type        = string
+  description = "Dead-man switch URL (e.g. a healthchecks.io check) that gets a GET after every successful invocation, so a monitor notices when the alerter stops running. A failed ping never fails the invocation."
+  sensitive   = true
+  default     = ""
+}
+
+variable "mask_arns" {
+  type        = bool
+  description = "Replace account IDs in ARNs with **** for Slack, Mattermost and Google Chat. Email and the JSON webhook keep full ARNs."
+  default     = false
+}
+
+variable "event_queue_arn" {
+  type        = string
+  description = "Existing SQS queue the alerter reads EventBridge events from in batches, for rules that target the queue instead of the Lambda. Empty reads no queue."
+  default     = ""
+}
+
+variable "audit_log_bucket" {
+  type        = string
+  description = "Existing S3 bucket every alert is archived to as JSON lines under alerts/yyyy/mm/dd/, whatever the channels did with it. Retention is up to the bucket's lifecycle rules. Empty disables the audit log."
+  default     = ""
+}
+
+variable "notification_banner" {
+  type        = string
+  description = "Line shown at the top of every alert body, e.g. \"This is an automated alert from AcmeCorp SRE ({environment})\". {environment} is replaced with var.environment. Empty shows none."
+  default     = ""
+}
+
+variable "banner_external_only" {
+  type        = bool
+  description = "Show notification_banner only on third-party channels (Slack, Mattermost, Google Chat, Chatbot, PagerDuty), leaving out email, SMS and the JSON webhook."
+  default     = false
+}
+
+variable "attach_metric_graph" {
+  type        = bool
+  description = "Attach the service's ECS CPU/memory graph for the last hour to alert emails."
+  default     = false
+}
+
+variable "slack_enabled" {
+  type        = bool
+  description = "Send Slack notifications. Set false to pause Slack without removing the webhook."
+  default     = true
+}
+
+variable "security_slack_enabled" {
+  type        = bool
+  description = "Send GuardDuty findings to the security Slack webhook. Set false to pause it without removing the URL."
+  default     = true
+}
+
+variable "mattermost_enabled" {
+  type        = bool
+  description = "Send Mattermost notifications. Set false to pause Mattermost without removing the webhook."
+  default     = true
+}
+
+variable "google_chat_enabled" {
+  type        = bool
+  description = "Send Google Chat notifications. Set false to pause Google Chat without removing the webhook."
+  default     = true
+}
+
+variable "json_webhook_enabled" {
+  type        = bool
+  description = "Send alerts to the JSON webhook. Set false to pause it without removing the URL."
+  default     = true
+}
+
+variable "chatbot_enabled" {
+  type        = bool
+  description = "Publish alerts to the AWS Chatbot topic. Set false to pause it without removing the topic."
+  default     = true
+}
+
+variable "pagerduty_enabled" {
+  type        = bool
+  description = "Send to PagerDuty. Set false to stop paging without removing the routing key."
+  default     = true
+}
+
+variable "sms_enabled" {
+  type        = bool
+  description = "Send SMS for critical alerts. Set false to stop texts without removing the numbers."
+  default     = true
+}
+
+variable "email_enabled" {
+  type        = bool
+  description = "Send email notifications. Set false to pause email without removing the addresses."
+  default     = true
+}
+variable "xray_enabled" {
+  type        = bool
+  description = "Turn on X-Ray active tracing. AWS calls and webhook posts show up as subsegments."
+  default     = false
+}
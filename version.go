@@ -0,0 +1,10 @@
+package main
+
+// Build info, injected at build time:
+//
+//	go build -ldflags "-X main.Version=v1.2.3 -X main.Commit=abc123 -X main.BuildDate=2024-01-01T00:00:00Z"
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildDate = "unknown"
+)
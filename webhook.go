@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Shared by all webhook-based senders so connections are reused across
+// invocations. Alerts go to a handful of hosts, so a small idle pool per
+// host is enough; idle connections are dropped before most providers would
+// close them on their side.
+var httpClient = &http.Client{
+	Timeout:   5 * time.Second,
+	Transport: newTransport(),
+}
+
+func newTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.MaxIdleConns = 32
+	t.MaxIdleConnsPerHost = 4
+	t.IdleConnTimeout = 60 * time.Second
+	return t
+}
+
+// Reads what's left of a response body before closing it. The transport
+// only returns a connection to the idle pool once its body has been read
+// to EOF, so closing early would force a new connection next time. Bodies
+// larger than the limit aren't worth the read and we let the connection go.
+func drainAndClose(body io.ReadCloser) {
+	io.Copy(io.Discard, io.LimitReader(body, 64<<10))
+	body.Close()
+}
+
+// Bounds for retry backoff: attempt n waits up to retryBaseDelay*2^n, capped
+const (
+	retryBaseDelay = 200 * time.Millisecond
+	retryMaxDelay  = 5 * time.Second
+)
+
+// Statuses retried when WEBHOOK_RETRY_STATUSES is unset: rate limiting and
+// server errors
+var defaultWebhookRetryStatuses = []string{"429", "5xx"}
+
+// A range of HTTP statuses: one code, or a whole class written as "5xx"
+type statusRange struct{ lo, hi int }
+
+// Parses WEBHOOK_RETRY_STATUSES entries such as "429", "503" or "5xx"
+func parseRetryStatuses(entries []string) ([]statusRange, error) {
+	var ranges []statusRange
+	for _, e := range entries {
+		if len(e) == 3 && strings.EqualFold(e[1:], "xx") && e[0] >= '1' && e[0] <= '5' {
+			lo := int(e[0]-'0') * 100
+			ranges = append(ranges, statusRange{lo, lo + 99})
+			continue
+		}
+		code, err := strconv.Atoi(e)
+		if err != nil || code < 100 || code > 599 {
+			return nil, fmt.Errorf("%q is not an HTTP status such as 503 or a class such as 5xx", e)
+		}
+		ranges = append(ranges, statusRange{code, code})
+	}
+	return ranges, nil
+}
+
+// Reports whether a webhook response status is worth another attempt
+func isRetryableStatus(code int) bool {
+	for _, r := range cfg.WebhookRetryOn {
+		if code >= r.lo && code <= r.hi {
+			return true
+		}
+	}
+	return false
+}
+
+// Posts a JSON body to a webhook URL, retrying network errors and the
+// WEBHOOK_RETRY_STATUSES responses (429 and 5xx by default) up to
+// WEBHOOK_MAX_RETRIES times. name is only used in messages.
+func postJSON(ctx context.Context, name, url string, body []byte) error {
+	return postJSONChecked(ctx, name, url, body, nil)
+}
+
+// Like postJSON, but check also gets the body of a successful response, for
+// providers that report failures in the body rather than the status.
+// Errors from check are not retried: the same payload would fail again.
+func postJSONChecked(ctx context.Context, name, url string, body []byte, check func([]byte) error) error {
+	return postJSONWith(ctx, name, url, body, check, nil)
+}
+
+// Like postJSON, but signs every attempt with WEBHOOK_SIGNING_SECRET, see
+// signRequest. Unsigned when no secret is set.
+func postSignedJSON(ctx context.Context, name, url string, body []byte) error {
+	if cfg.WebhookSecret == "" {
+		return postJSON(ctx, name, url, body)
+	}
+	return postJSONWith(ctx, name, url, body, nil, signRequest)
+}
+
+// The retry loop behind the postJSON variants. sign, when set, runs on each
+// attempt's request so a retry carries a fresh timestamp.
+func postJSONWith(ctx context.Context, name, url string, body []byte, check func([]byte) error, sign func(*http.Request, []byte)) error {
+	for attempt := 0; ; attempt++ {
+		retryable, err := postJSONOnce(ctx, name, url, body, check, sign)
+		if err == nil || !retryable || attempt >= cfg.WebhookMaxRetries {
+			return err
+		}
+		delay := backoffWithJitter(attempt)
+		slog.Warn("Retrying webhook send", "channel", name, "delay", delay, "error", err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+func postJSONOnce(ctx context.Context, name, url string, body []byte, check func([]byte) error, sign func(*http.Request, []byte)) (retryable bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if sign != nil {
+		sign(req, body)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("failed to send %s notification: %v", name, err)
+	}
+	defer drainAndClose(resp.Body)
+
+	// Most webhooks answer 200; PagerDuty's Events API answers 202
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		retryable = isRetryableStatus(resp.StatusCode)
+		return retryable, fmt.Errorf("received non-2xx response from %s: %s", name, resp.Status)
+	}
+	if check == nil {
+		return false, nil
+	}
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s response: %v", name, err)
+	}
+	if err := check(respBody); err != nil {
+		return false, fmt.Errorf("%s API error: %v", name, err)
+	}
+	return false, nil
+}
+
+// Sets X-Signature-Timestamp to the current Unix time and X-Signature to
+// "sha256=" and the hex HMAC-SHA256, keyed with WEBHOOK_SIGNING_SECRET, of
+// the timestamp, a ".", and the body. Receivers recompute it over the raw
+// body and reject stale timestamps, so a captured request can't be
+// replayed later.
+func signRequest(req *http.Request, body []byte) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(cfg.WebhookSecret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	req.Header.Set("X-Signature-Timestamp", timestamp)
+	req.Header.Set("X-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+}
+
+// Full jitter: a random delay between 0 and the exponential backoff for this
+// attempt, so concurrent invocations retrying the same outage spread out
+// instead of hitting the provider in lockstep.
+func backoffWithJitter(attempt int) time.Duration {
+	backoff := retryMaxDelay
+	if attempt < 16 {
+		backoff = min(retryBaseDelay<<attempt, retryMaxDelay)
+	}
+	return rand.N(backoff + 1)
+}
+
+const truncatedMarker = "… (truncated)"
+
+// Caps s at max bytes, marking the cut so readers know text is missing. The
+// cut backs up to a rune boundary so a multibyte character (an emoji, an
+// accented reason) is dropped whole rather than left as invalid UTF-8. A
+// limit too small for the marker keeps just the start of s.
+func truncateText(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	marker := truncatedMarker
+	if max < len(marker) {
+		marker = ""
+	}
+	cut := max - len(marker)
+	if cut < 0 {
+		cut = 0
+	}
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + marker
+}
+
+// Like truncateText, for limits counted in characters rather than bytes
+func truncateRunes(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	marker := truncatedMarker
+	if max < utf8.RuneCountInString(marker) {
+		marker = ""
+	}
+	keep := max - utf8.RuneCountInString(marker)
+	if keep < 0 {
+		keep = 0
+	}
+	return string([]rune(s)[:keep]) + marker
+}
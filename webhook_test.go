@@ -0,0 +1,71 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateText(t *testing.T) {
+	long := strings.Repeat("a", 40)
+	tests := []struct {
+		name string
+		s    string
+		max  int
+		want string
+	}{
+		{"fits", "short", 10, "short"},
+		{"exact boundary", "abcdefghij", 10, "abcdefghij"},
+		{"one over", long, 39, strings.Repeat("a", 39-len(truncatedMarker)) + truncatedMarker},
+		{"room for the marker only", long, len(truncatedMarker), truncatedMarker},
+		{"below the marker length", long, 5, "aaaaa"},
+		{"zero", long, 0, ""},
+		{"negative", long, -3, ""},
+		// "é" is two bytes; a cut inside it drops the whole rune
+		{"multibyte at the cut", "é" + strings.Repeat("é", 20), len(truncatedMarker) + 3, "é" + truncatedMarker},
+		{"multibyte below the marker length", "ééé", 3, "é"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncateText(tt.s, tt.max)
+			if got != tt.want {
+				t.Errorf("truncateText(%q, %d) = %q, want %q", tt.s, tt.max, got, tt.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("result %q is not valid UTF-8", got)
+			}
+			if tt.max >= 0 && len(got) > tt.max {
+				t.Errorf("result is %d bytes, over the limit of %d", len(got), tt.max)
+			}
+		})
+	}
+}
+
+func TestTruncateRunes(t *testing.T) {
+	markerRunes := utf8.RuneCountInString(truncatedMarker)
+	tests := []struct {
+		name string
+		s    string
+		max  int
+		want string
+	}{
+		{"fits", "héllo", 5, "héllo"},
+		{"multibyte counted as one", "日本語のテキスト", 8, "日本語のテキスト"},
+		{"one over", strings.Repeat("日", 20), 19, strings.Repeat("日", 19-markerRunes) + truncatedMarker},
+		{"room for the marker only", strings.Repeat("日", 20), markerRunes, truncatedMarker},
+		{"below the marker length", strings.Repeat("日", 20), 4, "日日日日"},
+		{"zero", "abc", 0, ""},
+		{"negative", "abc", -1, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncateRunes(tt.s, tt.max)
+			if got != tt.want {
+				t.Errorf("truncateRunes(%q, %d) = %q, want %q", tt.s, tt.max, got, tt.want)
+			}
+			if n := utf8.RuneCountInString(got); tt.max >= 0 && n > tt.max {
+				t.Errorf("result is %d runes, over the limit of %d", n, tt.max)
+			}
+		})
+	}
+}